@@ -0,0 +1,66 @@
+package tokenizer
+
+// Arena allocates tokens of one parse, see Tokenizer.ParseBytesArena.
+// The memory of the arena is released by its owner wholesale when the stream and its tokens aren't used anymore.
+type Arena interface {
+	// AllocTokens returns the slice of n zeroed tokens.
+	AllocTokens(n int) []Token
+}
+
+// TokenArena is the simple Arena which allocates tokens by blocks and reuses blocks after Reset.
+// TokenArena is not safe for concurrent use, use one arena per goroutine.
+type TokenArena struct {
+	blocks [][]Token
+	// index of the current block and count of allocated tokens of it
+	block, used int
+	size        int
+}
+
+// NewTokenArena creates the arena with blocks of `size` tokens.
+func NewTokenArena(size int) *TokenArena {
+	if size <= 0 {
+		size = maxSlabSize
+	}
+	return &TokenArena{size: size}
+}
+
+// AllocTokens returns the slice of n zeroed tokens from the current block, the new block is used if there is no room.
+func (a *TokenArena) AllocTokens(n int) []Token {
+	if n > a.size {
+		return make([]Token, n)
+	}
+	if a.block < len(a.blocks) && a.used+n > a.size {
+		a.block++
+		a.used = 0
+	}
+	if a.block == len(a.blocks) {
+		a.blocks = append(a.blocks, make([]Token, a.size))
+	}
+	tokens := a.blocks[a.block][a.used : a.used+n : a.used+n]
+	a.used += n
+	return tokens
+}
+
+// Reset releases all tokens of the arena wholesale, blocks are zeroed and reused.
+// Streams parsed with the arena and their tokens must not be used after Reset.
+func (a *TokenArena) Reset() {
+	for i := 0; i < len(a.blocks) && i <= a.block; i++ {
+		block := a.blocks[i]
+		for j := range block {
+			block[j] = Token{}
+		}
+	}
+	a.block, a.used = 0, 0
+}
+
+// ParseBytesArena parse the bytes slice into tokens like ParseBytes, all tokens are allocated from the arena
+// instead of the heap and pools, for latency-sensitive pipelines processing millions of small documents.
+// Stream.Close doesn't release tokens of the arena, the arena owner releases them wholesale (see TokenArena.Reset).
+func (t *Tokenizer) ParseBytesArena(arena Arena, data []byte) *Stream {
+	p := newArenaParser(t, arena, data)
+	p.parseTokens()
+	s := NewStream(p)
+	s.arena = true
+	p.release()
+	return s
+}
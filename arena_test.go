@@ -0,0 +1,51 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytesArena(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, "#", "\n")
+	tokenizer.DefineTrivia(20)
+	arena := NewTokenArena(16)
+
+	str := "a = 1 # one\nb = 2 c = 3 # tail\n"
+	expected := tokenizer.ParseString(str)
+	defer expected.Close()
+	stream := tokenizer.ParseBytesArena(arena, []byte(str))
+	require.Equal(t, expected.String(), stream.String())
+	require.Equal(t, expected.TrailingTrivia(), stream.TrailingTrivia())
+	require.Len(t, arena.blocks, 1)
+	stream.Close()
+
+	// tokens of the arena are not released into the pool of the tokenizer
+	for i := 0; i < 20; i++ {
+		pooled := tokenizer.ParseString("x")
+		require.False(t, inArena(arena, pooled.CurrentToken()))
+		pooled.Close()
+	}
+
+	arena.Reset()
+	require.Equal(t, Token{}, arena.blocks[0][0])
+	stream = tokenizer.ParseBytesArena(arena, []byte("one two"))
+	require.True(t, inArena(arena, stream.CurrentToken()))
+	require.Equal(t, "two", stream.GoNext().CurrentToken().ValueString())
+	require.Len(t, arena.blocks, 1)
+	require.Len(t, arena.AllocTokens(20), 20)
+}
+
+// inArena checks if the token is allocated from blocks of the arena.
+func inArena(a *TokenArena, t *Token) bool {
+	for _, block := range a.blocks {
+		for i := range block {
+			if &block[i] == t {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package tokenizer
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// Profiler labels of parse phases, see Tokenizer.ProfileLabels.
+var (
+	labelsParse     = pprof.Labels("tokenizer", "parse")
+	labelsInjection = pprof.Labels("tokenizer", "injection")
+)
+
+// ProfileLabels enables pprof labels around parse phases: samples of CPU profiles are labeled with
+// tokenizer=parse while scanning and tokenizer=injection while parsing injections of framed strings,
+// so time of the tokenizer can be filtered in profiles of applications (`go tool pprof -tagfocus tokenizer=injection`).
+// Labels are inherited from the context of Tokenizer.ParseBytesCtx and Tokenizer.ParseReaderCtx.
+func (t *Tokenizer) ProfileLabels() *Tokenizer {
+	t.flags |= fProfileLabels
+	return t
+}
+
+// labeled runs fn with the profiler labels added to labels of the context of the parser.
+func (p *parsing) labeled(labels pprof.LabelSet, fn func()) {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pprof.Do(ctx, labels, func(context.Context) {
+		fn()
+	})
+}
+
+// BenchResult describes the run of the tokenizer over the corpus, see Tokenizer.Bench.
+type BenchResult struct {
+	// N is the count of iterations over the corpus.
+	N int
+	// Bytes is the size of the corpus in bytes.
+	Bytes int
+	// Tokens is the count of tokens of the corpus, without trivia tokens.
+	Tokens int
+	// Duration is the total time of all iterations.
+	Duration time.Duration
+	// Allocs is the count of heap allocations per iteration.
+	Allocs uint64
+	// AllocBytes is the count of allocated bytes per iteration.
+	AllocBytes uint64
+}
+
+// PerIteration returns the average time of one iteration over the corpus.
+func (r BenchResult) PerIteration() time.Duration {
+	if r.N == 0 {
+		return 0
+	}
+	return r.Duration / time.Duration(r.N)
+}
+
+// BytesPerSecond returns the throughput of the tokenizer.
+func (r BenchResult) BytesPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) * float64(r.N) / r.Duration.Seconds()
+}
+
+// TokensPerSecond returns the count of tokens produced per second.
+func (r BenchResult) TokensPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Tokens) * float64(r.N) / r.Duration.Seconds()
+}
+
+// String returns the result in the form of Go benchmarks.
+func (r BenchResult) String() string {
+	return fmt.Sprintf("%d\t%d ns/op\t%.2f MB/s\t%.0f tokens/s\t%d B/op\t%d allocs/op",
+		r.N, r.PerIteration().Nanoseconds(), r.BytesPerSecond()/1e6, r.TokensPerSecond(), r.AllocBytes, r.Allocs)
+}
+
+// Bench parses each document of the corpus n times and measures time and allocations,
+// so performance regressions of user grammars can be measured reproducibly outside of `go test`,
+// e.g. in CI jobs comparing the grammar of the branch with the released one.
+// The fixed count of iterations makes results of runs comparable, streams are closed after each parse.
+func (t *Tokenizer) Bench(n int, corpus ...[]byte) BenchResult {
+	r := BenchResult{N: n}
+	for _, data := range corpus {
+		r.Bytes += len(data)
+		stream := t.ParseBytes(data)
+		r.Tokens += stream.len
+		stream.Close()
+	}
+	if n <= 0 {
+		r.N = 0
+		return r
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	for i := 0; i < n; i++ {
+		for _, data := range corpus {
+			t.ParseBytes(data).Close()
+		}
+	}
+	r.Duration = time.Since(started)
+	runtime.ReadMemStats(&after)
+	r.Allocs = (after.Mallocs - before.Mallocs) / uint64(n)
+	r.AllocBytes = (after.TotalAlloc - before.TotalAlloc) / uint64(n)
+	return r
+}
@@ -0,0 +1,56 @@
+package tokenizer
+
+import (
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeProfileLabels(t *testing.T) {
+	define := func() *Tokenizer {
+		tokenizer := New()
+		tokenizer.DefineTokens(TokenKey(10), []string{"{{"})
+		tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+		tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).AddInjection(TokenKey(10), TokenKey(11))
+		return tokenizer
+	}
+	source := `a = "one {{ two + 2 }} three" + 1`
+	expected := define().ParseString(source)
+	defer expected.Close()
+
+	labeled := define().ProfileLabels()
+	stream := labeled.ParseString(source)
+	defer stream.Close()
+	require.Nil(t, Diff(expected, stream, true))
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("request", "42"))
+	stream, err := labeled.ParseBytesCtx(ctx, []byte(source))
+	require.NoError(t, err)
+	defer stream.Close()
+	require.Nil(t, Diff(expected, stream, true))
+}
+
+func TestTokenizerBench(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"+", "="})
+	corpus := [][]byte{[]byte("a = b + 1"), []byte(strings.Repeat("x + 2 ", 100))}
+
+	result := tokenizer.Bench(10, corpus...)
+	require.Equal(t, 10, result.N)
+	require.Equal(t, 9+600, result.Bytes)
+	require.Equal(t, 5+300, result.Tokens)
+	require.Greater(t, result.Duration.Nanoseconds(), int64(0))
+	require.Greater(t, result.BytesPerSecond(), 0.0)
+	require.Greater(t, result.TokensPerSecond(), 0.0)
+	require.Equal(t, result.Duration/10, result.PerIteration())
+	require.Contains(t, result.String(), "10\t")
+	require.Contains(t, result.String(), " allocs/op")
+
+	result = tokenizer.Bench(0, corpus...)
+	require.Equal(t, BenchResult{Bytes: 609, Tokens: 305}, result)
+	require.Zero(t, result.PerIteration())
+	require.Zero(t, result.BytesPerSecond())
+}
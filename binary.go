@@ -0,0 +1,383 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// binaryMagic is the header of the binary representation of the stream.
+var binaryMagic = []byte("TKS\x02")
+
+// Bounds of Tokenizer.ReadBinary for the untrusted input: slices aren't preallocated beyond binaryPrealloc
+// elements or bytes and grow as data is read, so the hostile header can't allocate the memory which isn't backed
+// by data. Counts and sizes above binaryMaxCount are invalid.
+const (
+	binaryPrealloc = 4096
+	binaryMaxCount = math.MaxInt32
+)
+
+// ErrInvalidBinary is returned by Tokenizer.ReadBinary if data is not a valid binary representation of the stream.
+var ErrInvalidBinary = errors.New("invalid binary stream")
+
+// WriteBinary writes compact binary representation of tokens from the head token to the writer.
+// Values and indents of tokens are stored once in the shared table, keys and positions are varint-encoded.
+// The stream may be restored by Tokenizer.ReadBinary with the same tokenizer definitions.
+// In the streaming mode all data will be parsed. The pointer stays unchanged.
+func (s *Stream) WriteBinary(w io.Writer) error {
+	var tokens []*Token
+	for ptr := s.head; ptr != nil && ptr != undefToken; ptr = s.nextOf(ptr) {
+		tokens = append(tokens, ptr)
+	}
+	enc := binaryEncoder{index: map[string]uint64{}, dates: s.t.dateTimes}
+	for _, t := range tokens {
+		for _, tr := range t.trivia {
+			enc.add(tr)
+		}
+		enc.add(t)
+	}
+	trivia := s.trailingTrivia()
+	for _, tr := range trivia {
+		enc.add(tr)
+	}
+	tail := enc.value(s.tail())
+
+	buf := bufio.NewWriter(w)
+	out := writer{w: buf}
+	out.write(binaryMagic)
+	out.write(binary.AppendUvarint(nil, uint64(len(enc.values))))
+	for _, v := range enc.values {
+		out.write(binary.AppendUvarint(nil, uint64(len(v))))
+		out.write(v)
+	}
+	headID := 0
+	if len(tokens) > 0 {
+		headID = tokens[0].id
+	}
+	out.write(binary.AppendUvarint(nil, uint64(headID)))
+	out.write(binary.AppendUvarint(nil, uint64(len(tokens))))
+	for _, t := range tokens {
+		out.write(enc.token(t))
+	}
+	out.write(binary.AppendUvarint(nil, uint64(len(trivia))))
+	for _, tr := range trivia {
+		out.write(enc.token(tr))
+	}
+	out.write(binary.AppendUvarint(nil, tail))
+	if out.err != nil {
+		return out.err
+	}
+	return buf.Flush()
+}
+
+// binaryEncoder builds the value table and encodes tokens.
+type binaryEncoder struct {
+	values [][]byte
+	index  map[string]uint64
+	dates  []*DateTimeSettings
+	offset int
+	line   int
+}
+
+func (e *binaryEncoder) add(t *Token) {
+	e.value(t.value)
+	e.value(t.indent)
+	if t.folded != nil {
+		e.value(t.folded)
+	}
+}
+
+// value returns the index of the value in the table.
+func (e *binaryEncoder) value(v []byte) uint64 {
+	if i, ok := e.index[string(v)]; ok {
+		return i
+	}
+	i := uint64(len(e.values))
+	e.values = append(e.values, v)
+	e.index[string(v)] = i
+	return i
+}
+
+// token encodes the token: key, value, indent, offset and line as deltas from the previous token, column,
+// string definition, the length of the merged unit, date and time definition, the folded value and trivia tokens.
+func (e *binaryEncoder) token(t *Token) []byte {
+	b := make([]byte, 0, 16)
+	b = binary.AppendVarint(b, int64(t.key))
+	b = binary.AppendUvarint(b, e.index[string(t.value)])
+	b = binary.AppendUvarint(b, e.index[string(t.indent)])
+	b = binary.AppendVarint(b, int64(t.offset-e.offset))
+	b = binary.AppendVarint(b, int64(t.line-e.line))
+	b = binary.AppendUvarint(b, uint64(t.column))
+	e.offset = t.offset
+	e.line = t.line
+	if t.string != nil {
+		b = binary.AppendUvarint(b, uint64(t.string.id+1))
+	} else {
+		b = binary.AppendUvarint(b, 0)
+	}
+	b = binary.AppendUvarint(b, uint64(t.unit))
+	b = binary.AppendUvarint(b, e.date(t.date))
+	if t.folded != nil {
+		b = binary.AppendUvarint(b, e.index[string(t.folded)]+1)
+	} else {
+		b = binary.AppendUvarint(b, 0)
+	}
+	b = binary.AppendUvarint(b, uint64(len(t.trivia)))
+	for _, tr := range t.trivia {
+		b = append(b, e.token(tr)...)
+	}
+	return b
+}
+
+// date returns the sequence number of the date and time definition plus one or zero if the token isn't the date.
+func (e *binaryEncoder) date(d *DateTimeSettings) uint64 {
+	for i, other := range e.dates {
+		if other == d {
+			return uint64(i + 1)
+		}
+	}
+	return 0
+}
+
+// ReadBinary restores the stream written by Stream.WriteBinary.
+// String tokens are bound to the string definitions of the tokenizer by their sequence number (see StringSettings.ID),
+// date and time literals are bound to date and time definitions by the order of Tokenizer.DefineDateTimeToken calls.
+func (t *Tokenizer) ReadBinary(r io.Reader) (*Stream, error) {
+	dec := binaryDecoder{t: t, r: bufio.NewReader(r)}
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(dec.r, magic); err != nil {
+		return nil, dec.fail(err)
+	}
+	if string(magic) != string(binaryMagic) {
+		return nil, ErrInvalidBinary
+	}
+	n, err := dec.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > binaryMaxCount {
+		return nil, ErrInvalidBinary
+	}
+	dec.values = make([][]byte, 0, prealloc(n))
+	for i := uint64(0); i < n; i++ {
+		v, err := dec.bytes()
+		if err != nil {
+			return nil, err
+		}
+		dec.values = append(dec.values, v)
+	}
+	headID, err := dec.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	count, err := dec.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if headID > binaryMaxCount || count > binaryMaxCount-headID {
+		return nil, ErrInvalidBinary
+	}
+	s := &Stream{t: t, len: int(count)}
+	var ptr *Token
+	for i := 0; i < int(count); i++ {
+		tok, err := dec.token(int(headID)+i, 0)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		if ptr == nil {
+			s.head = tok
+		} else {
+			ptr.addNext(tok)
+		}
+		ptr = tok
+	}
+	nTrivia, err := dec.uvarint()
+	if err == nil {
+		for i := uint64(0); i < nTrivia && err == nil; i++ {
+			var tr *Token
+			if tr, err = dec.token(int(headID+count), 1); err == nil {
+				s.trivia = append(s.trivia, tr)
+			}
+		}
+	}
+	if err == nil {
+		s.wsTail, err = dec.value()
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.current = s.head
+	s.undefined()
+	if ptr != nil {
+		s.parsed = ptr.offset + len(ptr.value)
+	}
+	if n := len(s.trivia); n > 0 {
+		s.parsed = s.trivia[n-1].offset + len(s.trivia[n-1].value)
+	}
+	s.parsed += len(s.wsTail)
+	s.stats = Stats{Tokens: s.len, Trivia: len(s.trivia), Bytes: s.parsed}
+	for ptr := s.head; ptr != nil; ptr = ptr.next {
+		s.stats.Trivia += len(ptr.trivia)
+	}
+	return s, nil
+}
+
+// binaryDecoder decodes tokens written by binaryEncoder.
+type binaryDecoder struct {
+	t      *Tokenizer
+	r      *bufio.Reader
+	values [][]byte
+	offset int
+	line   int
+}
+
+// fail converts unexpected end of data to ErrInvalidBinary.
+func (d *binaryDecoder) fail(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrInvalidBinary
+	}
+	return err
+}
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, d.fail(err)
+	}
+	return v, nil
+}
+
+func (d *binaryDecoder) varint() (int64, error) {
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return 0, d.fail(err)
+	}
+	return v, nil
+}
+
+// prealloc returns the capacity for `n` elements which are not read yet.
+func prealloc(n uint64) int {
+	if n > binaryPrealloc {
+		return binaryPrealloc
+	}
+	return int(n)
+}
+
+// bytes reads the size and the value of the table.
+func (d *binaryDecoder) bytes() ([]byte, error) {
+	size, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if size > math.MaxInt32 {
+		return nil, ErrInvalidBinary
+	}
+	if size <= binaryPrealloc {
+		v := make([]byte, size)
+		if _, err = io.ReadFull(d.r, v); err != nil {
+			return nil, d.fail(err)
+		}
+		return v, nil
+	}
+	var buf bytes.Buffer
+	if _, err = io.CopyN(&buf, d.r, int64(size)); err != nil {
+		return nil, d.fail(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// value reads the index and returns the value from the table.
+func (d *binaryDecoder) value() ([]byte, error) {
+	i, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if i >= uint64(len(d.values)) {
+		return nil, ErrInvalidBinary
+	}
+	if len(d.values[i]) == 0 {
+		return nil, nil
+	}
+	return d.values[i], nil
+}
+
+// token decodes the token, `depth` is 1 for trivia tokens which can't have own trivia.
+func (d *binaryDecoder) token(id, depth int) (*Token, error) {
+	key, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	tok := d.t.allocToken()
+	tok.id = id
+	tok.key = TokenKey(key)
+	if tok.value, err = d.value(); err != nil {
+		d.t.freeToken(tok)
+		return nil, err
+	}
+	if tok.indent, err = d.value(); err != nil {
+		d.t.freeToken(tok)
+		return nil, err
+	}
+	var offset, line int64
+	var column, str, unit, date, folded, trivia uint64
+	if offset, err = d.varint(); err == nil {
+		if line, err = d.varint(); err == nil {
+			if column, err = d.uvarint(); err == nil {
+				if str, err = d.uvarint(); err == nil {
+					if unit, err = d.uvarint(); err == nil {
+						if date, err = d.uvarint(); err == nil {
+							if folded, err = d.uvarint(); err == nil {
+								trivia, err = d.uvarint()
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if err == nil && (trivia > 0 && depth > 0 || unit > uint64(len(tok.value)) || folded > uint64(len(d.values))) {
+		err = ErrInvalidBinary
+	}
+	if err != nil {
+		d.t.freeToken(tok)
+		return nil, err
+	}
+	d.offset += int(offset)
+	d.line += int(line)
+	tok.offset = d.offset
+	tok.line = d.line
+	tok.column = int(column)
+	tok.unit = int(unit)
+	if folded > 0 {
+		tok.folded = d.values[folded-1]
+	}
+	if str > 0 {
+		if str > uint64(len(d.t.quotes)) {
+			d.t.freeToken(tok)
+			return nil, fmt.Errorf("unknown string definition %d", str-1)
+		}
+		tok.string = d.t.quotes[str-1]
+	}
+	if date > 0 {
+		if date > uint64(len(d.t.dateTimes)) {
+			d.t.freeToken(tok)
+			return nil, fmt.Errorf("unknown date and time definition %d", date-1)
+		}
+		tok.date = d.t.dateTimes[date-1]
+	}
+	for i := uint64(0); i < trivia; i++ {
+		tr, err := d.token(id, depth+1)
+		if err != nil {
+			d.t.freeToken(tok)
+			return nil, err
+		}
+		tok.trivia = append(tok.trivia, tr)
+	}
+	return tok, nil
+}
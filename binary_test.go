@@ -0,0 +1,113 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamBinary(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"<=", "+"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"//"})
+	tokenizer.DefineTrivia(TokenKey(11))
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`)
+	tokenizer.DefineStringToken(TokenKey(15), "'", "'").SetEscapeSymbol('\\')
+	source := "one <=\n 'two' + // x\n one 3.5 // end\n "
+	stream := tokenizer.ParseString(source)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, stream.WriteBinary(buf))
+
+	restored, err := tokenizer.ReadBinary(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, stream.Tokens(), restored.Tokens())
+	require.Equal(t, source, sourceOf(t, restored))
+	require.Equal(t, "'two'", restored.GoTo(2).CurrentToken().ValueString())
+	require.Equal(t, `two`, restored.CurrentToken().ValueUnescapedString())
+	require.Equal(t, 1, restored.CurrentToken().StringSettings().ID())
+	require.Equal(t, stream.GetParsedLength(), restored.GetParsedLength())
+
+	_, err = tokenizer.ReadBinary(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	require.ErrorIs(t, err, ErrInvalidBinary)
+	_, err = tokenizer.ReadBinary(bytes.NewReader([]byte("json")))
+	require.ErrorIs(t, err, ErrInvalidBinary)
+	_, err = New().ReadBinary(bytes.NewReader(buf.Bytes()))
+	require.Error(t, err)
+}
+
+func TestStreamBinaryTyped(t *testing.T) {
+	tokenizer := New().FoldKeywords()
+	tokenizer.DefineUnits(20, []string{"ms"}).Merge(true)
+	tokenizer.DefineDateTimeToken(21, "Jan _2")
+	tokenizer.DefineDateTimeToken(22)
+	stream := tokenizer.ParseString("Timeout 200ms 2024-05-06")
+	defer stream.Close()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, stream.WriteBinary(buf))
+
+	restored, err := tokenizer.ReadBinary(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer restored.Close()
+	require.Equal(t, "timeout", string(restored.CurrentToken().ValueFolded()))
+	tok := restored.GoTo(1).CurrentToken()
+	require.Equal(t, "ms", string(tok.ValueUnit()))
+	require.Equal(t, int64(200), tok.ValueInt())
+	tm, ok := restored.GoNext().CurrentToken().ValueTime()
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), tm)
+	require.Equal(t, TokenKey(22), restored.CurrentToken().Key())
+
+	units := New()
+	units.DefineUnits(20, []string{"ms"}).Merge(true)
+	_, err = units.ReadBinary(bytes.NewReader(buf.Bytes()))
+	require.EqualError(t, err, "unknown date and time definition 1")
+}
+
+func sourceOf(t *testing.T, s *Stream) string {
+	out := bytes.NewBuffer(nil)
+	_, err := s.WriteTo(out)
+	require.NoError(t, err)
+	return out.String()
+}
+
+func TestStreamBinaryHostile(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"//"})
+	tokenizer.DefineTrivia(TokenKey(10))
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, tokenizer.ParseString("one // two\n 3.5 'x' // end").WriteBinary(buf))
+	for i := 0; i < buf.Len(); i++ {
+		_, err := tokenizer.ReadBinary(bytes.NewReader(buf.Bytes()[:i]))
+		require.ErrorIs(t, err, ErrInvalidBinary, "truncated at %d", i)
+	}
+
+	header := func(values ...uint64) []byte {
+		data := append([]byte(nil), binaryMagic...)
+		for _, v := range values {
+			data = binary.AppendUvarint(data, v)
+		}
+		return data
+	}
+	for name, data := range map[string][]byte{
+		"values count": header(1 << 62),
+		"value size":   append(header(1, 1<<40), "abc"...),
+		"tokens count": header(0, 0, 1<<62),
+		"head id":      header(0, 1<<62, 1),
+		// values, head and count, the token (key, value, indent, offset, line, column, string, unit, date, folded, trivia):
+		// the trivia of the token has own trivia
+		"nested trivia": header(1, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+		"unit length":   header(1, 1, 'x', 0, 1, 2, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0),
+		"folded value":  header(1, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0),
+	} {
+		_, err := tokenizer.ReadBinary(bytes.NewReader(data))
+		require.ErrorIs(t, err, ErrInvalidBinary, name)
+	}
+	valid := header(1, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	stream, err := tokenizer.ReadBinary(bytes.NewReader(valid))
+	require.NoError(t, err)
+	require.Len(t, stream.Tokens(), 1)
+}
@@ -0,0 +1,200 @@
+package tokenizer
+
+import "sort"
+
+// Clone returns the copy of the tokenizer with the whole grammar, settings and limits.
+// So the base grammar may be extended per dialect or tenant without mutating the shared base:
+//
+//	dialect := base.Clone()
+//	dialect.DefineTokens(TokenArrow, []string{"=>"})
+//
+// Tokenizers of injections (see StringSettings.AddInjectionWith) are copied too.
+func (t *Tokenizer) Clone() *Tokenizer {
+	return t.clone(map[*Tokenizer]*Tokenizer{})
+}
+
+// clone returns the deep copy of the tokenizer. Tokenizers of injections are copied too,
+// `copies` maps already copied tokenizers to their copies.
+func (t *Tokenizer) clone(copies map[*Tokenizer]*Tokenizer) *Tokenizer {
+	if c, ok := copies[t]; ok {
+		return c
+	}
+	c := New()
+	copies[t] = c
+	c.flags = t.flags
+	c.wSpaces = append([]byte(nil), t.wSpaces...)
+	c.trivia = append([]TokenKey(nil), t.trivia...)
+	c.signOperands = append([]TokenKey(nil), t.signOperands...)
+	c.trailingDot = t.trailingDot
+	c.infNaN = append([][]byte(nil), t.infNaN...)
+	c.thousands = t.thousands
+	c.duration = t.duration
+	c.uuid = t.uuid
+	c.ip, c.cidr = t.ip, t.cidr
+	for _, u := range t.units {
+		c.DefineUnits(u.Key, u.literals()).Merge(u.Merged)
+	}
+	for _, d := range t.dateTimes {
+		c.DefineDateTimeToken(d.Key, d.Layouts...).In(d.Location)
+	}
+	c.invalid = append([]invalidDefinition(nil), t.invalid...)
+	c.logger, c.logLevel, c.limits = t.logger, t.logLevel, t.limits
+	c.hooks = append([]tokenHook(nil), t.hooks...)
+	if t.modes != nil {
+		c.EnableModes()
+		for name := range t.modes {
+			c.modes[name] = true
+		}
+	}
+	refs := map[*tokenRef]*tokenRef{}
+	for key, tks := range t.tokens {
+		c.tokens[key] = make([]*tokenRef, len(tks))
+		for i, ref := range tks {
+			r := *ref
+			c.tokens[key][i] = &r
+			refs[ref] = &r
+		}
+	}
+	for head, tks := range t.index {
+		c.index[head] = make([]*tokenRef, len(tks))
+		for i, ref := range tks {
+			c.index[head][i] = refs[ref]
+		}
+	}
+	for _, q := range t.quotes {
+		cq := q.copy(c)
+		for i, inject := range cq.Injects {
+			if inject.Tokenizer != nil {
+				cq.Injects[i].Tokenizer = inject.Tokenizer.clone(copies)
+			}
+		}
+		c.quotes = append(c.quotes, cq)
+	}
+	if t.names != nil {
+		c.names = make(map[TokenKey]string, len(t.names))
+		for key, name := range t.names {
+			c.names[key] = name
+		}
+	}
+	if t.descriptions != nil {
+		c.descriptions = make(map[TokenKey]string, len(t.descriptions))
+		for key, description := range t.descriptions {
+			c.descriptions[key] = description
+		}
+	}
+	return c
+}
+
+// copy returns the deep copy of the string definition for the tokenizer `t`.
+func (q *StringSettings) copy(t *Tokenizer) *StringSettings {
+	c := *q
+	c.t = t
+	c.StartToken = append([]byte(nil), q.StartToken...)
+	c.EndToken = append([]byte(nil), q.EndToken...)
+	c.Prefix = c.StartToken[:len(q.Prefix)]
+	c.Operands = append([]TokenKey(nil), q.Operands...)
+	if q.SpecSymbols != nil {
+		c.SpecSymbols = make(map[byte]byte, len(q.SpecSymbols))
+		for k, v := range q.SpecSymbols {
+			c.SpecSymbols[k] = v
+		}
+	}
+	c.Injects = append([]QuoteInjectSettings(nil), q.Injects...)
+	return &c
+}
+
+// Merge imports the grammar of `other` into the tokenizer: tokens, strings, trivia keys, names and descriptions of keys.
+// User defined keys of `other` are shifted by `keyOffset`, so reusable grammar fragments with overlapping keys
+// may be combined, like the shared module of numbers and strings plus the module of DSL-specific operators.
+// Flags and modes are combined, whitespaces, the logger and limits of the tokenizer are kept.
+// Tokens of the existing key are replaced (see DefineTokens), strings are added after the existing ones.
+func (t *Tokenizer) Merge(other *Tokenizer, keyOffset TokenKey) *Tokenizer {
+	shift := func(key TokenKey) TokenKey {
+		if key > 0 {
+			return key + keyOffset
+		}
+		return key
+	}
+	t.flags |= other.flags
+	if other.trailingDot != TrailingDotFloat {
+		t.trailingDot = other.trailingDot
+	}
+	for _, spelling := range other.infNaN {
+		t.AllowInfNaN(string(spelling))
+	}
+	if other.thousands != 0 {
+		t.thousands = other.thousands
+	}
+	if other.duration != TokenUndef {
+		t.duration = shift(other.duration)
+	}
+	if other.uuid != TokenUndef {
+		t.uuid = shift(other.uuid)
+	}
+	if other.ip != TokenUndef || other.cidr != TokenUndef {
+		t.ip, t.cidr = shift(other.ip), shift(other.cidr)
+	}
+	for name := range other.modes {
+		t.EnableModes(name)
+	}
+	keys := make([]TokenKey, 0, len(other.tokens))
+	for key := range other.tokens {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		refs := other.tokens[key]
+		literals := make([]string, len(refs))
+		for i, ref := range refs {
+			literals[i] = string(ref.Token)
+		}
+		t.defineTokens(shift(key), literals, false)
+		if len(refs) > 0 {
+			t.copySettings(shift(key), refs[0])
+		}
+	}
+	for _, q := range other.quotes {
+		c := q.copy(t)
+		c.Key = shift(q.Key)
+		c.id = len(t.quotes)
+		for i, key := range c.Operands {
+			c.Operands[i] = shift(key)
+		}
+		for i, inject := range c.Injects {
+			c.Injects[i].StartKey, c.Injects[i].EndKey = shift(inject.StartKey), shift(inject.EndKey)
+			if inject.Tokenizer != nil {
+				sub := New().SetWhiteSpaces(append([]byte(nil), inject.Tokenizer.wSpaces...))
+				c.Injects[i].Tokenizer = sub.Merge(inject.Tokenizer, keyOffset)
+			}
+		}
+		t.quotes = append(t.quotes, c)
+	}
+	for _, key := range other.trivia {
+		t.trivia = append(t.trivia, shift(key))
+	}
+	for _, key := range other.signOperands {
+		t.signOperands = append(t.signOperands, shift(key))
+	}
+	for _, u := range other.units {
+		t.DefineUnits(shift(u.Key), u.literals()).Merge(u.Merged)
+	}
+	for _, d := range other.dateTimes {
+		t.DefineDateTimeToken(shift(d.Key), d.Layouts...).In(d.Location)
+	}
+	for _, def := range other.invalid {
+		t.invalid = append(t.invalid, invalidDefinition{key: shift(def.key), what: def.what})
+	}
+	for key, name := range other.names {
+		if _, ok := t.names[key]; key > 0 || !ok {
+			t.SetKeyName(shift(key), name)
+		}
+	}
+	for key, description := range other.descriptions {
+		if _, ok := t.descriptions[key]; key > 0 || !ok {
+			t.SetKeyDescription(shift(key), description)
+		}
+	}
+	return t
+}
@@ -0,0 +1,357 @@
+// Command tokengen generates Go constants of token keys and the constructor of the tokenizer
+// from the grammar definitions (see tokenizer.Definitions), so key numbering and names stay in sync across a project.
+// Keys are named by the `names` section of the grammar, like `names: {10: COMPARE_OP}`.
+//
+// Usage with go:generate:
+//
+//	//go:generate go run github.com/psyton/tokenizer/cmd/tokengen -o grammar_gen.go grammar.yaml
+//
+// Flags:
+//
+//	-o path   output file, stdout by default
+//	-package  package name, $GOPACKAGE by default
+//	-func     name of the constructor, newTokenizer by default
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	gotoken "go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/psyton/tokenizer"
+)
+
+// constructor methods of flags of the grammar
+var flagMethods = map[string]string{
+	tokenizer.FlagStopOnUnknown:     "StopOnUndefinedToken",
+	tokenizer.FlagKeywordUnderscore: "AllowKeywordUnderscore",
+	tokenizer.FlagNumberInKeyword:   "AllowNumbersInKeyword",
+	tokenizer.FlagNormalizeNFC:      "NormalizeNFC",
+	tokenizer.FlagSignedNumbers:     "AllowSignedNumbers",
+	tokenizer.FlagLeadingDotFloat:   "AllowLeadingDotFloat",
+}
+
+// constants of policies of numbers with the trailing dot
+var trailingDotConstants = map[string]string{
+	tokenizer.TrailingDotFloat.String():   "TrailingDotFloat",
+	tokenizer.TrailingDotInteger.String(): "TrailingDotInteger",
+	tokenizer.TrailingDotReject.String():  "TrailingDotReject",
+}
+
+// names of constants of embedded token keys
+var embeddedKeys = map[tokenizer.TokenKey]string{
+	tokenizer.TokenChar:           "TokenChar",
+	tokenizer.TokenUnknown:        "TokenUnknown",
+	tokenizer.TokenStringFragment: "TokenStringFragment",
+	tokenizer.TokenString:         "TokenString",
+	tokenizer.TokenFloat:          "TokenFloat",
+	tokenizer.TokenInteger:        "TokenInteger",
+	tokenizer.TokenKeyword:        "TokenKeyword",
+	tokenizer.TokenUndef:          "TokenUndef",
+}
+
+func main() {
+	output := flag.String("o", "", "output file, stdout by default")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name")
+	fn := flag.String("func", "newTokenizer", "name of the constructor")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: tokengen [-o output] [-package name] [-func name] grammar")
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *output, *pkg, *fn); err != nil {
+		fmt.Fprintln(os.Stderr, "tokengen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output, pkg, fn string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if pkg == "" {
+		pkg = "main"
+	}
+	src, err := generate(f, filepath.Base(input), pkg, fn)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
+
+// generate returns the formatted Go source of constants and the constructor for the grammar from `r`.
+func generate(r io.Reader, source, pkg, fn string) ([]byte, error) {
+	t := tokenizer.New()
+	if err := t.LoadDefinitions(r); err != nil {
+		return nil, err
+	}
+	defs := t.Definitions()
+	g := generator{names: defs.Names}
+	if err := g.check(defs); err != nil {
+		return nil, err
+	}
+
+	g.printf("// Code generated by tokengen from %s; DO NOT EDIT.\n\n", source)
+	g.printf("package %s\n\n", pkg)
+	g.printf("import \"github.com/psyton/tokenizer\"\n\n")
+	if keys := g.userKeys(); len(keys) > 0 {
+		g.printf("// Token keys of the grammar.\nconst (\n")
+		for _, key := range keys {
+			g.printf("\t%s tokenizer.TokenKey = %d\n", g.names[key], key)
+		}
+		g.printf(")\n\n")
+	}
+
+	g.printf("// %s creates the tokenizer of the grammar.\n", fn)
+	g.printf("func %s() *tokenizer.Tokenizer {\n\tt := tokenizer.New()\n", fn)
+	for _, name := range defs.Flags {
+		g.printf("\tt.%s()\n", flagMethods[name])
+	}
+	if defs.WhiteSpaces != "" {
+		g.printf("\tt.SetWhiteSpaces([]byte(%s))\n", strconv.Quote(defs.WhiteSpaces))
+	}
+	if defs.TrailingDot != "" {
+		g.printf("\tt.SetTrailingDot(tokenizer.%s)\n", trailingDotConstants[defs.TrailingDot])
+	}
+	if defs.ThousandsSeparator != "" {
+		g.printf("\tt.SetThousandsSeparator(%s)\n", strconv.QuoteRune(rune(defs.ThousandsSeparator[0])))
+	}
+	if len(defs.InfNaN) > 0 {
+		g.printf("\tt.AllowInfNaN(%s)\n", strings.TrimSuffix(strings.TrimPrefix(quoteStrings(defs.InfNaN), "[]string{"), "}"))
+	}
+	if len(defs.Modes) > 0 {
+		g.printf("\tt.EnableModes(%s)\n", strings.TrimSuffix(strings.TrimPrefix(quoteStrings(defs.Modes), "[]string{"), "}"))
+	}
+	for _, def := range defs.Tokens {
+		method := "DefineTokens"
+		if def.Full {
+			method = "DefineFullTokens"
+		}
+		g.printf("\tt.%s(%s, %s)", method, g.key(def.Key), quoteStrings(def.Literals))
+		if def.CaseInsensitive {
+			g.printf(".CaseInsensitive()")
+		}
+		if def.WholeWord {
+			g.printf(".WholeWord()")
+		}
+		if def.Mode != "" {
+			g.printf(".Mode(%s)", strconv.Quote(def.Mode))
+		}
+		g.printf("\n")
+		if def.Priority != 0 {
+			g.printf("\tt.SetPriority(%s, %d)\n", g.key(def.Key), def.Priority)
+		}
+	}
+	for _, def := range defs.Strings {
+		if def.Char {
+			// the char literal has the escape symbol and special symbols by default
+			g.printf("\tt.DefineCharToken(%s, %s)", g.key(def.Key), strconv.Quote(def.Start))
+			if def.Escape == "" {
+				g.printf(".\n\t\tSetEscapeSymbol(0)")
+			}
+			if len(def.Special) == 0 {
+				g.printf(".\n\t\tSetSpecialSymbols(nil)")
+			}
+		} else if def.Regex {
+			// the regex literal has the escape symbol by default
+			g.printf("\tt.DefineRegexToken(%s", g.key(def.Key))
+			for _, key := range def.Operands {
+				g.printf(", %s", g.key(key))
+			}
+			g.printf(")")
+			if def.Escape == "" {
+				g.printf(".\n\t\tSetEscapeSymbol(0)")
+			}
+		} else {
+			g.printf("\tt.DefineStringToken(%s, %s, %s)", g.key(def.Key), strconv.Quote(def.Start), strconv.Quote(def.End))
+		}
+		if def.Prefix != "" {
+			g.printf(".\n\t\tSetPrefix(%s)", strconv.Quote(def.Prefix))
+		}
+		if def.Escape != "" {
+			g.printf(".\n\t\tSetEscapeSymbol(%s)", quoteByte(def.Escape))
+		}
+		if len(def.Special) > 0 {
+			g.printf(".\n\t\tSetSpecialSymbols(map[byte]byte{")
+			for i, k := range sortedKeys(def.Special) {
+				if i > 0 {
+					g.printf(", ")
+				}
+				g.printf("%s: %s", quoteByte(k), quoteByte(def.Special[k]))
+			}
+			g.printf("})")
+		}
+		if def.DoubledEscape {
+			g.printf(".\n\t\tEscapeByDoubling()")
+		}
+		for _, inj := range def.Injections {
+			g.printf(".\n\t\tAddInjection(%s, %s)", g.key(inj.StartKey), g.key(inj.EndKey))
+		}
+		if def.DoubledInjection {
+			g.printf(".\n\t\tEscapeInjectionByDoubling()")
+		}
+		if def.SingleLine {
+			g.printf(".\n\t\tAllowNewlines(false)")
+		}
+		if def.KeepQuotes {
+			g.printf(".\n\t\tKeepQuotes(true)")
+		}
+		g.printf("\n")
+	}
+	if len(defs.Trivia) > 0 {
+		keys := make([]string, len(defs.Trivia))
+		for i, key := range defs.Trivia {
+			keys[i] = g.key(key)
+		}
+		g.printf("\tt.DefineTrivia(%s)\n", strings.Join(keys, ", "))
+	}
+	if len(defs.SignOperands) > 0 {
+		keys := make([]string, len(defs.SignOperands))
+		for i, key := range defs.SignOperands {
+			keys[i] = g.key(key)
+		}
+		g.printf("\tt.AllowSignedNumbers(%s)\n", strings.Join(keys, ", "))
+	}
+	if defs.Duration != tokenizer.TokenUndef {
+		g.printf("\tt.DefineDurationToken(%s)\n", g.key(defs.Duration))
+	}
+	if defs.UUID != tokenizer.TokenUndef {
+		g.printf("\tt.DefineUUIDToken(%s)\n", g.key(defs.UUID))
+	}
+	if defs.IP != tokenizer.TokenUndef || defs.CIDR != tokenizer.TokenUndef {
+		g.printf("\tt.DefineIPTokens(%s, %s)\n", g.key(defs.IP), g.key(defs.CIDR))
+	}
+	for _, def := range defs.DateTimes {
+		g.printf("\tt.DefineDateTimeToken(%s", g.key(def.Key))
+		for _, layout := range def.Layouts {
+			g.printf(", %s", strconv.Quote(layout))
+		}
+		g.printf(")\n")
+	}
+	for _, def := range defs.Units {
+		g.printf("\tt.DefineUnits(%s, %s)", g.key(def.Key), quoteStrings(def.Units))
+		if def.Merged {
+			g.printf(".Merge(true)")
+		}
+		g.printf("\n")
+	}
+	for _, key := range sortedTokenKeys(g.names) {
+		g.printf("\tt.SetKeyName(%s, %s)\n", g.key(key), strconv.Quote(g.names[key]))
+	}
+	for _, key := range sortedTokenKeys(defs.Descriptions) {
+		g.printf("\tt.SetKeyDescription(%s, %s)\n", g.key(key), strconv.Quote(defs.Descriptions[key]))
+	}
+	g.printf("\treturn t\n}\n")
+	return format.Source(g.buf.Bytes())
+}
+
+// generator writes the Go source.
+type generator struct {
+	buf   bytes.Buffer
+	names map[tokenizer.TokenKey]string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// check validates that all user defined keys are named by valid identifiers.
+func (g *generator) check(defs tokenizer.Definitions) error {
+	for key, name := range g.names {
+		if key > 0 && !gotoken.IsIdentifier(name) {
+			return fmt.Errorf("name %q of key %d is not a valid Go identifier", name, key)
+		}
+	}
+	keys := append(append([]tokenizer.TokenKey(nil), defs.Trivia...), defs.SignOperands...)
+	for _, def := range defs.Tokens {
+		keys = append(keys, def.Key)
+	}
+	for _, def := range defs.Units {
+		keys = append(keys, def.Key)
+	}
+	for _, def := range defs.DateTimes {
+		keys = append(keys, def.Key)
+	}
+	keys = append(keys, defs.Duration, defs.UUID, defs.IP, defs.CIDR)
+	for _, def := range defs.Strings {
+		keys = append(keys, def.Key)
+		keys = append(keys, def.Operands...)
+		for _, inj := range def.Injections {
+			keys = append(keys, inj.StartKey, inj.EndKey)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := g.names[key]; !ok && key > 0 {
+			return fmt.Errorf("key %d has no name", key)
+		}
+	}
+	return nil
+}
+
+// key returns the Go expression of the token key.
+func (g *generator) key(key tokenizer.TokenKey) string {
+	if name, ok := embeddedKeys[key]; ok {
+		return "tokenizer." + name
+	} else if name, ok := g.names[key]; ok && key > 0 {
+		return name
+	}
+	return "tokenizer.TokenKey(" + strconv.Itoa(int(key)) + ")"
+}
+
+// userKeys returns user defined named keys in ascending order.
+func (g *generator) userKeys() []tokenizer.TokenKey {
+	var keys []tokenizer.TokenKey
+	for _, key := range sortedTokenKeys(g.names) {
+		if key > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func sortedTokenKeys(m map[tokenizer.TokenKey]string) []tokenizer.TokenKey {
+	keys := make([]tokenizer.TokenKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func quoteStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// quoteByte returns the Go literal of the one-byte string.
+func quoteByte(s string) string {
+	return strconv.QuoteRuneToASCII(rune(s[0]))
+}
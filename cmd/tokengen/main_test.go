@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate(strings.NewReader(`
+flags: [keyword_underscore]
+trailing_dot: integer
+inf_nan: [.inf, -.inf, .nan]
+thousands_separator: ","
+modes: [logic]
+tokens:
+  - key: 10
+    literals: ["<=", "=="]
+  - key: 11
+    literals: ["and"]
+    full: true
+    case_insensitive: true
+    whole_word: true
+    mode: logic
+strings:
+  - key: 20
+    start: '"'
+    end: '"'
+    escape: '\'
+    special: {n: "\n"}
+    doubled_escape: true
+    injections: [{start: 10, end: 11}]
+    doubled_injection: true
+    single_line: true
+comments:
+  - key: 21
+    start: "#"
+    end: "\n"
+duration: 32
+uuid: 33
+ip: 34
+date_times:
+  - key: 31
+    layouts: ["2006-01-02", "15:04"]
+units:
+  - key: 30
+    units: [ms, s]
+    merged: true
+names: {10: CompareOp, 11: And, 20: Quoted, 21: Comment, 30: Duration, 31: Date, 32: Elapsed, 33: ID, 34: Addr, -1: Ident}
+descriptions: {10: "a comparison operator"}
+`), "grammar.yaml", "grammar", "newGrammar")
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by tokengen from grammar.yaml; DO NOT EDIT.
+
+package grammar
+
+import "github.com/psyton/tokenizer"
+
+// Token keys of the grammar.
+const (
+	CompareOp tokenizer.TokenKey = 10
+	And       tokenizer.TokenKey = 11
+	Quoted    tokenizer.TokenKey = 20
+	Comment   tokenizer.TokenKey = 21
+	Duration  tokenizer.TokenKey = 30
+	Date      tokenizer.TokenKey = 31
+	Elapsed   tokenizer.TokenKey = 32
+	ID        tokenizer.TokenKey = 33
+	Addr      tokenizer.TokenKey = 34
+)
+
+// newGrammar creates the tokenizer of the grammar.
+func newGrammar() *tokenizer.Tokenizer {
+	t := tokenizer.New()
+	t.AllowKeywordUnderscore()
+	t.SetTrailingDot(tokenizer.TrailingDotInteger)
+	t.SetThousandsSeparator(',')
+	t.AllowInfNaN("-.inf", ".inf", ".nan")
+	t.EnableModes("logic")
+	t.DefineTokens(CompareOp, []string{"<=", "=="})
+	t.DefineFullTokens(And, []string{"and"}).CaseInsensitive().WholeWord().Mode("logic")
+	t.DefineStringToken(Quoted, "\"", "\"").
+		SetEscapeSymbol('\\').
+		SetSpecialSymbols(map[byte]byte{'n': '\n'}).
+		EscapeByDoubling().
+		AddInjection(CompareOp, And).
+		EscapeInjectionByDoubling().
+		AllowNewlines(false)
+	t.DefineStringToken(Comment, "#", "\n")
+	t.DefineTrivia(Comment)
+	t.DefineDurationToken(Elapsed)
+	t.DefineUUIDToken(ID)
+	t.DefineIPTokens(Addr, tokenizer.TokenUndef)
+	t.DefineDateTimeToken(Date, "2006-01-02", "15:04")
+	t.DefineUnits(Duration, []string{"ms", "s"}).Merge(true)
+	t.SetKeyName(tokenizer.TokenKeyword, "Ident")
+	t.SetKeyName(CompareOp, "CompareOp")
+	t.SetKeyName(And, "And")
+	t.SetKeyName(Quoted, "Quoted")
+	t.SetKeyName(Comment, "Comment")
+	t.SetKeyName(Duration, "Duration")
+	t.SetKeyName(Date, "Date")
+	t.SetKeyName(Elapsed, "Elapsed")
+	t.SetKeyName(ID, "ID")
+	t.SetKeyName(Addr, "Addr")
+	t.SetKeyDescription(CompareOp, "a comparison operator")
+	return t
+}
+`, string(src))
+
+	_, err = generate(strings.NewReader(`{"tokens": [{"key": 10, "literals": ["="]}]}`), "g.json", "grammar", "newGrammar")
+	require.EqualError(t, err, "key 10 has no name")
+	_, err = generate(strings.NewReader(`{"names": {"10": "compare-op"}}`), "g.json", "grammar", "newGrammar")
+	require.EqualError(t, err, `name "compare-op" of key 10 is not a valid Go identifier`)
+}
@@ -0,0 +1,119 @@
+// Command tokenize loads the grammar definitions (see tokenizer.Definitions), tokenizes stdin or files
+// and prints tokens as a table, JSON or NDJSON. It helps to debug grammars without writing a Go program.
+//
+// Usage:
+//
+//	tokenize -grammar grammar.yaml [-format table|json|ndjson] [file ...]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/psyton/tokenizer"
+)
+
+// names of embedded token keys if the grammar doesn't name them
+var embeddedNames = map[tokenizer.TokenKey]string{
+	tokenizer.TokenChar:           "char",
+	tokenizer.TokenUnknown:        "unknown",
+	tokenizer.TokenStringFragment: "string_fragment",
+	tokenizer.TokenString:         "string",
+	tokenizer.TokenFloat:          "float",
+	tokenizer.TokenInteger:        "integer",
+	tokenizer.TokenKeyword:        "keyword",
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "tokenize:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("tokenize", flag.ContinueOnError)
+	grammar := flags.String("grammar", "", "grammar definitions file, JSON or YAML")
+	format := flags.String("format", "table", "output format: table, json or ndjson")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *grammar == "" {
+		return errors.New("the grammar file is required")
+	}
+	if *format != "table" && *format != "json" && *format != "ndjson" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	t, err := load(*grammar)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return write(stdout, t, t.ParseStreamNamed("stdin", stdin, 4096), *format)
+	}
+	for _, name := range flags.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err = write(stdout, t, t.ParseBytesNamed(name, data), *format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load creates the tokenizer from the grammar file.
+func load(name string) (*tokenizer.Tokenizer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := tokenizer.New()
+	if err = t.LoadDefinitions(f); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// write writes tokens of the stream in the format, keys are shown by names of the grammar.
+func write(w io.Writer, t *tokenizer.Tokenizer, s *tokenizer.Stream, format string) error {
+	defer s.Close()
+	switch format {
+	case "json":
+		data, err := s.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case "ndjson":
+		return s.WriteNDJSON(w)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPOSITION\tKEY\tVALUE")
+	for ; s.IsValid(); s.GoNext() {
+		tok := s.CurrentToken()
+		key := keyName(t, tok.Key())
+		if tok.IsString() {
+			key += " (" + keyName(t, tok.StringKey()) + ")"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", tok.ID(), s.Position(tok), key, strconv.Quote(tok.ValueString()))
+	}
+	return tw.Flush()
+}
+
+// keyName returns the name of the key in the grammar or the name of the embedded key.
+func keyName(t *tokenizer.Tokenizer, key tokenizer.TokenKey) string {
+	name := t.KeyName(key)
+	if embedded, ok := embeddedNames[key]; ok && name == strconv.Itoa(int(key)) {
+		return embedded
+	}
+	return name
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	grammar := filepath.Join(dir, "grammar.yaml")
+	require.NoError(t, os.WriteFile(grammar, []byte(`
+tokens:
+  - key: 10
+    literals: ["=="]
+strings:
+  - key: 20
+    start: '"'
+    end: '"'
+names: {10: CompareOp, 20: Quoted}
+`), 0o644))
+
+	out := bytes.NewBuffer(nil)
+	require.NoError(t, run([]string{"-grammar", grammar}, strings.NewReader("one == \"two\"\n  3"), out))
+	require.Equal(t, `ID  POSITION   KEY              VALUE
+0   stdin:1:1  keyword          "one"
+1   stdin:1:5  CompareOp        "=="
+2   stdin:1:8  string (Quoted)  "\"two\""
+3   stdin:2:3  integer          "3"
+`, out.String())
+
+	source := filepath.Join(dir, "source.txt")
+	require.NoError(t, os.WriteFile(source, []byte("a == 1"), 0o644))
+	out.Reset()
+	require.NoError(t, run([]string{"-grammar", grammar, "-format", "ndjson", source}, nil, out))
+	require.Equal(t, 3, strings.Count(out.String(), "\n"))
+	require.Contains(t, out.String(), `{"id":1,"key":10,"value":"==","offset":2,"line":1,"column":3}`)
+
+	out.Reset()
+	require.NoError(t, run([]string{"-grammar", grammar, "-format", "json"}, strings.NewReader("a"), out))
+	require.Equal(t, `[{"id":0,"key":-1,"name":"keyword","value":"a","offset":0,"line":1,"column":1}]`+"\n", out.String())
+
+	require.EqualError(t, run([]string{"-grammar", grammar, "-format", "xml"}, nil, out), `unknown format "xml"`)
+	require.EqualError(t, run(nil, nil, out), "the grammar file is required")
+}
@@ -0,0 +1,219 @@
+package tokenizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// invalidDefinition describes the definition which is ignored, see Tokenizer.Compile.
+type invalidDefinition struct {
+	key  TokenKey
+	what string
+}
+
+// CompiledTokenizer is the tokenizer with the validated grammar, see Tokenizer.Compile.
+// The compiled tokenizer is read-only: it has its own copy of the grammar, so changes of the source tokenizer
+// don't affect it. It's safe for concurrent use, any count of goroutines may parse with it at the same time.
+type CompiledTokenizer struct {
+	t *Tokenizer
+}
+
+// Compile validates the grammar of the tokenizer and returns the compiled tokenizer.
+// The error describes all problems of the grammar which silently produce surprising tokens at parse time:
+//   - empty literals of tokens, empty start or end of strings;
+//   - the same literal defined for different keys (the first definition wins, see DefineTokens);
+//   - strings with the same start, the later one is never matched;
+//   - tokens and strings starting with whitespaces, they are never matched;
+//   - strings with the escape symbol equal to the end, they are never closed;
+//   - injections with start or end keys without tokens;
+//   - the keyword underscore flag with tokens starting with the underscore, keywords can't start with it;
+//   - literals not in NFC with the normalization of the input (see NormalizeNFC), they are never matched;
+//   - invalid options of New, like negative limits.
+func (t *Tokenizer) Compile() (*CompiledTokenizer, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return &CompiledTokenizer{t: t.clone(map[*Tokenizer]*Tokenizer{})}, nil
+}
+
+// validate returns errors of the grammar joined together, see Compile.
+func (t *Tokenizer) validate() error {
+	var errs []error
+	for _, def := range t.invalid {
+		if def.key == TokenUndef {
+			errs = append(errs, errors.New(def.what))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s %s", t.describe(def.key), def.what))
+	}
+
+	keys := make([]TokenKey, 0, len(t.tokens))
+	for key := range t.tokens {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	literals := map[string]TokenKey{}
+	for _, key := range keys {
+		for _, ref := range t.tokens[key] {
+			if other, ok := literals[string(ref.Token)]; ok && other != key {
+				errs = append(errs, fmt.Errorf("literal %q is defined for %s and %s", ref.Token, t.describe(other), t.describe(key)))
+			} else if !ok {
+				literals[string(ref.Token)] = key
+			}
+			if t.isWhitespace(ref.Token[0]) {
+				errs = append(errs, fmt.Errorf("literal %q of %s starts with whitespace and is never matched", ref.Token, t.describe(key)))
+			}
+			if t.flags&fNormalizeNFC != 0 && !norm.NFC.IsNormal(ref.Token) {
+				errs = append(errs, fmt.Errorf("literal %q of %s isn't in NFC and is never matched", ref.Token, t.describe(key)))
+			}
+			if ref.Token[0] == '_' && t.flags&fAllowKeywordUnderscore != 0 {
+				errs = append(errs, fmt.Errorf("literal %q of %s conflicts with the keyword underscore flag", ref.Token, t.describe(key)))
+			}
+		}
+	}
+
+	for i, q := range t.quotes {
+		for _, prev := range t.quotes[:i] {
+			if string(prev.StartToken) == string(q.StartToken) && prev.Char == q.Char && prev.Regex == q.Regex {
+				errs = append(errs, fmt.Errorf("string %s with start %q is never matched, the string %s has the same start",
+					t.describe(q.Key), q.StartToken, t.describe(prev.Key)))
+				break
+			}
+		}
+		if t.isWhitespace(q.StartToken[0]) {
+			errs = append(errs, fmt.Errorf("string %s with start %q starts with whitespace and is never matched", t.describe(q.Key), q.StartToken))
+		}
+		if q.EscapeSymbol != 0 && len(q.EndToken) > 0 && q.EscapeSymbol == q.EndToken[0] {
+			errs = append(errs, fmt.Errorf("string %s has the escape symbol %q equal to the end %q and is never closed, use EscapeByDoubling",
+				t.describe(q.Key), q.EscapeSymbol, q.EndToken))
+		}
+		for _, inject := range q.Injects {
+			g := t
+			if inject.Tokenizer != nil {
+				g = inject.Tokenizer
+			}
+			if len(t.tokens[inject.StartKey]) == 0 {
+				errs = append(errs, fmt.Errorf("injection of string %s starts with %s without tokens", t.describe(q.Key), t.describe(inject.StartKey)))
+			}
+			if inject.EndKey != TokenUndef && len(g.tokens[inject.EndKey]) == 0 {
+				errs = append(errs, fmt.Errorf("injection of string %s ends with %s without tokens", t.describe(q.Key), t.describe(inject.EndKey)))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// describe returns the key with its name for errors of the grammar.
+func (t *Tokenizer) describe(key TokenKey) string {
+	if name, ok := t.names[key]; ok {
+		return fmt.Sprintf("key %d (%s)", key, name)
+	}
+	return fmt.Sprintf("key %d", key)
+}
+
+// isWhitespace checks if `b` is the whitespace of the tokenizer.
+func (t *Tokenizer) isWhitespace(b byte) bool {
+	for _, ws := range t.wSpaces {
+		if b == ws {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseString parse the string into tokens, see Tokenizer.ParseString.
+func (c *CompiledTokenizer) ParseString(str string) *Stream {
+	return c.t.ParseString(str)
+}
+
+// ParseBytes parse the bytes slice into tokens, see Tokenizer.ParseBytes.
+func (c *CompiledTokenizer) ParseBytes(str []byte) *Stream {
+	return c.t.ParseBytes(str)
+}
+
+// ParseBytesCopy parse the copy of the bytes slice into tokens, see Tokenizer.ParseBytesCopy.
+func (c *CompiledTokenizer) ParseBytesCopy(data []byte) *Stream {
+	return c.t.ParseBytesCopy(data)
+}
+
+// ParseBytesHint parse the bytes slice into tokens with the expected count of tokens, see Tokenizer.ParseBytesHint.
+func (c *CompiledTokenizer) ParseBytesHint(data []byte, expectedTokens int) *Stream {
+	return c.t.ParseBytesHint(data, expectedTokens)
+}
+
+// ParseBytesPooled parse the bytes slice into tokens of the pooled stream, see Tokenizer.ParseBytesPooled.
+func (c *CompiledTokenizer) ParseBytesPooled(data []byte) *Stream {
+	return c.t.ParseBytesPooled(data)
+}
+
+// ParseBytesArena parse the bytes slice into tokens allocated from the arena, see Tokenizer.ParseBytesArena.
+func (c *CompiledTokenizer) ParseBytesArena(arena Arena, data []byte) *Stream {
+	return c.t.ParseBytesArena(arena, data)
+}
+
+// ParseStreamWith parse the data from the reader into tokens with options, see Tokenizer.ParseStreamWith.
+func (c *CompiledTokenizer) ParseStreamWith(r io.Reader, opts StreamOptions) *Stream {
+	return c.t.ParseStreamWith(r, opts)
+}
+
+// ParseFile parse the file into tokens, see Tokenizer.ParseFile.
+func (c *CompiledTokenizer) ParseFile(path string) (*Stream, error) {
+	return c.t.ParseFile(path)
+}
+
+// ParseBytesParallel parse the bytes slice into tokens on many goroutines, see Tokenizer.ParseBytesParallel.
+func (c *CompiledTokenizer) ParseBytesParallel(data []byte, workers int) *Stream {
+	return c.t.ParseBytesParallel(data, workers)
+}
+
+// Count returns the count of tokens of the data, see Tokenizer.Count.
+func (c *CompiledTokenizer) Count(data []byte) (int, error) {
+	return c.t.Count(data)
+}
+
+// ParseStream parse the string into tokens, see Tokenizer.ParseStream.
+func (c *CompiledTokenizer) ParseStream(r io.Reader, bufferSize uint) *Stream {
+	return c.t.ParseStream(r, bufferSize)
+}
+
+// ParseBytesCtx parse the bytes slice into tokens, see Tokenizer.ParseBytesCtx.
+func (c *CompiledTokenizer) ParseBytesCtx(ctx context.Context, data []byte) (*Stream, error) {
+	return c.t.ParseBytesCtx(ctx, data)
+}
+
+// ParseReaderCtx parse the data from the reader into tokens, see Tokenizer.ParseReaderCtx.
+func (c *CompiledTokenizer) ParseReaderCtx(ctx context.Context, r io.Reader, bufferSize uint) *Stream {
+	return c.t.ParseReaderCtx(ctx, r, bufferSize)
+}
+
+// ParseBytesNamed parse the bytes slice of the file `name` into tokens, see Tokenizer.ParseBytesNamed.
+func (c *CompiledTokenizer) ParseBytesNamed(name string, data []byte) *Stream {
+	return c.t.ParseBytesNamed(name, data)
+}
+
+// ParseStreamNamed parse the data of the file `name` into tokens, see Tokenizer.ParseStreamNamed.
+func (c *CompiledTokenizer) ParseStreamNamed(name string, r io.Reader, bufferSize uint) *Stream {
+	return c.t.ParseStreamNamed(name, r, bufferSize)
+}
+
+// KeyName returns the name of the token key, see Tokenizer.KeyName.
+func (c *CompiledTokenizer) KeyName(key TokenKey) string {
+	return c.t.KeyName(key)
+}
+
+// KeyDescription returns the display string of the token key, see Tokenizer.KeyDescription.
+func (c *CompiledTokenizer) KeyDescription(key TokenKey) string {
+	return c.t.KeyDescription(key)
+}
+
+// Definitions returns the grammar of the tokenizer, see Tokenizer.Definitions.
+func (c *CompiledTokenizer) Definitions() Definitions {
+	return c.t.Definitions()
+}
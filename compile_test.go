@@ -0,0 +1,95 @@
+package tokenizer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tokenizer := New().AllowKeywordUnderscore()
+	tokenizer.DefineTokens(10, []string{"=", ""})
+	tokenizer.DefineTokens(11, []string{"=", " +", "_"})
+	tokenizer.DefineStringToken(20, `"`, `"`).AddInjection(12, 11)
+	tokenizer.DefineStringToken(21, `"`, `"`)
+	tokenizer.DefineStringToken(22, "'", "")
+	tokenizer.DefineStringToken(23, "|", "|").SetEscapeSymbol('|')
+	tokenizer.SetKeyName(10, "ASSIGN")
+
+	_, err := tokenizer.Compile()
+	require.EqualError(t, err, `key 10 (ASSIGN) tokens have the empty literal
+key 22 string has the empty end
+literal "=" is defined for key 10 (ASSIGN) and key 11
+literal " +" of key 11 starts with whitespace and is never matched
+literal "_" of key 11 conflicts with the keyword underscore flag
+injection of string key 20 starts with key 12 without tokens
+string key 21 with start "\"" is never matched, the string key 20 has the same start
+string key 23 has the escape symbol '|' equal to the end "|" and is never closed, use EscapeByDoubling`)
+
+	tokenizer = New()
+	tokenizer.DefineTokens(10, []string{"{{"})
+	tokenizer.DefineTokens(11, []string{"}}"})
+	tokenizer.DefineStringToken(20, `"`, `"`).AddInjection(10, 11)
+	tokenizer.DefineCharToken(21, `"`)
+	compiled, err := tokenizer.Compile()
+	require.NoError(t, err)
+	stream := compiled.ParseString(`"a{{b}}"`)
+	defer stream.Close()
+	require.Equal(t, TokenKey(20), stream.CurrentToken().StringKey())
+	require.Equal(t, "b", stream.GoTo(2).CurrentToken().ValueString())
+}
+
+func TestInvalidStringIgnored(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineStringToken(20, "#", "")
+	tokenizer.DefineStringToken(21, "", "#")
+
+	stream := tokenizer.ParseString("a # b")
+	defer stream.Close()
+	var keys []TokenKey
+	for ; stream.IsValid(); stream.GoNext() {
+		keys = append(keys, stream.CurrentToken().Key())
+	}
+	require.Equal(t, []TokenKey{TokenKeyword, TokenUnknown, TokenKeyword}, keys)
+}
+
+func TestCompiledConcurrent(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"{{"})
+	tokenizer.DefineTokens(11, []string{"}}"})
+	tokenizer.DefineTokens(12, []string{"+", "-"})
+	tokenizer.DefineStringToken(20, `"`, `"`).SetEscapeSymbol(BackSlash).AddInjection(10, 11)
+	compiled, err := tokenizer.Compile()
+	require.NoError(t, err)
+
+	// changes of the source don't affect the compiled tokenizer
+	tokenizer.DefineTokens(12, []string{"*"})
+	tokenizer.SetWhiteSpaces([]byte{'\n'})
+
+	const expected = `[{"id":0,"key":-5,"name":"string_fragment","value":"\"a ","offset":0,"line":1,"column":1,"string":0},` +
+		`{"id":1,"key":10,"value":"{{","offset":3,"line":1,"column":4},` +
+		`{"id":2,"key":-1,"name":"keyword","value":"b","offset":6,"line":1,"column":7},` +
+		`{"id":3,"key":12,"value":"+","offset":8,"line":1,"column":9},` +
+		`{"id":4,"key":-2,"name":"integer","value":"1","offset":10,"line":1,"column":11},` +
+		`{"id":5,"key":11,"value":"}}","offset":12,"line":1,"column":13},` +
+		`{"id":6,"key":-5,"name":"string_fragment","value":"\\\"\"","offset":14,"line":1,"column":15,"string":0}]`
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			var err error
+			for j := 0; j < 100 && err == nil; j++ {
+				stream := compiled.ParseString(`"a {{ b + 1 }}\""`)
+				var data []byte
+				if data, err = stream.MarshalJSON(); err == nil && string(data) != expected {
+					err = fmt.Errorf("unexpected tokens %s", data)
+				}
+				stream.Close()
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		require.NoError(t, <-done)
+	}
+}
@@ -0,0 +1,239 @@
+package tokenizer
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultDateTimeLayouts are layouts of DefineDateTimeToken by default: RFC3339 and the date with the time
+// separated by the space (both with optional fractional seconds) and the date.
+var DefaultDateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// dateTimeSlack is how many bytes the literal may be longer than the layout,
+// like fractional seconds which aren't in the layout or long names of months.
+const dateTimeSlack = 10
+
+// DateTimeSettings describes date and time literals, see Tokenizer.DefineDateTimeToken.
+type DateTimeSettings struct {
+	*Tokenizer
+	// Key is the key of literals.
+	Key TokenKey
+	// Layouts are layouts of literals in the format of time.Parse.
+	Layouts []string
+	// Location of literals without the time zone, see In.
+	Location *time.Location
+	// fixed prefixes of literals of layouts, see dateTimeShape
+	shapes [][]byte
+}
+
+// In sets the location of literals without the time zone, UTC by default (see time.ParseInLocation).
+func (d *DateTimeSettings) In(loc *time.Location) *DateTimeSettings {
+	d.Location = loc
+	return d
+}
+
+func (d *DateTimeSettings) location() *time.Location {
+	if d.Location == nil {
+		return time.UTC
+	}
+	return d.Location
+}
+
+// DefineDateTimeToken defines date and time literals in the format of time.Parse, like `2021-10-06 12:30:44`
+// or RFC3339 `2021-10-06T12:30:44Z`, DefaultDateTimeLayouts are used if layouts are empty.
+// The literal is the longest prefix of data which is parsed by any of layouts and isn't followed by the letter,
+// digit or underscore. Literals take precedence over numbers, keywords and tokens. Token.ValueTime returns the time.
+// If key already exists layouts will be rewritten.
+// At each position matching the beginning of the layout (up to the first element of the variable length, like `Jan`,
+// `_2` or `3`) prefixes of data are parsed by time.ParseInLocation from the longest one, so layouts which start with
+// such elements, like time.Kitchen, are tried at more positions and make tokenizing slower.
+func (t *Tokenizer) DefineDateTimeToken(key TokenKey, layouts ...string) *DateTimeSettings {
+	if len(layouts) == 0 {
+		layouts = DefaultDateTimeLayouts
+	}
+	d := &DateTimeSettings{Tokenizer: t, Key: key}
+	if key < 1 {
+		return d
+	}
+	for i, other := range t.dateTimes {
+		if other.Key == key {
+			t.dateTimes = append(t.dateTimes[:i:i], t.dateTimes[i+1:]...)
+			break
+		}
+	}
+	for _, layout := range layouts {
+		if layout == "" {
+			t.invalid = append(t.invalid, invalidDefinition{key: key, what: "date and time literal has the empty layout"})
+			continue
+		}
+		d.Layouts = append(d.Layouts, layout)
+		d.shapes = append(d.shapes, dateTimeShape(layout))
+	}
+	t.dateTimes = append(t.dateTimes, d)
+	return d
+}
+
+// markers of the last byte of the shape of the layout, see dateTimeShape
+const (
+	shapeDay     = '\x01' // `_2` or `__2`: the space or the digit
+	shapeWeekday = '\x02' // `Mon` or `Monday`: the name of the week day
+	shapeMonth   = '\x03' // `Jan` or `January`: the name of the month
+	shapeNoon    = '\x04' // `PM` or `pm`: AM or PM
+	shapeZone    = '\x05' // `MST`, `Z07` or `-07`: the letter or the sign of the time zone
+)
+
+// lower case abbreviations of names of week days and months, see shaped
+const (
+	shortWeekdays = "sunmontuewedthufrisat"
+	shortMonths   = "janfebmaraprmayjunjulaugsepoctnovdec"
+)
+
+// dateTimeShape returns the fixed prefix of literals of the layout to skip layouts quickly: `0` is any digit,
+// other bytes are literal. The prefix ends at the first element of the variable length, like `Jan`, `_2`,
+// the number without the padding or the time zone, which is kept as the marker of its first bytes.
+func dateTimeShape(layout string) []byte {
+	var shape []byte
+	for i := 0; i < len(layout); {
+		switch c, rest := layout[i], layout[i:]; {
+		case isNumberByte(c):
+			j := i
+			for j < len(layout) && isNumberByte(layout[j]) {
+				j++
+			}
+			if j-i < 2 {
+				return append(shape, '0') // numbers without the padding, like `1` or `2`
+			}
+			for ; i < j; i++ {
+				shape = append(shape, '0')
+			}
+			continue
+		case strings.HasPrefix(rest, "_2006"):
+			shape = append(shape, c)
+		case strings.HasPrefix(rest, "_2"), strings.HasPrefix(rest, "__2"):
+			return append(shape, shapeDay)
+		case strings.HasPrefix(rest, "Mon"):
+			return append(shape, shapeWeekday)
+		case strings.HasPrefix(rest, "Jan"):
+			return append(shape, shapeMonth)
+		case strings.HasPrefix(rest, "PM"), strings.HasPrefix(rest, "pm"):
+			return append(shape, shapeNoon)
+		case strings.HasPrefix(rest, "MST"), strings.HasPrefix(rest, "Z07"), strings.HasPrefix(rest, "-07"):
+			return append(shape, shapeZone)
+		case isLetterByte(c) || c == ' ' || c == '-' || c == ':' || c == '/':
+			shape = append(shape, c)
+		default:
+			return shape
+		}
+		i++
+	}
+	return shape
+}
+
+// shaped checks if data at the current position matches the fixed prefix of literals, see dateTimeShape.
+func (p *parsing) shaped(shape []byte) bool {
+	if len(shape) > 0 && !p.ensureBytes(len(shape)-1) {
+		return false
+	}
+	for i, c := range shape {
+		b := p.str[p.pos+i]
+		switch c {
+		case '0':
+			if !isNumberByte(b) {
+				return false
+			}
+		case shapeDay:
+			return b == ' ' || isNumberByte(b)
+		case shapeWeekday:
+			return p.named(i, shortWeekdays)
+		case shapeMonth:
+			return p.named(i, shortMonths)
+		case shapeNoon:
+			return (b|0x20 == 'a' || b|0x20 == 'p') && p.ensureBytes(i+1) && p.str[p.pos+i+1]|0x20 == 'm'
+		case shapeZone:
+			return b == '+' || b == '-' || isLetterByte(b)
+		default:
+			if b != c {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// named checks if data at the offset from the current position starts with any of three letters names
+// in any case (like time.Parse).
+func (p *parsing) named(offset int, names string) bool {
+	if !p.ensureBytes(offset + 2) {
+		return false
+	}
+	name := p.str[p.pos+offset : p.pos+offset+3]
+	for i := 0; i < len(names); i += 3 {
+		if name[0]|0x20 == names[i] && name[1]|0x20 == names[i+1] && name[2]|0x20 == names[i+2] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDateTime parses the date and time literal at the current position, the longest literal of all layouts wins,
+// see Tokenizer.DefineDateTimeToken.
+func (p *parsing) parseDateTime() bool {
+	var found *DateTimeSettings
+	var length int
+	for _, d := range p.g.dateTimes {
+		for i, layout := range d.Layouts {
+			if !p.shaped(d.shapes[i]) {
+				continue
+			}
+			n := len(layout) + dateTimeSlack
+			p.ensureBytes(n)
+			if n > len(p.str)-p.pos {
+				n = len(p.str) - p.pos
+			}
+			for ; n > length && n >= len(d.shapes[i]); n-- {
+				if p.isWordRune(n) {
+					continue
+				}
+				if _, err := time.ParseInLocation(layout, b2s(p.str[p.pos:p.pos+n]), d.location()); err == nil {
+					found, length = d, n
+					break
+				}
+			}
+		}
+	}
+	if found == nil {
+		return false
+	}
+	start := p.pos
+	p.pos += length - 1
+	p.next()
+	p.token.key = found.Key
+	p.token.value = p.str[start:p.pos]
+	p.token.offset = p.offset + start
+	p.token.date = found
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "date and time %q (%s)", p.token.value, p.g.KeyName(found.Key))
+	}
+	p.emmitToken()
+	return true
+}
+
+// ValueTime returns the time of the date and time literal, see Tokenizer.DefineDateTimeToken.
+// False is returned if the token isn't the date and time literal.
+func (t *Token) ValueTime() (time.Time, bool) {
+	if t.date == nil {
+		return time.Time{}, false
+	}
+	for _, layout := range t.date.Layouts {
+		// the zone name of the result may refer to the value, so the value is copied
+		if tm, err := time.ParseInLocation(layout, string(t.value), t.date.location()); err == nil {
+			return tm, true
+		}
+	}
+	return time.Time{}, false
+}
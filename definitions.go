@@ -0,0 +1,457 @@
+package tokenizer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Flag names of the grammar definitions.
+const (
+	FlagStopOnUnknown     = "stop_on_unknown"
+	FlagKeywordUnderscore = "keyword_underscore"
+	FlagNumberInKeyword   = "number_in_keyword"
+	FlagNormalizeNFC      = "normalize_nfc"
+	FlagSignedNumbers     = "signed_numbers"
+	FlagLeadingDotFloat   = "leading_dot_float"
+)
+
+var flagNames = []struct {
+	name string
+	flag uint16
+}{
+	{FlagStopOnUnknown, fStopOnUnknown},
+	{FlagKeywordUnderscore, fAllowKeywordUnderscore},
+	{FlagNumberInKeyword, fAllowNumberInKeyword},
+	{FlagNormalizeNFC, fNormalizeNFC},
+	{FlagSignedNumbers, fSignedNumbers},
+	{FlagLeadingDotFloat, fLeadingDotFloat},
+}
+
+// Definitions describes the grammar of the tokenizer as data.
+type Definitions struct {
+	// Flags are names of enabled behaviors, see FlagStopOnUnknown, FlagKeywordUnderscore, FlagNumberInKeyword, FlagNormalizeNFC, FlagSignedNumbers and FlagLeadingDotFloat.
+	Flags []string `json:"flags,omitempty" yaml:"flags,omitempty"`
+	// WhiteSpaces are whitespace symbols between tokens, default whitespaces are used if empty (see SetWhiteSpaces).
+	WhiteSpaces string `json:"whitespaces,omitempty" yaml:"whitespaces,omitempty"`
+	// Modes are names of enabled modes of tokens, see EnableModes.
+	Modes []string `json:"modes,omitempty" yaml:"modes,omitempty"`
+	// Tokens are user defined tokens (see DefineTokens and DefineFullTokens).
+	Tokens []TokenDefinition `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	// Strings are framed strings in definition order (see DefineStringToken).
+	Strings []StringDefinition `json:"strings,omitempty" yaml:"strings,omitempty"`
+	// Comments are framed strings which are trivia as well, they are defined after Strings.
+	Comments []StringDefinition `json:"comments,omitempty" yaml:"comments,omitempty"`
+	// Trivia are keys of trivia tokens (see DefineTrivia).
+	Trivia []TokenKey `json:"trivia,omitempty" yaml:"trivia,omitempty"`
+	// SignOperands are keys of tokens after which the sign isn't absorbed into the number, see AllowSignedNumbers.
+	SignOperands []TokenKey `json:"sign_operands,omitempty" yaml:"sign_operands,omitempty"`
+	// TrailingDot is the policy of numbers with the trailing dot: "float" (default), "integer" or "reject",
+	// see SetTrailingDot.
+	TrailingDot string `json:"trailing_dot,omitempty" yaml:"trailing_dot,omitempty"`
+	// InfNaN are spellings of infinity and not-a-number floats, see AllowInfNaN.
+	InfNaN []string `json:"inf_nan,omitempty" yaml:"inf_nan,omitempty"`
+	// ThousandsSeparator is the one-byte separator of thousands in numbers, see SetThousandsSeparator.
+	ThousandsSeparator string `json:"thousands_separator,omitempty" yaml:"thousands_separator,omitempty"`
+	// Units are unit suffixes of numbers in definition order, see DefineUnits.
+	Units []UnitDefinition `json:"units,omitempty" yaml:"units,omitempty"`
+	// Duration is the key of duration literals, see DefineDurationToken.
+	Duration TokenKey `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// UUID is the key of UUIDs, see DefineUUIDToken.
+	UUID TokenKey `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	// IP and CIDR are keys of IP addresses and CIDR ranges, see DefineIPTokens.
+	IP   TokenKey `json:"ip,omitempty" yaml:"ip,omitempty"`
+	CIDR TokenKey `json:"cidr,omitempty" yaml:"cidr,omitempty"`
+	// DateTimes are date and time literals in definition order, see DefineDateTimeToken.
+	// Locations of literals aren't described, they are UTC.
+	DateTimes []DateTimeDefinition `json:"date_times,omitempty" yaml:"date_times,omitempty"`
+	// Names are names of token keys (see SetKeyName).
+	Names map[TokenKey]string `json:"names,omitempty" yaml:"names,omitempty"`
+	// Descriptions are display strings of token keys for error messages (see SetKeyDescription).
+	Descriptions map[TokenKey]string `json:"descriptions,omitempty" yaml:"descriptions,omitempty"`
+}
+
+// TokenDefinition describes user defined tokens with the same key.
+type TokenDefinition struct {
+	Key      TokenKey `json:"key" yaml:"key"`
+	Literals []string `json:"literals" yaml:"literals"`
+	// Full requires that tokens must be surrounded by whitespaces (see DefineFullTokens).
+	Full bool `json:"full,omitempty" yaml:"full,omitempty"`
+	// Priority of tokens, see SetPriority.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// CaseInsensitive tokens are matched ignoring the case, see TokenSettings.CaseInsensitive.
+	CaseInsensitive bool `json:"case_insensitive,omitempty" yaml:"case_insensitive,omitempty"`
+	// WholeWord tokens aren't matched as parts of words, see TokenSettings.WholeWord.
+	WholeWord bool `json:"whole_word,omitempty" yaml:"whole_word,omitempty"`
+	// Mode is the name of the mode of tokens, see TokenSettings.Mode.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// UnitDefinition describes unit suffixes of numbers with the same key.
+type UnitDefinition struct {
+	Key   TokenKey `json:"key" yaml:"key"`
+	Units []string `json:"units" yaml:"units"`
+	// Merged units are the part of the number token, see UnitSettings.Merge.
+	Merged bool `json:"merged,omitempty" yaml:"merged,omitempty"`
+}
+
+// DateTimeDefinition describes date and time literals with the same key.
+type DateTimeDefinition struct {
+	Key TokenKey `json:"key" yaml:"key"`
+	// Layouts are in the format of time.Parse, DefaultDateTimeLayouts are used if empty.
+	Layouts []string `json:"layouts,omitempty" yaml:"layouts,omitempty"`
+}
+
+// StringDefinition describes the framed string.
+type StringDefinition struct {
+	Key   TokenKey `json:"key" yaml:"key"`
+	Start string   `json:"start" yaml:"start"`
+	End   string   `json:"end" yaml:"end"`
+	// Char means the char literal (see Tokenizer.DefineCharToken), End is the same as Start.
+	Char bool `json:"char,omitempty" yaml:"char,omitempty"`
+	// Regex means the regex literal (see Tokenizer.DefineRegexToken) which isn't expected after Operands.
+	Regex    bool       `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Operands []TokenKey `json:"operands,omitempty" yaml:"operands,omitempty"`
+	// Prefix is the literal prefix of the start, see StringSettings.SetPrefix.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Escape is the escape symbol, see StringSettings.SetEscapeSymbol.
+	Escape string `json:"escape,omitempty" yaml:"escape,omitempty"`
+	// Special maps escaped symbols to their values, see StringSettings.SetSpecialSymbols.
+	Special map[string]string `json:"special,omitempty" yaml:"special,omitempty"`
+	// DoubledEscape allows the end token escaped by doubling, see StringSettings.EscapeByDoubling.
+	DoubledEscape bool `json:"doubled_escape,omitempty" yaml:"doubled_escape,omitempty"`
+	// DoubledInjection allows start tokens of injections escaped by doubling,
+	// see StringSettings.EscapeInjectionByDoubling.
+	DoubledInjection bool `json:"doubled_injection,omitempty" yaml:"doubled_injection,omitempty"`
+	// Injections are keys of tokens which open and close injections, see StringSettings.AddInjection.
+	Injections []QuoteInjectSettings `json:"injections,omitempty" yaml:"injections,omitempty"`
+	// SingleLine forbids new lines in the string, see StringSettings.AllowNewlines.
+	SingleLine bool `json:"single_line,omitempty" yaml:"single_line,omitempty"`
+	// KeepQuotes keeps quotes in unescaped values, see StringSettings.KeepQuotes.
+	KeepQuotes bool `json:"keep_quotes,omitempty" yaml:"keep_quotes,omitempty"`
+}
+
+// LoadDefinitions reads the grammar of the tokenizer from the JSON or YAML document (see Definitions)
+// and defines tokens, strings, comments and flags in addition to already defined ones.
+// So grammars can be shipped as data and edited without recompiling the program.
+func (t *Tokenizer) LoadDefinitions(r io.Reader) error {
+	var defs Definitions
+	br := bufio.NewReader(r)
+	if isJSON(br) {
+		dec := json.NewDecoder(br)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&defs); err != nil {
+			return fmt.Errorf("invalid definitions: %w", err)
+		}
+	} else {
+		dec := yaml.NewDecoder(br)
+		dec.KnownFields(true)
+		if err := dec.Decode(&defs); err != nil && err != io.EOF {
+			return fmt.Errorf("invalid definitions: %w", err)
+		}
+	}
+	return t.define(defs)
+}
+
+// isJSON checks if the document starts with the JSON object.
+func isJSON(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{':
+			return true
+		}
+		return false
+	}
+}
+
+// define validates and applies the definitions to the tokenizer.
+func (t *Tokenizer) define(defs Definitions) error {
+	var flags uint16
+	for _, name := range defs.Flags {
+		found := false
+		for _, f := range flagNames {
+			if f.name == name {
+				flags |= f.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+	}
+	trailingDot := TrailingDotFloat
+	if defs.TrailingDot != "" {
+		for trailingDot = TrailingDotFloat; trailingDot <= TrailingDotReject; trailingDot++ {
+			if trailingDot.String() == defs.TrailingDot {
+				break
+			}
+		}
+		if trailingDot > TrailingDotReject {
+			return fmt.Errorf("unknown trailing dot policy %q", defs.TrailingDot)
+		}
+	}
+	for _, spelling := range defs.InfNaN {
+		if _, ok := infNaN([]byte(spelling)); !ok {
+			return fmt.Errorf("spelling %q is neither infinity nor NaN", spelling)
+		}
+	}
+	if defs.Duration < 0 {
+		return fmt.Errorf("invalid duration key %d", defs.Duration)
+	}
+	if defs.UUID < 0 {
+		return fmt.Errorf("invalid uuid key %d", defs.UUID)
+	}
+	if defs.IP < 0 || defs.CIDR < 0 {
+		return fmt.Errorf("invalid ip key %d or cidr key %d", defs.IP, defs.CIDR)
+	}
+	for _, def := range defs.DateTimes {
+		if def.Key < 1 {
+			return fmt.Errorf("invalid date and time key %d", def.Key)
+		}
+		for _, layout := range def.Layouts {
+			if layout == "" {
+				return fmt.Errorf("empty layout of date and time key %d", def.Key)
+			}
+		}
+	}
+	if sep := defs.ThousandsSeparator; sep != "" && (len(sep) != 1 || !validThousands(sep[0])) {
+		return fmt.Errorf("invalid thousands separator %q", sep)
+	}
+	for _, def := range defs.Units {
+		if def.Key < 1 {
+			return fmt.Errorf("invalid unit key %d", def.Key)
+		}
+		for _, unit := range def.Units {
+			if unit == "" || isNumberByte(unit[0]) {
+				return fmt.Errorf("unit %q of key %d is empty or starts with the digit", unit, def.Key)
+			}
+		}
+	}
+	for _, def := range defs.Tokens {
+		if def.Key < 1 {
+			return fmt.Errorf("invalid token key %d", def.Key)
+		}
+		for _, literal := range def.Literals {
+			if literal == "" {
+				return fmt.Errorf("empty literal of token key %d", def.Key)
+			}
+		}
+	}
+	strs := append(append([]StringDefinition{}, defs.Strings...), defs.Comments...)
+	for _, def := range strs {
+		if def.Start == "" {
+			return fmt.Errorf("empty start of string key %d", def.Key)
+		}
+		if len(def.Escape) > 1 {
+			return fmt.Errorf("escape of string key %d should be one byte", def.Key)
+		}
+		for k, v := range def.Special {
+			if len(k) != 1 || len(v) != 1 {
+				return fmt.Errorf("special symbols of string key %d should be one byte", def.Key)
+			}
+		}
+	}
+
+	t.flags |= flags
+	if defs.TrailingDot != "" {
+		t.trailingDot = trailingDot
+	}
+	if len(defs.InfNaN) > 0 {
+		t.AllowInfNaN(defs.InfNaN...)
+	}
+	if defs.ThousandsSeparator != "" {
+		t.SetThousandsSeparator(defs.ThousandsSeparator[0])
+	}
+	for _, def := range defs.Units {
+		t.DefineUnits(def.Key, def.Units).Merge(def.Merged)
+	}
+	for _, def := range defs.DateTimes {
+		t.DefineDateTimeToken(def.Key, def.Layouts...)
+	}
+	if defs.Duration != TokenUndef {
+		t.DefineDurationToken(defs.Duration)
+	}
+	if defs.UUID != TokenUndef {
+		t.DefineUUIDToken(defs.UUID)
+	}
+	if defs.IP != TokenUndef || defs.CIDR != TokenUndef {
+		t.DefineIPTokens(defs.IP, defs.CIDR)
+	}
+	if defs.WhiteSpaces != "" {
+		t.SetWhiteSpaces([]byte(defs.WhiteSpaces))
+	}
+	t.EnableModes(defs.Modes...)
+	for _, def := range defs.Tokens {
+		var s *TokenSettings
+		if def.Full {
+			s = t.DefineFullTokens(def.Key, def.Literals)
+		} else {
+			s = t.DefineTokens(def.Key, def.Literals)
+		}
+		if def.CaseInsensitive {
+			s.CaseInsensitive()
+		}
+		if def.WholeWord {
+			s.WholeWord()
+		}
+		s.Mode(def.Mode)
+		if def.Priority != 0 {
+			t.SetPriority(def.Key, def.Priority)
+		}
+	}
+	for _, def := range strs {
+		q := t.DefineStringToken(def.Key, def.Start, def.End)
+		q.Char = def.Char
+		q.Regex, q.Operands = def.Regex, def.Operands
+		q.AllowNewlines(!def.SingleLine).KeepQuotes(def.KeepQuotes)
+		if def.Prefix != "" {
+			q.SetPrefix(def.Prefix)
+		}
+		if def.Escape != "" {
+			q.SetEscapeSymbol(def.Escape[0])
+		}
+		if len(def.Special) > 0 {
+			special := make(map[byte]byte, len(def.Special))
+			for k, v := range def.Special {
+				special[k[0]] = v[0]
+			}
+			q.SetSpecialSymbols(special)
+		}
+		if def.DoubledEscape {
+			q.EscapeByDoubling()
+		}
+		if def.DoubledInjection {
+			q.EscapeInjectionByDoubling()
+		}
+		for _, inj := range def.Injections {
+			q.AddInjection(inj.StartKey, inj.EndKey)
+		}
+	}
+	for _, def := range defs.Comments {
+		t.DefineTrivia(def.Key)
+	}
+	t.DefineTrivia(defs.Trivia...)
+	t.signOperands = append(t.signOperands, defs.SignOperands...)
+	for key, name := range defs.Names {
+		t.SetKeyName(key, name)
+	}
+	for key, description := range defs.Descriptions {
+		t.SetKeyDescription(key, description)
+	}
+	return nil
+}
+
+// Definitions returns the current grammar of the tokenizer: flags, whitespaces, user defined tokens ordered by keys,
+// framed strings in definition order, trivia keys, names and descriptions of keys.
+// Comments are returned as Strings and Trivia. The result may be loaded into another tokenizer (see LoadDefinitions).
+func (t *Tokenizer) Definitions() Definitions {
+	var defs Definitions
+	for _, f := range flagNames {
+		if t.flags&f.flag != 0 {
+			defs.Flags = append(defs.Flags, f.name)
+		}
+	}
+	if string(t.wSpaces) != string(defaultWhiteSpaces) {
+		defs.WhiteSpaces = string(t.wSpaces)
+	}
+	for name := range t.modes {
+		defs.Modes = append(defs.Modes, name)
+	}
+	sort.Strings(defs.Modes)
+	keys := make([]TokenKey, 0, len(t.tokens))
+	for key := range t.tokens {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		def := TokenDefinition{Key: key, Literals: []string{}}
+		for _, ref := range t.tokens[key] {
+			def.Literals = append(def.Literals, string(ref.Token))
+			def.Full = ref.IsFull
+			def.Priority = ref.Priority
+			def.CaseInsensitive, def.WholeWord, def.Mode = ref.Fold, ref.Word, ref.Mode
+		}
+		defs.Tokens = append(defs.Tokens, def)
+	}
+	for _, q := range t.quotes {
+		def := StringDefinition{
+			Key:        q.Key,
+			Start:      string(q.StartToken[len(q.Prefix):]),
+			Prefix:     string(q.Prefix),
+			Char:       q.Char,
+			Regex:      q.Regex,
+			Operands:   append([]TokenKey(nil), q.Operands...),
+			End:        string(q.EndToken),
+			Injections: append([]QuoteInjectSettings(nil), q.Injects...),
+			SingleLine: q.SingleLine,
+			KeepQuotes: q.Quoted,
+		}
+		if q.EscapeSymbol != 0 {
+			def.Escape = string([]byte{q.EscapeSymbol})
+		}
+		def.DoubledEscape, def.DoubledInjection = q.DoubledEscape, q.DoubledInjection
+		if len(q.SpecSymbols) > 0 {
+			def.Special = make(map[string]string, len(q.SpecSymbols))
+			for k, v := range q.SpecSymbols {
+				def.Special[string([]byte{k})] = string([]byte{v})
+			}
+		}
+		defs.Strings = append(defs.Strings, def)
+	}
+	defs.Trivia = append(defs.Trivia, t.trivia...)
+	defs.SignOperands = append(defs.SignOperands, t.signOperands...)
+	if t.trailingDot != TrailingDotFloat {
+		defs.TrailingDot = t.trailingDot.String()
+	}
+	for _, spelling := range t.infNaN {
+		defs.InfNaN = append(defs.InfNaN, string(spelling))
+	}
+	if t.thousands != 0 {
+		defs.ThousandsSeparator = string([]byte{t.thousands})
+	}
+	for _, u := range t.units {
+		defs.Units = append(defs.Units, UnitDefinition{Key: u.Key, Units: u.literals(), Merged: u.Merged})
+	}
+	defs.Duration = t.duration
+	defs.UUID = t.uuid
+	defs.IP, defs.CIDR = t.ip, t.cidr
+	for _, d := range t.dateTimes {
+		defs.DateTimes = append(defs.DateTimes, DateTimeDefinition{Key: d.Key, Layouts: append([]string(nil), d.Layouts...)})
+	}
+	if len(t.names) > 0 {
+		defs.Names = make(map[TokenKey]string, len(t.names))
+		for key, name := range t.names {
+			defs.Names[key] = name
+		}
+	}
+	if len(t.descriptions) > 0 {
+		defs.Descriptions = make(map[TokenKey]string, len(t.descriptions))
+		for key, description := range t.descriptions {
+			defs.Descriptions[key] = description
+		}
+	}
+	return defs
+}
+
+// Fingerprint returns the hash of the grammar of the tokenizer (see Definitions).
+// Tokenizers with identical grammars have the same fingerprint.
+func (t *Tokenizer) Fingerprint() string {
+	data, _ := json.Marshal(t.Definitions())
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
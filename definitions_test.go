@@ -0,0 +1,150 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDefinitions(t *testing.T) {
+	yamlDefs := `
+flags: [keyword_underscore]
+tokens:
+  - key: 10
+    literals: ["<=", "=", "{{"]
+  - key: 11
+    literals: ["}}"]
+  - key: 12
+    literals: ["and"]
+    full: true
+strings:
+  - key: 20
+    start: '"'
+    end: '"'
+    escape: '\'
+    special: {n: "\n"}
+    injections:
+      - {start: 10, end: 11}
+comments:
+  - key: 21
+    start: "#"
+    end: "\n"
+names: {10: COMPARE_OP}
+`
+	jsonDefs := `{
+	"flags": ["keyword_underscore"],
+	"tokens": [
+		{"key": 10, "literals": ["<=", "=", "{{"]},
+		{"key": 11, "literals": ["}}"]},
+		{"key": 12, "literals": ["and"], "full": true}
+	],
+	"strings": [
+		{"key": 20, "start": "\"", "end": "\"", "escape": "\\", "special": {"n": "\n"}, "injections": [{"start": 10, "end": 11}]}
+	],
+	"comments": [{"key": 21, "start": "#", "end": "\n"}],
+	"names": {"10": "COMPARE_OP"}
+}`
+	for name, defs := range map[string]string{"yaml": yamlDefs, "json": jsonDefs} {
+		t.Run(name, func(t *testing.T) {
+			tokenizer := New()
+			require.NoError(t, tokenizer.LoadDefinitions(strings.NewReader(defs)))
+			stream := tokenizer.ParseString("# note\nuser_id <= 1 and \"a\\n{{b}}\"")
+			require.Equal(t, "user_id", stream.CurrentToken().ValueString())
+			require.Equal(t, []Trivia{{Key: TokenKey(21), Value: []byte("# note\n"), Line: 1}}, stream.CurrentToken().Trivia())
+			require.Equal(t, TokenKey(10), stream.GoNext().CurrentToken().Key())
+			require.Equal(t, TokenKey(12), stream.GoNext().GoNext().CurrentToken().Key())
+			tok := stream.GoNext().CurrentToken()
+			require.Equal(t, TokenStringFragment, tok.Key())
+			require.Equal(t, TokenKey(20), tok.StringKey())
+			require.Equal(t, "a\n", tok.ValueUnescapedString())
+			require.Equal(t, TokenKey(10), stream.GoNext().CurrentToken().Key())
+			require.Equal(t, "COMPARE_OP", tokenizer.KeyName(10))
+		})
+	}
+
+	for _, defs := range []string{
+		`flags: [unknown]`,
+		`trailing_dot: ignore`,
+		`inf_nan: [infinite]`,
+		`units: [{key: 0, units: [ms]}]`,
+		`thousands_separator: "."`,
+		`date_times: [{key: 1, layouts: [""]}]`,
+		`duration: -1`,
+		`uuid: -1`,
+		`cidr: -1`,
+		`thousands_separator: ", "`,
+		`units: [{key: 1, units: [1s]}]`,
+		`{"tokens": [{"key": 0, "literals": ["+"]}]}`,
+		`tokens: [{key: 1, literals: [""]}]`,
+		`strings: [{key: 1, start: "", end: "'"}]`,
+		`strings: [{key: 1, start: "'", end: "'", escape: "ab"}]`,
+		`symbols: []`,
+		`{"tokens": []`,
+	} {
+		require.Error(t, New().LoadDefinitions(strings.NewReader(defs)), defs)
+	}
+}
+
+func TestTokenizerDefinitions(t *testing.T) {
+	tokenizer := New().AllowKeywordUnderscore().SetWhiteSpaces([]byte{' ', '\n'}).SetTrailingDot(TrailingDotInteger).AllowInfNaN().
+		SetThousandsSeparator(' ')
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineTokens(TokenKey(10), []string{"<=", "{{"})
+	tokenizer.DefineFullTokens(TokenKey(12), []string{"and"}).CaseInsensitive().Mode("logic").EnableModes("logic")
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`).SetEscapeSymbol(BackSlash).
+		SetSpecialSymbols(map[byte]byte{'n': '\n'}).AddInjection(10, 11).EscapeInjectionByDoubling()
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`).SetPrefix("r").AllowNewlines(false).KeepQuotes(true).
+		EscapeByDoubling()
+	tokenizer.DefineStringToken(TokenKey(21), "#", "\n")
+	tokenizer.DefineTrivia(TokenKey(21))
+	tokenizer.DefineUnits(TokenKey(30), []string{"s", "ms"}).Merge(true)
+	tokenizer.DefineDateTimeToken(TokenKey(31), "2006-01-02")
+	tokenizer.DefineDurationToken(TokenKey(32)).DefineUUIDToken(TokenKey(33)).DefineIPTokens(34, 35)
+	tokenizer.SetKeyName(TokenKey(10), "COMPARE_OP").SetKeyDescription(TokenKey(10), "a comparison operator")
+
+	defs := tokenizer.Definitions()
+	require.Equal(t, Definitions{
+		Flags:       []string{FlagKeywordUnderscore},
+		WhiteSpaces: " \n",
+		Modes:       []string{"logic"},
+		Tokens: []TokenDefinition{
+			{Key: 10, Literals: []string{"<=", "{{"}},
+			{Key: 11, Literals: []string{"}}"}},
+			{Key: 12, Literals: []string{"and"}, Full: true, CaseInsensitive: true, Mode: "logic"},
+		},
+		Strings: []StringDefinition{
+			{Key: 20, Start: `"`, End: `"`, Escape: `\`, Special: map[string]string{"n": "\n"},
+				Injections: []QuoteInjectSettings{{StartKey: 10, EndKey: 11}}, DoubledInjection: true},
+			{Key: 20, Start: `"`, End: `"`, Prefix: "r", SingleLine: true, KeepQuotes: true, DoubledEscape: true},
+			{Key: 21, Start: "#", End: "\n"},
+		},
+		Trivia:             []TokenKey{21},
+		TrailingDot:        "integer",
+		InfNaN:             []string{"+Inf", "-Inf", "Inf", "NaN"},
+		ThousandsSeparator: " ",
+		Units:              []UnitDefinition{{Key: 30, Units: []string{"ms", "s"}, Merged: true}},
+		Duration:           32,
+		UUID:               33,
+		IP:                 34,
+		CIDR:               35,
+		DateTimes:          []DateTimeDefinition{{Key: 31, Layouts: []string{"2006-01-02"}}},
+		Names:              map[TokenKey]string{10: "COMPARE_OP"},
+		Descriptions:       map[TokenKey]string{10: "a comparison operator"},
+	}, defs)
+
+	other := New()
+	require.NotEqual(t, tokenizer.Fingerprint(), other.Fingerprint())
+	require.NoError(t, other.define(defs))
+	require.Equal(t, defs, other.Definitions())
+	require.Equal(t, tokenizer.Fingerprint(), other.Fingerprint())
+
+	doubled, plain := New(), New()
+	doubled.DefineStringToken(TokenKey(20), "'", "'").EscapeByDoubling()
+	plain.DefineStringToken(TokenKey(20), "'", "'")
+	require.NotEqual(t, doubled.Fingerprint(), plain.Fingerprint())
+	doubled, plain = New(), New()
+	doubled.DefineStringToken(TokenKey(20), `"`, `"`).AddInjection(10, 11).EscapeInjectionByDoubling()
+	plain.DefineStringToken(TokenKey(20), `"`, `"`).AddInjection(10, 11)
+	require.NotEqual(t, doubled.Fingerprint(), plain.Fingerprint())
+}
@@ -0,0 +1,73 @@
+package tokenizer
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Divergence describes the first pair of different tokens of two streams, see Diff.
+type Divergence struct {
+	// Left is the copy of the token of the first stream.
+	// If the stream is ended the token is TokenUndef token positioned at the end of the last token.
+	Left Token
+	// Right is the copy of the token of the second stream, like Left.
+	Right Token
+	// LeftPosition is the position of the Left token with the file name of the first stream.
+	LeftPosition Position
+	// RightPosition is the position of the Right token with the file name of the second stream.
+	RightPosition Position
+}
+
+func (d *Divergence) String() string {
+	return fmt.Sprintf("%s: %s != %s: %s", d.LeftPosition, describeToken(&d.Left), d.RightPosition, describeToken(&d.Right))
+}
+
+// describeToken returns the quoted value of the token or "end of stream".
+func describeToken(t *Token) string {
+	if !t.IsValid() {
+		return "end of stream"
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// Diff compares tokens of streams from head tokens by keys (strings by string keys) and values
+// and returns the first divergence or nil if streams have the same sequence of tokens,
+// like the check that the minified or refactored source keeps the logical token sequence.
+// Indents are compared too if `indents` is true. Trivia tokens are not compared.
+// In the streaming mode all data will be parsed, the history should not be limited (see SetHistorySize).
+// Pointers of streams stay unchanged.
+func Diff(a, b *Stream, indents bool) *Divergence {
+	left, right := a.head, b.head
+	for ; isToken(left) && isToken(right); left, right = a.nextOf(left), b.nextOf(right) {
+		if !sameTokens(left, right, indents) {
+			break
+		}
+	}
+	if !isToken(left) && !isToken(right) {
+		return nil
+	}
+	d := &Divergence{}
+	if isToken(left) {
+		d.Left = left.copy()
+	} else {
+		d.Left = a.endToken()
+	}
+	if isToken(right) {
+		d.Right = right.copy()
+	} else {
+		d.Right = b.endToken()
+	}
+	d.LeftPosition, d.RightPosition = a.Position(&d.Left), b.Position(&d.Right)
+	return d
+}
+
+// isToken checks if the pointer is the valid token of the stream.
+func isToken(t *Token) bool {
+	return t != nil && t.IsValid()
+}
+
+// sameTokens checks if tokens have the same key, string key and value.
+func sameTokens(a, b *Token, indents bool) bool {
+	return a.key == b.key && a.StringKey() == b.StringKey() && bytes.Equal(a.value, b.value) &&
+		(!indents || bytes.Equal(a.indent, b.indent))
+}
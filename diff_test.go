@@ -0,0 +1,38 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, `"`, `"`)
+	tokenizer.DefineStringToken(21, `'`, `'`)
+
+	parse := func(name, str string) *Stream {
+		return tokenizer.ParseBytesNamed(name, []byte(str))
+	}
+	a, b := parse("a", `x = "one"`), parse("b", "x=\n\"one\"")
+	defer a.Close()
+	defer b.Close()
+	require.Nil(t, Diff(a, b, false))
+	require.Equal(t, "a:1:3: \"=\" != b:1:2: \"=\"", Diff(a, b, true).String())
+
+	c := parse("c", `x = 'one'`)
+	defer c.Close()
+	d := Diff(a, c, false)
+	require.NotNil(t, d)
+	require.Equal(t, 2, d.Left.ID())
+	require.Equal(t, TokenKey(20), d.Left.StringKey())
+	require.Equal(t, Position{File: "c", Line: 1, Column: 5, Offset: 4}, d.RightPosition)
+
+	e := parse("e", "x =\n")
+	defer e.Close()
+	d = Diff(a, e, false)
+	require.False(t, d.Right.IsValid())
+	require.Equal(t, "a:1:5: \"\\\"one\\\"\" != e:1:4: end of stream", d.String())
+	require.Nil(t, Diff(parse("", ""), parse("", " "), true))
+}
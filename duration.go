@@ -0,0 +1,90 @@
+package tokenizer
+
+import (
+	"time"
+)
+
+// DefineDurationToken defines Go duration literals with the key `key`, like `250ms`, `1.5h` or `1h30m`
+// (see time.ParseDuration). The literal isn't matched if the letter, digit or underscore follows it, so `5min` is
+// the number and the keyword. The sign isn't the part of the literal. Literals take precedence over numbers
+// and units, see DefineUnits. Token.ValueDuration returns the duration. TokenUndef disables literals.
+func (t *Tokenizer) DefineDurationToken(key TokenKey) *Tokenizer {
+	if key < 1 {
+		key = TokenUndef
+	}
+	t.duration = key
+	return t
+}
+
+// parseDuration parses the duration literal at the current position, see Tokenizer.DefineDurationToken.
+func (p *parsing) parseDuration() bool {
+	if !isNumberByte(p.curr) {
+		return false
+	}
+	n := 0
+	for {
+		n = p.skipDurationDigits(n)
+		if p.ensureBytes(n) && p.str[p.pos+n] == '.' {
+			n = p.skipDurationDigits(n + 1)
+		}
+		unit := p.durationUnit(n)
+		if unit == 0 {
+			return false
+		}
+		n += unit
+		if !p.ensureBytes(n) || !isNumberByte(p.str[p.pos+n]) && p.str[p.pos+n] != '.' {
+			break
+		}
+	}
+	if p.isWordRune(n) {
+		return false
+	}
+	if _, err := time.ParseDuration(b2s(p.str[p.pos : p.pos+n])); err != nil {
+		return false // like the overflow
+	}
+	start := p.pos
+	p.pos += n - 1
+	p.next()
+	p.token.key = p.g.duration
+	p.token.value = p.str[start:p.pos]
+	p.token.offset = p.offset + start
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "duration %q (%s)", p.token.value, p.g.KeyName(p.token.key))
+	}
+	p.emmitToken()
+	return true
+}
+
+// skipDurationDigits returns the offset after digits which start `n` bytes after the current position.
+func (p *parsing) skipDurationDigits(n int) int {
+	for p.ensureBytes(n) && isNumberByte(p.str[p.pos+n]) {
+		n++
+	}
+	return n
+}
+
+// durationUnits are units of durations, longer units first, see time.ParseDuration.
+var durationUnits = []string{"µs", "μs", "ns", "us", "ms", "h", "m", "s"}
+
+// durationUnit returns the length of the unit of durations `n` bytes after the current position, or zero.
+func (p *parsing) durationUnit(n int) int {
+	for _, unit := range durationUnits {
+		if !p.ensureBytes(n + len(unit) - 1) {
+			continue
+		}
+		if b2s(p.str[p.pos+n:p.pos+n+len(unit)]) == unit {
+			return len(unit)
+		}
+	}
+	return 0
+}
+
+// ValueDuration returns the duration of the duration literal (see Tokenizer.DefineDurationToken) or of the number
+// with the merged unit like `200ms` (see UnitSettings.Merge). False is returned if the value isn't the duration.
+func (t *Token) ValueDuration() (time.Duration, bool) {
+	if t.key == TokenInteger || t.key == TokenFloat {
+		return 0, false // `0` is the duration
+	}
+	d, err := time.ParseDuration(b2s(t.value))
+	return d, err == nil
+}
@@ -0,0 +1,164 @@
+package tokenizer
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Minify writes the source reconstructed from tokens in the minimal form to the writer.
+// Indents are collapsed to a single space where it's required to keep the same tokens and dropped elsewhere.
+// Tokens with `drop` keys are dropped, string tokens are matched by the string key,
+// so comments defined via DefineStringToken may be dropped too.
+// Tokens separated by whitespaces are re-lexed by the tokenizer to check if they may be joined,
+// so hooks of the tokenizer are called for them too (see Tokenizer.OnToken).
+// Tokens are written from the head token like by WriteTo (see Stream.WriteTo). The pointer stays unchanged.
+func (s *Stream) Minify(w io.Writer, drop ...TokenKey) (int64, error) {
+	var tokens []*Token
+	var spaced []bool // there are whitespaces or dropped tokens before the token
+	gap := false
+	s.each(func(ptr *Token) {
+		if ptr.hasAnyKey(drop) {
+			gap = true
+			return
+		}
+		tokens = append(tokens, ptr)
+		spaced = append(spaced, gap || len(ptr.indent) > 0)
+		gap = false
+	})
+	out := writer{w: w}
+	run := 0 // index of the first token written without the separator before the current one
+	for i, ptr := range tokens {
+		if i > 0 && spaced[i] {
+			end := i + 1
+			for end < len(tokens) && end-i <= minifyContext && !spaced[end] {
+				end++
+			}
+			if s.t.needsSeparator(tokens[run:i-1], tokens[i-1], ptr, tokens[i+1:end]) {
+				out.writeString(" ")
+				run = i
+			}
+		}
+		if i-run > minifyContext {
+			run = i - minifyContext
+		}
+		out.write(ptr.value)
+	}
+	return out.n, out.err
+}
+
+// minifyContext is the max count of tokens written without whitespaces around the pair of tokens
+// which are re-lexed with the pair by Minify, like tokens of the date 2021-10-06 or the IPv6 address.
+const minifyContext = 16
+
+// Format writes the source reconstructed from tokens with canonical spacing to the writer:
+// tokens are separated by one space and the new line is written after tokens with `breakAfter` keys
+// (string tokens are matched by the string key), like the primitive formatter driven by token keys.
+// Trivia tokens (comments) are written as tokens, the new line is kept after tokens ending with the new line.
+// Fragments of framed strings are not separated from tokens of injections, so values of strings stay unchanged.
+// Tokens are written from the head token like by WriteTo (see Stream.WriteTo). The pointer stays unchanged.
+func (s *Stream) Format(w io.Writer, breakAfter ...TokenKey) (int64, error) {
+	out := writer{w: w}
+	lineStart := true
+	var prev *Token
+	s.each(func(ptr *Token) {
+		if !lineStart && !injected(prev, ptr) {
+			out.writeString(" ")
+		}
+		out.write(ptr.value)
+		prev = ptr
+		n := len(ptr.value)
+		lineStart = n > 0 && ptr.value[n-1] == newLine
+		if !lineStart && ptr.hasAnyKey(breakAfter) {
+			out.writeString("\n")
+			lineStart = true
+		}
+	})
+	return out.n, out.err
+}
+
+// injected checks if tokens `prev` and `next` are the string fragment and the start token of the injection
+// or the end token of the injection and the string fragment.
+func injected(prev, next *Token) bool {
+	if prev.key == TokenStringFragment && prev.string != nil {
+		for _, inj := range prev.string.Injects {
+			if next.key == inj.StartKey {
+				return true
+			}
+		}
+	}
+	if next.key == TokenStringFragment && next.string != nil && prev.key != TokenStringFragment {
+		for _, inj := range next.string.Injects {
+			if inj.EndKey == TokenUndef || prev.key == inj.EndKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// needsSeparator checks if tokens `prev` and `next` written without whitespaces between would be parsed differently.
+// Tokens are re-lexed together with tokens `before` and `after` which are written without whitespaces
+// around them too, like `1` and `000` of `1 ,000` with the thousands separator.
+// Fragments of framed strings are re-lexed as the empty string of the same definition,
+// fragments around injections are never separated. Words are always separated without re-lexing.
+func (t *Tokenizer) needsSeparator(before []*Token, prev, next *Token, after []*Token) bool {
+	if len(prev.value) == 0 || len(next.value) == 0 {
+		return false
+	}
+	if injected(prev, next) {
+		return false
+	}
+	last, _ := utf8.DecodeLastRune(prev.value)
+	first, _ := utf8.DecodeRune(next.value)
+	if isWordRune(last) && isWordRune(first) {
+		return true
+	}
+	window := make([]*Token, 0, len(before)+len(after)+2)
+	for i := len(before); prev.key != TokenStringFragment && i > 0; i-- {
+		if before[i-1].key == TokenStringFragment {
+			before = before[i:]
+			break
+		}
+	}
+	if prev.key != TokenStringFragment {
+		window = append(window, before...)
+	}
+	window = append(window, relexed(prev), relexed(next))
+	for _, tok := range after {
+		if next.key == TokenStringFragment || tok.key == TokenStringFragment {
+			break
+		}
+		window = append(window, tok)
+	}
+	var str []byte
+	for _, tok := range window {
+		str = append(str, tok.value...)
+	}
+	stream := t.ParseBytes(str)
+	defer stream.Close()
+	i := 0
+	same := true
+	stream.each(func(tok *Token) {
+		same = same && i < len(window) && sameTokens(tok, window[i], false)
+		i++
+	})
+	return !same || i != len(window)
+}
+
+// isWordRune checks if the rune may be a part of keyword or number.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || r >= utf8.RuneSelf
+}
+
+// relexed returns the token as it's re-lexed by needsSeparator: fragments of framed strings are replaced
+// by the empty string of the same definition.
+func relexed(tok *Token) *Token {
+	if tok.key != TokenStringFragment || tok.string == nil {
+		return tok
+	}
+	q := tok.string
+	value := make([]byte, 0, len(q.StartToken)+len(q.EndToken))
+	value = append(append(value, q.StartToken...), q.EndToken...)
+	return &Token{key: TokenString, value: value, string: q}
+}
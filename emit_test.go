@@ -0,0 +1,123 @@
+package tokenizer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamMinify(t *testing.T) {
+	compareKey := TokenKey(10)
+	commentKey := TokenKey(15)
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{">=", ">", "=", "{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineTokens(TokenKey(12), []string{"(", ")", ","})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).AddInjection(10, 11)
+	tokenizer.DefineStringToken(commentKey, "//", "\n")
+
+	for str, expected := range map[string]string{
+		"":                                    "",
+		"  one  ":                             "one",
+		"one two\n\t3 4.5":                    "one two 3 4.5",
+		"f ( a , b ) > = 1 . 5":               "f(a,b)> =1 .5",
+		"a // comment\nb":                     "a b",
+		"a// comment\n(b)":                    "a(b)",
+		`x = "one {{ two  ( three ) }} four"`: `x="one {{two(three)}} four"`,
+		"один  два":                           "один два",
+		"a > > b":                             "a>>b",
+	} {
+		out := bytes.NewBuffer(nil)
+		n, err := tokenizer.ParseString(str).Minify(out, commentKey)
+		require.NoError(t, err)
+		require.Equal(t, expected, out.String(), "minify %q", str)
+		require.Equal(t, int64(out.Len()), n)
+	}
+
+	out := bytes.NewBuffer(nil)
+	_, err := tokenizer.ParseString("a >= b // comment\n").Minify(out, compareKey)
+	require.NoError(t, err)
+	require.Equal(t, "a b// comment\n", out.String())
+}
+
+func TestStreamFormat(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"=", "{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineTokens(TokenKey(12), []string{";"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).AddInjection(10, 11)
+	tokenizer.DefineStringToken(TokenKey(15), "//", "\n")
+	tokenizer.DefineTrivia(TokenKey(15))
+
+	for str, expected := range map[string]string{
+		"":                          "",
+		"  a=1;b  =\n\n 2 ;":        "a = 1 ;\nb = 2 ;\n",
+		"a=1// note\nb=2":           "a = 1 // note\nb = 2",
+		`x="one {{y}} two";`:        "x = \"one {{ y }} two\" ;\n",
+		"// head\n\n a=1;// tail\n": "// head\na = 1 ;\n// tail\n",
+	} {
+		out := bytes.NewBuffer(nil)
+		n, err := tokenizer.ParseString(str).Format(out, TokenKey(12))
+		require.NoError(t, err)
+		require.Equal(t, expected, out.String(), "format %q", str)
+		require.Equal(t, int64(out.Len()), n)
+	}
+}
+
+func TestStreamMinifyRoundTrip(t *testing.T) {
+	ops := func(tokenizer *Tokenizer) *Tokenizer {
+		tokenizer.DefineTokens(10, []string{"-", "+", ".", ",", "=", "/", ":", "{{"})
+		tokenizer.DefineTokens(11, []string{"(", "}}"})
+		tokenizer.DefineTokens(12, []string{")"})
+		return tokenizer
+	}
+	strs := ops(New())
+	strs.DefineStringToken(20, `"`, `"`).SetEscapeSymbol(BackSlash).AddInjection(10, 11)
+	strs.DefineStringToken(21, "`", "`").SetPrefix("r")
+	strs.DefineCharToken(22, "'")
+	doubled := ops(New())
+	doubled.DefineStringToken(20, "'", "'").EscapeByDoubling()
+	regex := ops(New())
+	regex.DefineRegexToken(20, 12)
+	units := ops(New())
+	units.DefineUnits(20, []string{"ms", "s"}).Merge(true)
+	dates := ops(New())
+	dates.DefineDateTimeToken(20)
+	durations := ops(New()).DefineDurationToken(20)
+	uuids := ops(New()).DefineUUIDToken(20)
+	ips := ops(New()).DefineIPTokens(20, 21)
+
+	for _, c := range []struct {
+		tokenizer *Tokenizer
+		source    string
+		expected  string
+	}{
+		{ops(New()), "1 . 5 1 .5 x . 5 1 , 000 - 1", "1 .5 1 .5 x.5 1,000-1"},
+		{ops(New()).AllowSignedNumbers(12), "( - 5 ) - 1 x - 2 - - 3 + 4", "(- 5)-1 x-2-- 3+4"},
+		{ops(New()).AllowLeadingDotFloat(), "a . 5 1 . 5 . 5e1 x . y", "a. 5 1 . 5 . 5e1 x.y"},
+		{ops(New()).AllowInfNaN("inf", "-inf", "nan"), "x - inf - nan ( - inf )", "x-inf-nan(- inf)"},
+		{ops(New()).AllowInfNaN().AllowSignedNumbers(12), "x = - Inf + NaN", "x=- Inf+NaN"},
+		{ops(New()).SetThousandsSeparator(','), "f ( 1 ,000 ) 1 , 234 , 567 12 ,34", "f(1 ,000)1, 234, 567 12,34"},
+		{ops(New()).SetThousandsSeparator(' '), "1 000 12, 345", "1 000 12,345"},
+		{ops(New()).SetTrailingDot(TrailingDotInteger), "1 . . 5 1 .. 5", "1..5 1..5"},
+		{strs, `r "x" r ` + "`y`" + ` "a {{ b }} c" "d" ( "e" ) 'f' 'g' x '\n' "\"" "h"`, `r"x"r ` + "`y`" + `"a {{b}} c""d"("e")'f''g'x'\n'"\"""h"`},
+		{doubled, "'a' 'b' 'it''s' ( 'c' ) ''", "'a' 'b' 'it''s'('c')''"},
+		{regex, "( /a/ ) / 2 , /b/g x / y", "(/a/)/2,/b/g x/y"},
+		{units, "5 ms 1.5 s x ms", "5 ms 1.5 s x ms"},
+		{dates, "2021-10-06 12:30:44 2021 - 10 - 06", "2021-10-06 12:30:44 2021-10- 06"},
+		{durations, "1 h 30 m 1h 30m", "1 h 30 m 1h 30m"},
+		{uuids, "550e8400 - e29b - 41d4 - a716 - 446655440000", "550e8400-e29b-41d4-a716- 446655440000"},
+		{ips, "10 . 0 . 0 . 1 / 8 2001 : db8 : : 1", "10 .0 .0 .1/8 2001:db8: :1"},
+	} {
+		src := c.tokenizer.ParseString(c.source)
+		out := bytes.NewBuffer(nil)
+		_, err := src.Minify(out)
+		require.NoError(t, err)
+		require.Equal(t, c.expected, out.String(), "minify %q", c.source)
+		min := c.tokenizer.ParseString(out.String())
+		require.Nil(t, Diff(src, min, false), "minify %q to %q", c.source, out.String())
+		src.Close()
+		min.Close()
+	}
+}
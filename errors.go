@@ -0,0 +1,135 @@
+package tokenizer
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnexpectedTokenError describes the token which doesn't match expected token keys.
+type UnexpectedTokenError struct {
+	// Expected token keys.
+	Expected []TokenKey
+	// Token is the copy of the unexpected token.
+	// If the stream is ended the token is TokenUndef token positioned at the end of the last token.
+	Token Token
+	// Position of the unexpected token with the file name of the stream (see Tokenizer.ParseBytesNamed).
+	Position Position
+	// tokenizer of the stream for names of keys
+	t *Tokenizer
+}
+
+func (e *UnexpectedTokenError) Error() string {
+	if e.described() {
+		return e.message()
+	}
+	expected := make([]string, len(e.Expected))
+	for i, key := range e.Expected {
+		expected[i] = e.keyName(key)
+	}
+	where := "on line " + strconv.Itoa(e.Token.line)
+	if e.Position.File != "" {
+		where = "at " + e.Position.String()
+	}
+	if !e.Token.IsValid() {
+		return fmt.Sprintf("expected %s, got end of stream %s", strings.Join(expected, " or "), where)
+	}
+	return fmt.Sprintf("expected %s, got %s %q %s", strings.Join(expected, " or "), e.keyName(e.Token.key), e.Token.value, where)
+}
+
+// described checks if any of the expected keys has the description, see Tokenizer.SetKeyDescription.
+func (e *UnexpectedTokenError) described() bool {
+	if e.t == nil {
+		return false
+	}
+	for _, key := range e.Expected {
+		if _, ok := e.t.descriptions[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// message returns the user-facing message with descriptions of expected keys.
+func (e *UnexpectedTokenError) message() string {
+	expected := make([]string, len(e.Expected))
+	for i, key := range e.Expected {
+		expected[i] = e.t.KeyDescription(key)
+	}
+	found := "end of input"
+	if e.Token.IsValid() {
+		found = strconv.Quote(e.Token.ValueString())
+	}
+	return fmt.Sprintf("expected %s, found %s at %s", strings.Join(expected, " or "), found, e.Position)
+}
+
+// keyName returns the name of the key, see Tokenizer.SetKeyName.
+func (e *UnexpectedTokenError) keyName(key TokenKey) string {
+	if e.t == nil {
+		return strconv.Itoa(int(key))
+	}
+	return e.t.KeyName(key)
+}
+
+// UnterminatedInjectionError describes the injection in the framed string which isn't closed
+// before the end of data, like `"one {{ two"`. Parsing stops on the error, see Stream.Err.
+type UnterminatedInjectionError struct {
+	// Token is the copy of the start token of the injection.
+	Token Token
+	// Position of the start token of the injection.
+	Position Position
+}
+
+func (e *UnterminatedInjectionError) Error() string {
+	return fmt.Sprintf("%s: injection opened by %q isn't closed", e.Position, e.Token.value)
+}
+
+// TrailingDotError describes the number with the trailing dot, like `2.`, if the policy is TrailingDotReject
+// (see Tokenizer.SetTrailingDot). Parsing stops on the error, see Stream.Err.
+type TrailingDotError struct {
+	// Token is the copy of the number with the trailing dot.
+	Token Token
+	// Position of the number.
+	Position Position
+}
+
+func (e *TrailingDotError) Error() string {
+	return fmt.Sprintf("%s: number %q has the trailing dot", e.Position, e.Token.value)
+}
+
+// unexpected creates the error for the token `t` of the stream.
+func (s *Stream) unexpected(t *Token, keys ...TokenKey) *UnexpectedTokenError {
+	err := &UnexpectedTokenError{
+		Expected: keys,
+		t:        s.t,
+	}
+	if t.IsValid() {
+		err.Token = t.copy()
+	} else {
+		err.Token = s.endToken()
+	}
+	err.Position = s.Position(&err.Token)
+	return err
+}
+
+// endToken returns TokenUndef token positioned at the end of the last token of the stream.
+func (s *Stream) endToken() Token {
+	last := s.lastToken()
+	if last == nil {
+		return Token{id: -1, line: 1, column: 1}
+	}
+	end := Token{
+		id:     -1,
+		line:   last.line,
+		offset: last.offset + len(last.value),
+	}
+	if last.column > 0 {
+		end.column = last.column + len(last.value)
+	}
+	if n := countNewLines(last.value); n > 0 {
+		end.line += n
+		end.column = len(last.value) - bytes.LastIndexByte(last.value, newLine)
+	}
+	return end
+}
@@ -0,0 +1,15 @@
+package tokenizer
+
+// ParseFile parse the file into tokens. The file is memory-mapped where it's supported (it's read elsewhere),
+// so values of tokens reference the mapping without copying, which is much faster for multi-hundred-MB logs.
+// The path is attached to the stream as the file name, like ParseBytesNamed.
+// Stream.Close unmaps the file, so the stream and values of its tokens must not be used after Close.
+func (t *Tokenizer) ParseFile(path string) (*Stream, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := t.ParseBytesNamed(path, data)
+	s.unmap = unmap
+	return s, nil
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package tokenizer
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the regular file into the memory for reading, other files are read.
+// The returned function unmaps the file, it's nil if the file is read.
+func mapFile(path string) ([]byte, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size > 0 && info.Mode().IsRegular() && int64(int(size)) == size {
+		data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+		if err == nil {
+			return data, func() { _ = syscall.Munmap(data) }, nil
+		}
+	}
+	data, err := io.ReadAll(f)
+	return data, nil, err
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package tokenizer
+
+import "os"
+
+// mapFile reads the file, memory mapping isn't supported on the platform (like js/wasm).
+func mapFile(path string) ([]byte, func(), error) {
+	data, err := os.ReadFile(path)
+	return data, nil, err
+}
@@ -0,0 +1,39 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFile(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, `"`, `"`)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "data.txt")
+	data := strings.Repeat("key = \"value\" 10\n", 1000)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	stream, err := tokenizer.ParseFile(path)
+	require.NoError(t, err)
+	expected := tokenizer.ParseString(data)
+	require.Equal(t, expected.String(), stream.String())
+	require.Equal(t, path, stream.FileName())
+	require.Equal(t, path+":1:5", stream.GoNext().Position(stream.CurrentToken()).String())
+	stream.Close()
+	expected.Close()
+
+	empty := filepath.Join(dir, "empty.txt")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+	stream, err = tokenizer.ParseFile(empty)
+	require.NoError(t, err)
+	require.False(t, stream.IsValid())
+	stream.Close()
+
+	_, err = tokenizer.ParseFile(filepath.Join(dir, "missing.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
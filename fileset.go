@@ -0,0 +1,47 @@
+package tokenizer
+
+import (
+	gotoken "go/token"
+)
+
+// AddFile registers the source of the stream in the file set of go/token, so positions of tokens (see Token.Pos)
+// may be used by Go tooling which expects FileSet-based positions, like go/scanner errors.
+// Lines of the file are computed from tokens, comments and whitespaces, so in the streaming mode
+// all data will be parsed and the history should not be limited (see SetHistorySize). The pointer stays unchanged.
+func (s *Stream) AddFile(fset *gotoken.FileSet, name string) *gotoken.File {
+	var lines []int
+	size := 0
+	first := true
+	piece := func(value []byte) {
+		for i, b := range value {
+			if b == newLine {
+				lines = append(lines, size+i+1)
+			}
+		}
+		size += len(value)
+	}
+	s.each(func(t *Token) {
+		if first {
+			// the source starts at the first token in source order, the leading trivia of the head token
+			size, first = t.offset-len(t.indent), false
+		}
+		piece(t.indent)
+		piece(t.value)
+	})
+	piece(s.tail())
+
+	file := fset.AddFile(name, -1, size)
+	for _, line := range lines {
+		file.AddLine(line)
+	}
+	return file
+}
+
+// Pos returns the position of the token in the file registered by Stream.AddFile.
+// Method returns token.NoPos for TokenUndef token.
+func (t *Token) Pos(file *gotoken.File) gotoken.Pos {
+	if !t.IsValid() {
+		return gotoken.NoPos
+	}
+	return file.Pos(t.offset)
+}
@@ -0,0 +1,37 @@
+package tokenizer
+
+import (
+	gotoken "go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamAddFile(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"="})
+	tokenizer.DefineStringToken(TokenKey(11), "/*", "*/")
+	tokenizer.DefineTrivia(TokenKey(11))
+	stream := tokenizer.ParseString("a = 1\n/* x\n */ b\n  = 2\n")
+
+	fset := gotoken.NewFileSet()
+	file := stream.AddFile(fset, "query.txt")
+	require.Equal(t, 23, file.Size())
+	require.Equal(t, 4, file.LineCount())
+
+	require.Equal(t, "query.txt:1:1", fset.Position(stream.CurrentToken().Pos(file)).String())
+	b := stream.GoTo(3).CurrentToken()
+	require.Equal(t, "b", b.ValueString())
+	require.Equal(t, gotoken.Position{Filename: "query.txt", Offset: 15, Line: 3, Column: 5},
+		fset.Position(b.Pos(file)))
+	require.Equal(t, "query.txt:4:3", fset.Position(stream.GoNext().CurrentToken().Pos(file)).String())
+	require.Equal(t, gotoken.NoPos, stream.GoTo(10).CurrentToken().Pos(file))
+
+	tokenizer.DefineStringToken(TokenKey(12), "#", "\n")
+	tokenizer.DefineTrivia(TokenKey(12))
+	stream = tokenizer.ParseString("# comment\n# two\nx y\nz")
+	file = stream.AddFile(gotoken.NewFileSet(), "comments.txt")
+	require.Equal(t, 21, file.Size())
+	require.Equal(t, gotoken.Position{Filename: "comments.txt", Offset: 16, Line: 3, Column: 1}, file.Position(stream.CurrentToken().Pos(file)))
+	require.Equal(t, "comments.txt:4:1", file.Position(stream.GoTo(2).CurrentToken().Pos(file)).String())
+}
@@ -1,19 +1,27 @@
 package tokenizer
 
-import (
-	"unsafe"
-)
+import "io"
 
-func b2s(b []byte) string {
-	return unsafe.String(unsafe.SliceData(b), len(b))
+func isNumberByte(b byte) bool {
+	return '0' <= b && b <= '9'
 }
 
-func s2b(s string) (b []byte) {
-	return unsafe.Slice(unsafe.StringData(s), len(s))
+func isLetterByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
 }
 
-func isNumberByte(b byte) bool {
-	return '0' <= b && b <= '9'
+func isHexByte(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
+
+func countNewLines(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c == newLine {
+			n++
+		}
+	}
+	return n
 }
 
 func bytesStarts(prefix []byte, b []byte) bool {
@@ -29,3 +37,23 @@ func bytesEnds(suffix []byte, b []byte) bool {
 	}
 	return b2s(suffix) == b2s(b[len(b)-len(suffix):])
 }
+
+// writer writes bytes until the first error and counts written bytes.
+type writer struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *writer) write(b []byte) {
+	if w.err != nil || len(b) == 0 {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) writeString(s string) {
+	w.write(s2b(s))
+}
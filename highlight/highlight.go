@@ -0,0 +1,205 @@
+// Package highlight maps tokens of any grammar defined with the tokenizer to syntax highlighting classes.
+// Token types are named as Chroma token types (github.com/alecthomas/chroma), so the result may be converted
+// to Chroma tokens via chroma.TokenTypeString and formatted with Chroma styles.
+package highlight
+
+import (
+	"html"
+	"io"
+
+	"github.com/psyton/tokenizer"
+)
+
+// TokenType is the name of the Chroma token type.
+type TokenType string
+
+// Chroma token types.
+const (
+	Text           TokenType = "Text"
+	Whitespace     TokenType = "TextWhitespace"
+	Error          TokenType = "Error"
+	Keyword        TokenType = "Keyword"
+	KeywordType    TokenType = "KeywordType"
+	KeywordConst   TokenType = "KeywordConstant"
+	Name           TokenType = "Name"
+	NameBuiltin    TokenType = "NameBuiltin"
+	NameFunction   TokenType = "NameFunction"
+	NameVariable   TokenType = "NameVariable"
+	String         TokenType = "LiteralString"
+	StringEscape   TokenType = "LiteralStringEscape"
+	StringInterpol TokenType = "LiteralStringInterpol"
+	Number         TokenType = "LiteralNumber"
+	Integer        TokenType = "LiteralNumberInteger"
+	Float          TokenType = "LiteralNumberFloat"
+	Operator       TokenType = "Operator"
+	Punctuation    TokenType = "Punctuation"
+	Comment        TokenType = "Comment"
+	CommentSingle  TokenType = "CommentSingle"
+	CommentMulti   TokenType = "CommentMultiline"
+)
+
+// classes are CSS classes of token types used by Chroma HTML formatter.
+var classes = map[TokenType]string{
+	Text:           "",
+	Whitespace:     "w",
+	Error:          "err",
+	Keyword:        "k",
+	KeywordType:    "kt",
+	KeywordConst:   "kc",
+	Name:           "n",
+	NameBuiltin:    "nb",
+	NameFunction:   "nf",
+	NameVariable:   "nv",
+	String:         "s",
+	StringEscape:   "se",
+	StringInterpol: "si",
+	Number:         "m",
+	Integer:        "mi",
+	Float:          "mf",
+	Operator:       "o",
+	Punctuation:    "p",
+	Comment:        "c",
+	CommentSingle:  "c1",
+	CommentMulti:   "cm",
+}
+
+// Class returns the CSS class of the token type used by Chroma HTML formatter, like `k` for Keyword.
+func (t TokenType) Class() string {
+	return classes[t]
+}
+
+// Token is the highlighted piece of the source.
+type Token struct {
+	Type  TokenType
+	Value string
+}
+
+// Highlighter maps token keys to token types.
+type Highlighter struct {
+	keys     map[tokenizer.TokenKey]TokenType
+	strings  map[tokenizer.TokenKey]TokenType
+	keywords map[string]TokenType
+}
+
+// New creates the highlighter with default types of embedded tokens: keywords are Name, numbers are Integer and
+// Float, strings are String, unknown tokens are Error, comments are Comment and user defined tokens are Text.
+func New() *Highlighter {
+	return &Highlighter{
+		keys: map[tokenizer.TokenKey]TokenType{
+			tokenizer.TokenKeyword:        Name,
+			tokenizer.TokenInteger:        Integer,
+			tokenizer.TokenFloat:          Float,
+			tokenizer.TokenString:         String,
+			tokenizer.TokenStringFragment: String,
+			tokenizer.TokenChar:           String,
+			tokenizer.TokenUnknown:        Error,
+		},
+		strings:  map[tokenizer.TokenKey]TokenType{},
+		keywords: map[string]TokenType{},
+	}
+}
+
+// Map sets the type of tokens with these keys.
+func (h *Highlighter) Map(typ TokenType, keys ...tokenizer.TokenKey) *Highlighter {
+	for _, key := range keys {
+		h.keys[key] = typ
+	}
+	return h
+}
+
+// MapString sets the type of framed strings and comments with these string keys (see tokenizer.Token.StringKey).
+func (h *Highlighter) MapString(typ TokenType, keys ...tokenizer.TokenKey) *Highlighter {
+	for _, key := range keys {
+		h.strings[key] = typ
+	}
+	return h
+}
+
+// MapKeywords sets the type of keywords with these values, like `if` or `func`.
+func (h *Highlighter) MapKeywords(typ TokenType, words ...string) *Highlighter {
+	for _, word := range words {
+		h.keywords[word] = typ
+	}
+	return h
+}
+
+// TypeOf returns the type of the token.
+func (h *Highlighter) TypeOf(t *tokenizer.Token) TokenType {
+	if t.IsString() {
+		if typ, ok := h.strings[t.StringKey()]; ok {
+			return typ
+		}
+	}
+	if t.IsKeyword() {
+		if typ, ok := h.keywords[t.ValueString()]; ok {
+			return typ
+		}
+	}
+	if typ, ok := h.keys[t.Key()]; ok {
+		return typ
+	}
+	return Text
+}
+
+// Tokens returns highlighted pieces of the source from the current token to the end of the stream,
+// including comments and whitespaces, so values of tokens are the source byte-for-byte.
+// The stream is drained, like tokenizer.Stream.Tokens.
+func (h *Highlighter) Tokens(s *tokenizer.Stream) []Token {
+	pieces := h.pieces(s)
+	tokens := make([]Token, len(pieces))
+	for i, p := range pieces {
+		tokens[i] = p.Token
+	}
+	return tokens
+}
+
+// piece is the highlighted token with its offset in the source.
+type piece struct {
+	Token
+	offset int
+}
+
+func (h *Highlighter) pieces(s *tokenizer.Stream) []piece {
+	var pieces []piece
+	for _, t := range s.Tokens() {
+		pieces = h.appendTrivia(pieces, t.Trivia())
+		pieces = append(pieces, piece{Token{Type: h.TypeOf(&t), Value: t.ValueString()}, t.Offset()})
+	}
+	return h.appendTrivia(pieces, s.TrailingTrivia())
+}
+
+func (h *Highlighter) appendTrivia(pieces []piece, trivia []tokenizer.Trivia) []piece {
+	for _, t := range trivia {
+		typ := Whitespace
+		if t.IsComment() {
+			typ = Comment
+			if mapped, ok := h.strings[t.Key]; ok {
+				typ = mapped
+			} else if mapped, ok = h.keys[t.Key]; ok {
+				typ = mapped
+			}
+		}
+		pieces = append(pieces, piece{Token{Type: typ, Value: string(t.Value)}, t.Offset})
+	}
+	return pieces
+}
+
+// WriteHTML writes the source from the current token to the end of the stream as HTML
+// with Chroma CSS classes, like `<span class="k">func</span>`. Whitespaces and Text are written without spans.
+// The stream is drained, like tokenizer.Stream.Tokens.
+func (h *Highlighter) WriteHTML(w io.Writer, s *tokenizer.Stream) error {
+	for _, t := range h.Tokens(s) {
+		if _, err := io.WriteString(w, t.html(t.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// html returns the value as HTML with the CSS class of the token type.
+func (t Token) html(value string) string {
+	if class := t.Type.Class(); class != "" && t.Type != Whitespace {
+		return `<span class="` + class + `">` + html.EscapeString(value) + "</span>"
+	}
+	return html.EscapeString(value)
+}
@@ -0,0 +1,58 @@
+package highlight
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	tokenOperator tokenizer.TokenKey = iota + 1
+	tokenParen
+	tokenString
+	tokenComment
+)
+
+func TestHighlighter(t *testing.T) {
+	parser := tokenizer.New()
+	parser.DefineTokens(tokenOperator, []string{"=", "+"})
+	parser.DefineTokens(tokenParen, []string{"(", ")"})
+	parser.DefineStringToken(tokenString, `"`, `"`)
+	parser.DefineStringToken(tokenComment, "//", "\n")
+	parser.DefineTrivia(tokenComment)
+
+	h := New().
+		Map(Operator, tokenOperator).
+		Map(Punctuation, tokenParen).
+		MapString(CommentSingle, tokenComment).
+		MapKeywords(Keyword, "let")
+
+	source := "// sum\nlet x = f(1.5 + \"<a>\") # // end\n"
+	require.Equal(t, []Token{
+		{Type: CommentSingle, Value: "// sum\n"},
+		{Type: Keyword, Value: "let"},
+		{Type: Whitespace, Value: " "},
+		{Type: Name, Value: "x"},
+		{Type: Whitespace, Value: " "},
+		{Type: Operator, Value: "="},
+		{Type: Whitespace, Value: " "},
+		{Type: Name, Value: "f"},
+		{Type: Punctuation, Value: "("},
+		{Type: Float, Value: "1.5"},
+		{Type: Whitespace, Value: " "},
+		{Type: Operator, Value: "+"},
+		{Type: Whitespace, Value: " "},
+		{Type: String, Value: `"<a>"`},
+		{Type: Punctuation, Value: ")"},
+		{Type: Whitespace, Value: " "},
+		{Type: Error, Value: "#"},
+		{Type: Whitespace, Value: " "},
+		{Type: CommentSingle, Value: "// end\n"},
+	}, h.Tokens(parser.ParseString(source)))
+
+	out := bytes.NewBuffer(nil)
+	require.NoError(t, h.WriteHTML(out, parser.ParseString(`x = "<a>"  `)))
+	require.Equal(t, `<span class="n">x</span> <span class="o">=</span> <span class="s">&#34;&lt;a&gt;&#34;</span>  `, out.String())
+}
@@ -0,0 +1,81 @@
+package highlight
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/psyton/tokenizer"
+)
+
+// WriteErrorHTML writes the source from the current token to the end of the stream as the HTML fragment
+// with line numbers, the token at the byte offset `offset` is wrapped in `<span class="error">`,
+// like the token of tokenizer.UnexpectedTokenError. If there is no token at the offset (the end of the stream)
+// the empty error span is inserted at the offset. Only `context` lines around the line of the error are written,
+// all lines if `context` is negative.
+// Lines are written in the Chroma HTML format: `<span class="line"><span class="ln">1</span><span class="cl">…</span></span>`.
+// The stream is drained, like tokenizer.Stream.Tokens. The empty stream is written as the line 1 with the error span.
+func (h *Highlighter) WriteErrorHTML(w io.Writer, s *tokenizer.Stream, offset int, context int) error {
+	var lines []string
+	var line strings.Builder
+	errLine := -1
+	marked := false
+	mark := func(html string) {
+		line.WriteString(`<span class="error">` + html + `</span>`)
+		errLine = len(lines)
+		marked = true
+	}
+	startLine := 1
+	if first := s.CurrentToken(); first != nil && first.IsValid() {
+		startLine = first.Line()
+		if trivia := first.Trivia(); len(trivia) > 0 {
+			startLine = trivia[0].Line
+		}
+	}
+	for _, p := range h.pieces(s) {
+		isError := !marked && offset >= p.offset && offset < p.offset+len(p.Value)
+		if isError && p.Type == Whitespace {
+			mark("")
+			isError = false
+		}
+		for i, segment := range strings.Split(p.Value, "\n") {
+			if i > 0 {
+				lines = append(lines, line.String())
+				line.Reset()
+			}
+			if segment == "" {
+				continue
+			}
+			if isError {
+				mark(p.html(segment))
+			} else {
+				line.WriteString(p.html(segment))
+			}
+		}
+	}
+	if !marked {
+		mark("")
+	}
+	if line.Len() > 0 || len(lines) == 0 {
+		lines = append(lines, line.String())
+	}
+
+	from, to := 0, len(lines)
+	if context >= 0 {
+		if from = errLine - context; from < 0 {
+			from = 0
+		}
+		if to = errLine + context + 1; to > len(lines) {
+			to = len(lines)
+		}
+	}
+	var out strings.Builder
+	out.WriteString(`<pre class="chroma">`)
+	for i := from; i < to; i++ {
+		out.WriteString(`<span class="line"><span class="ln">` + strconv.Itoa(startLine+i) + `</span><span class="cl">` +
+			lines[i] + "\n</span></span>")
+	}
+	out.WriteString("</pre>")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
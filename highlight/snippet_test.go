@@ -0,0 +1,41 @@
+package highlight
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteErrorHTML(t *testing.T) {
+	parser := tokenizer.New()
+	parser.DefineTokens(tokenOperator, []string{"=", "<"})
+	h := New().Map(Operator, tokenOperator)
+	source := "a = 1\nb = < c\n\nd = 2\n"
+
+	stream := parser.ParseString(source)
+	_, err := stream.GoTo(4).GoNext().Expect(tokenizer.TokenKeyword)
+	var unexpected *tokenizer.UnexpectedTokenError
+	require.True(t, errors.As(err, &unexpected))
+
+	out := bytes.NewBuffer(nil)
+	require.NoError(t, h.WriteErrorHTML(out, parser.ParseString(source), unexpected.Token.Offset(), 1))
+	require.Equal(t, `<pre class="chroma">`+
+		`<span class="line"><span class="ln">1</span><span class="cl"><span class="n">a</span> <span class="o">=</span> <span class="mi">1</span>`+"\n</span></span>"+
+		`<span class="line"><span class="ln">2</span><span class="cl"><span class="n">b</span> <span class="o">=</span> `+
+		`<span class="error"><span class="o">&lt;</span></span> <span class="n">c</span>`+"\n</span></span>"+
+		`<span class="line"><span class="ln">3</span><span class="cl">`+"\n</span></span></pre>", out.String())
+
+	out.Reset()
+	require.NoError(t, h.WriteErrorHTML(out, parser.ParseString("a =\n"), 3, -1))
+	require.Equal(t, `<pre class="chroma">`+
+		`<span class="line"><span class="ln">1</span><span class="cl"><span class="n">a</span> <span class="o">=</span>`+
+		`<span class="error"></span>`+"\n</span></span></pre>", out.String())
+
+	out.Reset()
+	require.NoError(t, h.WriteErrorHTML(out, parser.ParseString(""), 0, 1))
+	require.Equal(t, `<pre class="chroma">`+
+		`<span class="line"><span class="ln">1</span><span class="cl"><span class="error"></span>`+"\n</span></span></pre>", out.String())
+}
@@ -0,0 +1,50 @@
+package tokenizer
+
+// TokenHook is the callback of the emitted token, see Tokenizer.OnToken.
+// The hook returns the key of the token, so it may reclassify the token, like the contextual keyword promoted
+// to the user defined key. TokenUndef vetoes the token: it isn't added to the stream.
+// The hook may annotate the token via Token.SetData.
+type TokenHook func(token *Token) TokenKey
+
+// tokenHook is the hook with keys of tokens
+type tokenHook struct {
+	keys []TokenKey
+	fn   TokenHook
+}
+
+// OnToken registers the hook called for each emitted token with any of the keys (all tokens if no keys),
+// including trivia tokens, before the token is added to the stream. Hooks are called in the order of registration
+// during parsing, so symbol tables may be collected in a single pass. The token is detached: it isn't linked yet,
+// its neighbours aren't available.
+func (t *Tokenizer) OnToken(hook TokenHook, keys ...TokenKey) *Tokenizer {
+	t.hooks = append(t.hooks, tokenHook{keys: keys, fn: hook})
+	return t
+}
+
+// SetData annotates the token by the value, like the symbol of the symbol table. See Data.
+func (t *Token) SetData(data interface{}) {
+	t.data = data
+}
+
+// Data returns the annotation of the token, see SetData.
+func (t *Token) Data() interface{} {
+	return t.data
+}
+
+// hook calls hooks for the current token. Method returns false if the token is vetoed.
+func (p *parsing) hook() bool {
+	for _, h := range p.t.hooks {
+		if len(h.keys) > 0 && !p.token.hasAnyKey(h.keys) {
+			continue
+		}
+		key := h.fn(p.token)
+		if key == TokenUndef {
+			if p.t.logs(LogTrace) {
+				p.logf(LogTrace, p.token.offset, "token %q (%s) vetoed by the hook", p.token.value, p.g.KeyName(p.token.key))
+			}
+			return false
+		}
+		p.token.key = key
+	}
+	return true
+}
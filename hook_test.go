@@ -0,0 +1,41 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnToken(t *testing.T) {
+	const (
+		tokenAssign TokenKey = iota + 10
+		tokenComment
+		tokenVar
+	)
+	symbols := map[string]int{}
+	tokenizer := New()
+	tokenizer.DefineTokens(tokenAssign, []string{"="})
+	tokenizer.DefineStringToken(tokenComment, "#", "\n")
+	tokenizer.OnToken(func(token *Token) TokenKey {
+		// the keyword at the start of the line is the variable
+		if token.Column() == 1 {
+			symbols[token.ValueString()] = token.ID()
+			token.SetData(len(symbols))
+			return tokenVar
+		}
+		return token.Key()
+	}, TokenKeyword).OnToken(func(token *Token) TokenKey {
+		return TokenUndef
+	}, tokenComment)
+
+	stream := tokenizer.ParseString("a = b # comment\nc = a")
+	defer stream.Close()
+	var keys []TokenKey
+	for ; stream.IsValid(); stream.GoNext() {
+		keys = append(keys, stream.CurrentToken().Key())
+	}
+	require.Equal(t, []TokenKey{tokenVar, tokenAssign, TokenKeyword, tokenVar, tokenAssign, TokenKeyword}, keys)
+	require.Equal(t, map[string]int{"a": 0, "c": 3}, symbols)
+	require.Equal(t, 2, stream.GoTo(3).CurrentToken().Data())
+	require.Nil(t, stream.GoTo(2).CurrentToken().Data())
+}
@@ -0,0 +1,96 @@
+package tokenizer
+
+import (
+	"net/netip"
+)
+
+// maxIPLength is the length of the longest IPv6 address, like `ffff:ffff:ffff:ffff:ffff:ffff:255.255.255.255`.
+const maxIPLength = 45
+
+// DefineIPTokens defines IPv4 and IPv6 addresses with the key `addrKey`, like `10.0.0.1` or `2001:db8::1`,
+// and CIDR ranges with the key `cidrKey`, like `10.0.0.0/8` or `2001:db8::/32`. TokenUndef disables addresses
+// or ranges, without ranges the prefix length is the separate token. Addresses take precedence over numbers,
+// keywords and tokens, the address isn't matched if the letter, digit or underscore follows it, zones of IPv6
+// addresses aren't supported. Token.ValueAddr and Token.ValuePrefix return the address and the range.
+func (t *Tokenizer) DefineIPTokens(addrKey, cidrKey TokenKey) *Tokenizer {
+	if addrKey < 1 {
+		addrKey = TokenUndef
+	}
+	if cidrKey < 1 {
+		cidrKey = TokenUndef
+	}
+	t.ip, t.cidr = addrKey, cidrKey
+	return t
+}
+
+// parseIP parses the address or the range at the current position, see Tokenizer.DefineIPTokens.
+func (p *parsing) parseIP() bool {
+	if !isHexByte(p.curr) && p.curr != ':' {
+		return false
+	}
+	n, dots, colons := 0, 0, 0
+	for ; p.ensureBytes(n); n++ {
+		if b := p.str[p.pos+n]; b == '.' {
+			dots++
+		} else if b == ':' {
+			colons++
+		} else if !isHexByte(b) {
+			break
+		}
+		if n == maxIPLength {
+			return false
+		}
+	}
+	if dots < 3 && colons < 2 {
+		return false
+	}
+	// the address may be followed by the dot or the colon, like `10.0.0.1.`
+	for {
+		if _, err := netip.ParseAddr(b2s(p.str[p.pos : p.pos+n])); err == nil {
+			break
+		}
+		if last := p.str[p.pos+n-1]; n == 1 || last != '.' && last != ':' {
+			return false
+		}
+		n--
+	}
+	key := p.g.ip
+	if p.g.cidr != TokenUndef && p.ensureBytes(n) && p.str[p.pos+n] == '/' {
+		m := n + 1
+		for p.ensureBytes(m) && isNumberByte(p.str[p.pos+m]) && m-n <= 3 {
+			m++
+		}
+		if _, err := netip.ParsePrefix(b2s(p.str[p.pos : p.pos+m])); err == nil && m > n+1 {
+			n, key = m, p.g.cidr
+		}
+	}
+	if key == TokenUndef || p.isWordRune(n) {
+		return false
+	}
+	start := p.pos
+	p.pos += n - 1
+	p.next()
+	p.token.key = key
+	p.token.value = p.str[start:p.pos]
+	p.token.offset = p.offset + start
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "ip %q (%s)", p.token.value, p.g.KeyName(p.token.key))
+	}
+	p.emmitToken()
+	return true
+}
+
+// ValueAddr returns the IP address, see Tokenizer.DefineIPTokens.
+// False is returned if the value isn't the address.
+func (t *Token) ValueAddr() (netip.Addr, bool) {
+	// the zone of the result may refer to the value, so the value is copied
+	addr, err := netip.ParseAddr(string(t.value))
+	return addr, err == nil
+}
+
+// ValuePrefix returns the CIDR range, like `10.0.0.0/8`, see Tokenizer.DefineIPTokens.
+// False is returned if the value isn't the range.
+func (t *Token) ValuePrefix() (netip.Prefix, bool) {
+	prefix, err := netip.ParsePrefix(string(t.value))
+	return prefix, err == nil
+}
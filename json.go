@@ -0,0 +1,76 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// names of embedded token keys
+var keyNames = map[TokenKey]string{
+	TokenChar:           "char",
+	TokenUnknown:        "unknown",
+	TokenStringFragment: "string_fragment",
+	TokenString:         "string",
+	TokenFloat:          "float",
+	TokenInteger:        "integer",
+	TokenKeyword:        "keyword",
+	TokenUndef:          "undef",
+}
+
+// tokenJSON is JSON representation of the token.
+type tokenJSON struct {
+	ID     int      `json:"id"`
+	Key    TokenKey `json:"key"`
+	Name   string   `json:"name,omitempty"`
+	Value  string   `json:"value"`
+	Offset int      `json:"offset"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	String *int     `json:"string,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+// The token is encoded as object with fields: id, key, name (for embedded keys), value, offset, line, column and
+// string — id of the string definition (see StringSettings.ID) for framed strings.
+func (t *Token) MarshalJSON() ([]byte, error) {
+	v := tokenJSON{
+		ID:     t.id,
+		Key:    t.key,
+		Name:   keyNames[t.key],
+		Value:  t.ValueString(),
+		Offset: t.offset,
+		Line:   t.line,
+		Column: t.column,
+	}
+	if t.string != nil {
+		id := t.string.id
+		v.String = &id
+	}
+	return json.Marshal(v)
+}
+
+// MarshalJSON implements json.Marshaler.
+// The stream is encoded as array of tokens from the head token (see Token.MarshalJSON).
+// In the streaming mode all data will be parsed. The pointer stays unchanged.
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	tokens := make([]*Token, 0, s.len)
+	for ptr := s.head; ptr != nil && ptr != undefToken; ptr = s.nextOf(ptr) {
+		tokens = append(tokens, ptr)
+	}
+	return json.Marshal(tokens)
+}
+
+// WriteNDJSON writes tokens from the head token to the writer as newline delimited JSON — one token per line
+// (see Token.MarshalJSON). The pointer stays unchanged.
+func (s *Stream) WriteNDJSON(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	for ptr := s.head; ptr != nil && ptr != undefToken; ptr = s.nextOf(ptr) {
+		if err := enc.Encode(ptr); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
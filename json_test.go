@@ -0,0 +1,33 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamJSON(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"<="})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`)
+	tokenizer.DefineStringToken(TokenKey(15), "'", "'")
+	stream := tokenizer.ParseString("one <=\n 'two' 3.5")
+
+	data, err := json.Marshal(stream)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"id": 0, "key": -1, "name": "keyword", "value": "one", "offset": 0, "line": 1, "column": 1},
+		{"id": 1, "key": 10, "value": "<=", "offset": 4, "line": 1, "column": 5},
+		{"id": 2, "key": -4, "name": "string", "value": "'two'", "offset": 8, "line": 2, "column": 2, "string": 1},
+		{"id": 3, "key": -3, "name": "float", "value": "3.5", "offset": 14, "line": 2, "column": 8}
+	]`, string(data))
+
+	out := bytes.NewBuffer(nil)
+	require.NoError(t, stream.GoNext().WriteNDJSON(out))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 4)
+	require.JSONEq(t, `{"id": 1, "key": 10, "value": "<=", "offset": 4, "line": 1, "column": 5}`, string(lines[1]))
+	require.Equal(t, 1, stream.CurrentToken().ID())
+}
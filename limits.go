@@ -0,0 +1,134 @@
+package tokenizer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Names of limits of the parser, see LimitError.
+const (
+	// LimitBytes is the limit of the size of the input, see Tokenizer.SetMaxBytes.
+	LimitBytes = "bytes"
+	// LimitDuration is the limit of the time spent for parsing, see Tokenizer.SetMaxDuration.
+	LimitDuration = "duration"
+	// LimitTokenLength is the limit of the length of tokens, see Tokenizer.SetMaxTokenLength.
+	LimitTokenLength = "token length"
+	// LimitTokens is the limit of the count of tokens, see Tokenizer.SetMaxTokens.
+	LimitTokens = "tokens"
+	// LimitDepth is the limit of the nesting depth of injections, see Tokenizer.SetMaxDepth.
+	LimitDepth = "depth"
+)
+
+// limits of the parser, zero means unlimited
+type limits struct {
+	bytes       int
+	duration    time.Duration
+	tokenLength int
+	tokens      int
+	depth       int
+}
+
+// LimitError is the error of the exceeded limit of the parser, like SetMaxBytes.
+// Parsing stops at the position of the error, see Stream.Err.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, like LimitBytes.
+	Limit string
+	// Max is the value of the limit, nanoseconds for LimitDuration.
+	Max int64
+	// Position is the position in the source where parsing stopped.
+	Position Position
+}
+
+func (e *LimitError) Error() string {
+	max := fmt.Sprint(e.Max)
+	if e.Limit == LimitDuration {
+		max = time.Duration(e.Max).String()
+	}
+	return fmt.Sprintf("%s: %s limit %s exceeded", e.Position, e.Limit, max)
+}
+
+// SetMaxBytes limits the size of the input. Tokens starting after `n` bytes are not parsed, parsing stops
+// with LimitError (see Stream.Err). It's needed to safely tokenize untrusted input. Zero means unlimited.
+func (t *Tokenizer) SetMaxBytes(n int) *Tokenizer {
+	t.limits.bytes = n
+	return t
+}
+
+// SetMaxDuration limits the time spent for parsing, parsing stops with LimitError (see Stream.Err)
+// when the time is over. In the streaming mode only the time of parsing of data-chunks is counted.
+// Zero means unlimited.
+func (t *Tokenizer) SetMaxDuration(d time.Duration) *Tokenizer {
+	t.limits.duration = d
+	return t
+}
+
+// SetMaxTokenLength limits the length of any single token, particularly strings and keywords
+// (in the framed string with injections the length of each fragment is limited).
+// If the token is longer parsing stops with LimitError (see Stream.Err) and the token isn't added to the stream,
+// so an unbounded value isn't buffered in the streaming mode. Zero means unlimited.
+func (t *Tokenizer) SetMaxTokenLength(n int) *Tokenizer {
+	t.limits.tokenLength = n
+	return t
+}
+
+// SetMaxTokens limits the total count of tokens produced per parse, including trivia tokens.
+// The token over the limit isn't added to the stream, parsing stops with LimitError (see Stream.Err).
+// It protects services from inputs like millions of single-character unknown tokens. Zero means unlimited.
+func (t *Tokenizer) SetMaxTokens(n int) *Tokenizer {
+	t.limits.tokens = n
+	return t
+}
+
+// SetMaxDepth limits the nesting depth of injections in framed strings, like `"{{"{{"{{…`.
+// Parsing stops with LimitError (see Stream.Err) at the start of the injection over the limit.
+// Zero means unlimited.
+func (t *Tokenizer) SetMaxDepth(n int) *Tokenizer {
+	t.limits.depth = n
+	return t
+}
+
+// exceed stops parsing with LimitError at the position.
+func (p *parsing) exceed(limit string, max int64, pos Position) {
+	if p.stop != nil {
+		return
+	}
+	err := &LimitError{Limit: limit, Max: max, Position: pos}
+	p.stop = err
+	if p.t.logs(LogWarning) {
+		p.t.logger.Log(LogWarning, pos, limit+" limit exceeded")
+	}
+}
+
+// checkLimits checks limits of the size of the input on each token
+// and the duration of parsing each checkSteps iterations.
+func (p *parsing) checkLimits() bool {
+	offset := p.offset + p.pos
+	if p.t.limits.bytes > 0 && offset >= p.t.limits.bytes && p.ensureBytes(0) {
+		p.exceed(LimitBytes, int64(p.t.limits.bytes), p.position(offset))
+	}
+	if p.steps%checkSteps == 0 && p.t.limits.duration > 0 && p.spent+time.Since(p.started) > p.t.limits.duration {
+		p.exceed(LimitDuration, int64(p.t.limits.duration), p.position(offset))
+	}
+	return p.stop != nil
+}
+
+// tooLong checks the length of the token which starts at the position `start` of the data-chunk
+// and stops parsing if the token is too long.
+func (p *parsing) tooLong(start int) bool {
+	if p.tokenLength == 0 || p.pos-start <= p.tokenLength {
+		return false
+	}
+	// the token may be multi-line, so the position is from the beginning of the token
+	p.exceed(LimitTokenLength, int64(p.tokenLength), p.tokenPosition(p.offset+start))
+	return true
+}
+
+// tooMany checks the count of produced tokens before the next token is emitted
+// and stops parsing if the limit is reached.
+func (p *parsing) tooMany() bool {
+	if p.t.limits.tokens == 0 || p.n+p.stats.Trivia < p.t.limits.tokens {
+		return false
+	}
+	p.exceed(LimitTokens, int64(p.t.limits.tokens), p.tokenPosition(p.token.offset))
+	return true
+}
@@ -0,0 +1,104 @@
+package tokenizer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	tokenizer := New().SetMaxBytes(10)
+	stream := tokenizer.ParseString("one two three")
+	require.Equal(t, []string{"one", "two", "three"}, tokenValues(stream.Tokens()))
+	require.NoError(t, stream.Err())
+	stream.Close()
+
+	stream = tokenizer.ParseString("one two three four")
+	require.Equal(t, []string{"one", "two", "three"}, tokenValues(stream.Tokens()))
+	require.EqualError(t, stream.Err(), "1:14: bytes limit 10 exceeded")
+	var limit *LimitError
+	require.ErrorAs(t, stream.Err(), &limit)
+	require.Equal(t, LimitBytes, limit.Limit)
+	require.Equal(t, 13, limit.Position.Offset)
+	stream.Close()
+
+	stream = tokenizer.ParseStream(bytes.NewReader(bytes.Repeat([]byte("one "), 100)), 4)
+	require.Len(t, stream.Tokens(), 3)
+	require.ErrorAs(t, stream.Err(), &limit)
+	stream.Close()
+
+	_, err := tokenizer.ParseBytesCtx(context.Background(), []byte("one two three four"))
+	require.ErrorAs(t, err, &limit)
+
+	tokenizer = New().SetMaxDuration(time.Nanosecond)
+	stream = tokenizer.ParseBytes(bytes.Repeat([]byte("one "), 10000))
+	require.Less(t, len(stream.Tokens()), 10000)
+	require.ErrorAs(t, stream.Err(), &limit)
+	require.Equal(t, LimitDuration, limit.Limit)
+	require.Contains(t, limit.Error(), "duration limit 1ns exceeded")
+	stream.Close()
+}
+
+func TestMaxTokenLength(t *testing.T) {
+	tokenizer := New().SetMaxTokenLength(5)
+	tokenizer.DefineStringToken(TokenKey(10), `"`, `"`)
+	stream := tokenizer.ParseString(`one "two" 12345 "three four"`)
+	require.Equal(t, []string{"one", `"two"`, "12345"}, tokenValues(stream.Tokens()))
+	require.EqualError(t, stream.Err(), "1:17: token length limit 5 exceeded")
+	stream.Close()
+
+	stream = tokenizer.ParseString("a \"b\nc\nddddddd\"")
+	require.EqualError(t, stream.Err(), "1:3: token length limit 5 exceeded")
+	stream.Close()
+
+	stream = tokenizer.ParseString("one 1234567")
+	require.Equal(t, []string{"one"}, tokenValues(stream.Tokens()))
+	require.Error(t, stream.Err())
+	stream.Close()
+
+	stream = tokenizer.ParseStream(bytes.NewReader(append([]byte("one "), bytes.Repeat([]byte("a"), 100000)...)), 16)
+	require.Equal(t, []string{"one"}, tokenValues(stream.Tokens()))
+	var limit *LimitError
+	require.ErrorAs(t, stream.Err(), &limit)
+	require.Equal(t, LimitTokenLength, limit.Limit)
+	require.Less(t, stream.GetParsedLength(), 100)
+	stream.Close()
+}
+
+func TestMaxTokens(t *testing.T) {
+	tokenizer := New().SetMaxTokens(3)
+	tokenizer.DefineStringToken(TokenKey(10), "#", "\n")
+	tokenizer.DefineTrivia(TokenKey(10))
+	stream := tokenizer.ParseString("one two three")
+	require.Len(t, stream.Tokens(), 3)
+	require.NoError(t, stream.Err())
+	stream.Close()
+
+	stream = tokenizer.ParseString("one # note\n!!!!!!")
+	require.Equal(t, []string{"one", "!"}, tokenValues(stream.Tokens()))
+	require.EqualError(t, stream.Err(), "2:2: tokens limit 3 exceeded")
+	stream.Close()
+
+	stream = tokenizer.ParseString("one two three # note\n")
+	require.EqualError(t, stream.Err(), "1:15: tokens limit 3 exceeded")
+	stream.Close()
+}
+
+func TestMaxDepth(t *testing.T) {
+	tokenizer := New().SetMaxDepth(2)
+	tokenizer.DefineTokens(TokenKey(10), []string{"{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`).AddInjection(10, 11)
+	stream := tokenizer.ParseString(`"a{{"b{{c}}"}}"`)
+	require.Len(t, stream.Tokens(), 9)
+	require.NoError(t, stream.Err())
+	stream.Close()
+
+	stream = tokenizer.ParseString(`"{{"{{"{{"{{"{{`)
+	require.Equal(t, []string{`"`, "{{", `"`, "{{"}, tokenValues(stream.Tokens()))
+	require.EqualError(t, stream.Err(), "1:8: depth limit 2 exceeded")
+	stream.Close()
+}
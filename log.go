@@ -0,0 +1,70 @@
+package tokenizer
+
+import (
+	"fmt"
+	"io"
+)
+
+// LogLevel is the level of events of the tokenizer, see Tokenizer.SetLogger.
+type LogLevel int8
+
+const (
+	// LogTrace is the level of scanning decisions, like candidate tokens tried (see Tokenizer.EnableTrace).
+	LogTrace LogLevel = iota
+	// LogWarning is the level of events which usually mean invalid input, like the unterminated string.
+	LogWarning
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogTrace:
+		return "trace"
+	case LogWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("level(%d)", int8(l))
+}
+
+// Logger receives events of the tokenizer.
+// Implement the interface to integrate the tokenizer with the logging of the application.
+type Logger interface {
+	// Log receives the event with the position in the source.
+	Log(level LogLevel, pos Position, msg string)
+}
+
+// SetLogger sets the receiver of events with the level `level` and higher. Pass nil to disable logging.
+// Events of the LogTrace level slow parsing down, don't enable them in production.
+func (t *Tokenizer) SetLogger(logger Logger, level LogLevel) *Tokenizer {
+	t.logger = logger
+	t.logLevel = level
+	return t
+}
+
+// EnableTrace enables logging of each scanning decision to the writer: candidate tokens tried,
+// why a byte became TokenUnknown, which string opened and closed, injections.
+// It helps to diagnose why a grammar mis-tokenizes an input. Lines are prefixed with the `line:column` position,
+// warnings are prefixed with `warning:` in addition. Pass nil to disable tracing, see SetLogger.
+func (t *Tokenizer) EnableTrace(w io.Writer) *Tokenizer {
+	if w == nil {
+		return t.SetLogger(nil, LogTrace)
+	}
+	return t.SetLogger(writerLogger{w: w}, LogTrace)
+}
+
+// logs checks if events of the level are sent to the logger.
+func (t *Tokenizer) logs(level LogLevel) bool {
+	return t.logger != nil && level >= t.logLevel
+}
+
+// writerLogger writes events to the writer line by line.
+type writerLogger struct {
+	w io.Writer
+}
+
+func (l writerLogger) Log(level LogLevel, pos Position, msg string) {
+	if level == LogTrace {
+		fmt.Fprintf(l.w, "%s: %s\n", pos, msg)
+	} else {
+		fmt.Fprintf(l.w, "%s: %s: %s\n", pos, level, msg)
+	}
+}
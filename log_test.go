@@ -0,0 +1,33 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger []string
+
+func (l *testLogger) Log(level LogLevel, pos Position, msg string) {
+	*l = append(*l, level.String()+" "+pos.String()+" "+msg)
+}
+
+func TestSetLogger(t *testing.T) {
+	logger := &testLogger{}
+	tokenizer := New().StopOnUndefinedToken().SetLogger(logger, LogWarning)
+	tokenizer.DefineStringToken(TokenKey(10), `"`, `"`)
+	tokenizer.ParseBytesNamed("query.txt", []byte("a \"b\nc")).Close()
+	tokenizer.ParseString("a ! b").Close()
+	require.Equal(t, testLogger{
+		`warning query.txt:2:2 string 10 isn't closed by "\"" at the end of data`,
+		`warning 1:3 stop on unknown byte '!'`,
+	}, *logger)
+
+	*logger = nil
+	tokenizer.SetLogger(logger, LogTrace).ParseString("a").Close()
+	require.Equal(t, testLogger{`trace 1:1 keyword "a"`}, *logger)
+
+	*logger = nil
+	tokenizer.SetLogger(nil, LogTrace).ParseString("\"a").Close()
+	require.Empty(t, *logger)
+}
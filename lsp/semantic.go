@@ -0,0 +1,179 @@
+// Package lsp converts token streams into structures of the Language Server Protocol.
+package lsp
+
+import (
+	"unicode/utf8"
+
+	"github.com/psyton/tokenizer"
+)
+
+// Position encodings of the Language Server Protocol, see SemanticTokensEncoder.SetPositionEncoding.
+const (
+	UTF8  = "utf-8"
+	UTF16 = "utf-16"
+	UTF32 = "utf-32"
+)
+
+// Legend is the LSP SemanticTokensLegend: the server capability which describes indexes of types and modifiers.
+type Legend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// semanticType is the type index and the modifiers bitset of tokens.
+type semanticType struct {
+	typ       uint32
+	modifiers uint32
+}
+
+// SemanticTokensEncoder converts the stream into `textDocument/semanticTokens` data
+// given the mapping from token keys to semantic token types and modifiers.
+type SemanticTokensEncoder struct {
+	legend   Legend
+	keys     map[tokenizer.TokenKey]semanticType
+	strings  map[tokenizer.TokenKey]semanticType
+	encoding string
+}
+
+// NewSemanticTokensEncoder creates the encoder without mappings, positions are encoded in UTF-16 code units.
+func NewSemanticTokensEncoder() *SemanticTokensEncoder {
+	return &SemanticTokensEncoder{
+		keys:     map[tokenizer.TokenKey]semanticType{},
+		strings:  map[tokenizer.TokenKey]semanticType{},
+		encoding: UTF16,
+	}
+}
+
+// SetPositionEncoding sets units of characters: UTF8 (bytes), UTF16 (code units, default) or UTF32 (runes).
+func (e *SemanticTokensEncoder) SetPositionEncoding(encoding string) *SemanticTokensEncoder {
+	e.encoding = encoding
+	return e
+}
+
+// Map sets the semantic type and modifiers of tokens with the key, like `keyword`, `operator` or `number`.
+// Types and modifiers are added to the legend in order of the first use. Tokens without the mapping are skipped.
+func (e *SemanticTokensEncoder) Map(key tokenizer.TokenKey, typ string, modifiers ...string) *SemanticTokensEncoder {
+	e.keys[key] = e.semanticType(typ, modifiers)
+	return e
+}
+
+// MapString sets the semantic type and modifiers of framed strings and comments with the string key
+// (see tokenizer.Token.StringKey), like `string` or `comment`.
+func (e *SemanticTokensEncoder) MapString(key tokenizer.TokenKey, typ string, modifiers ...string) *SemanticTokensEncoder {
+	e.strings[key] = e.semanticType(typ, modifiers)
+	return e
+}
+
+// Legend returns types and modifiers used by mappings.
+func (e *SemanticTokensEncoder) Legend() Legend {
+	return e.legend
+}
+
+func (e *SemanticTokensEncoder) semanticType(typ string, modifiers []string) semanticType {
+	st := semanticType{typ: index(&e.legend.TokenTypes, typ)}
+	for _, m := range modifiers {
+		st.modifiers |= 1 << index(&e.legend.TokenModifiers, m)
+	}
+	return st
+}
+
+// index returns the index of the name in the list, the name is appended if not found.
+func index(list *[]string, name string) uint32 {
+	for i, n := range *list {
+		if n == name {
+			return uint32(i)
+		}
+	}
+	*list = append(*list, name)
+	return uint32(len(*list) - 1)
+}
+
+// Encode returns the delta-encoded data of semantic tokens from the current token to the end of the stream:
+// five integers per token — delta line, delta start character, length, type index and modifiers bitset.
+// Positions are computed from the source reconstructed from tokens, comments and whitespaces,
+// tokens over several lines are split by lines. The stream is drained, like tokenizer.Stream.Tokens.
+func (e *SemanticTokensEncoder) Encode(s *tokenizer.Stream) []uint32 {
+	enc := semanticEncoder{units: e.encoding}
+	for _, t := range s.Tokens() {
+		e.encodeTrivia(&enc, t.Trivia())
+		st, ok := e.keys[t.Key()]
+		if t.IsString() {
+			if sst, found := e.strings[t.StringKey()]; found {
+				st, ok = sst, true
+			}
+		}
+		enc.piece(t.Value(), st, ok)
+	}
+	e.encodeTrivia(&enc, s.TrailingTrivia())
+	return enc.data
+}
+
+func (e *SemanticTokensEncoder) encodeTrivia(enc *semanticEncoder, trivia []tokenizer.Trivia) {
+	for _, tr := range trivia {
+		st, ok := e.strings[tr.Key]
+		if !ok {
+			st, ok = e.keys[tr.Key]
+		}
+		enc.piece(tr.Value, st, ok && tr.IsComment())
+	}
+}
+
+// semanticEncoder tracks the position in the source and the position of the previous semantic token.
+type semanticEncoder struct {
+	units              string
+	line, char         uint32
+	prevLine, prevChar uint32
+	data               []uint32
+}
+
+// piece advances the position over the value and emits semantic tokens for each line of the value if `emit` is true.
+func (e *semanticEncoder) piece(value []byte, st semanticType, emit bool) {
+	for len(value) > 0 {
+		n := 0
+		for n < len(value) && value[n] != '\n' {
+			n++
+		}
+		segment := value[:n]
+		if len(segment) > 0 && segment[len(segment)-1] == '\r' {
+			segment = segment[:len(segment)-1]
+		}
+		length := e.length(segment)
+		if emit && length > 0 {
+			deltaChar := e.char
+			if e.line == e.prevLine {
+				deltaChar -= e.prevChar
+			}
+			e.data = append(e.data, e.line-e.prevLine, deltaChar, length, st.typ, st.modifiers)
+			e.prevLine, e.prevChar = e.line, e.char
+		}
+		if n < len(value) {
+			e.line++
+			e.char = 0
+			value = value[n+1:]
+		} else {
+			e.char += length
+			value = nil
+		}
+	}
+}
+
+// length returns the length of the value in units of the position encoding.
+func (e *semanticEncoder) length(value []byte) uint32 {
+	switch e.units {
+	case UTF8:
+		return uint32(len(value))
+	case UTF32:
+		return uint32(utf8.RuneCount(value))
+	}
+	n := uint32(0)
+	for len(value) > 0 {
+		r, size := utf8.DecodeRune(value)
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+		value = value[size:]
+	}
+	return n
+}
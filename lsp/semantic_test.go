@@ -0,0 +1,48 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	tokenOperator tokenizer.TokenKey = iota + 1
+	tokenString
+	tokenComment
+)
+
+func TestSemanticTokensEncoder(t *testing.T) {
+	parser := tokenizer.New()
+	parser.DefineTokens(tokenOperator, []string{"=", "+"})
+	parser.DefineStringToken(tokenString, `"`, `"`)
+	parser.DefineStringToken(tokenComment, "/*", "*/")
+	parser.DefineTrivia(tokenComment)
+
+	enc := NewSemanticTokensEncoder().
+		Map(tokenOperator, "operator").
+		Map(tokenizer.TokenInteger, "number").
+		Map(tokenizer.TokenFloat, "number").
+		MapString(tokenString, "string", "readonly").
+		MapString(tokenComment, "comment", "documentation", "readonly")
+	require.Equal(t, Legend{
+		TokenTypes:     []string{"operator", "number", "string", "comment"},
+		TokenModifiers: []string{"readonly", "documentation"},
+	}, enc.Legend())
+
+	source := "/* a\n b */ x = \"😀\" + 1\n  y = 2.5"
+	require.Equal(t, []uint32{
+		0, 0, 4, 3, 0b11, // "/* a"
+		1, 0, 5, 3, 0b11, // " b */"
+		0, 8, 1, 0, 0, // "="
+		0, 2, 4, 2, 0b1, // "😀" with quotes is 4 UTF-16 units
+		0, 5, 1, 0, 0, // "+"
+		0, 2, 1, 1, 0, // "1"
+		1, 4, 1, 0, 0, // "="
+		0, 2, 3, 1, 0, // "2.5"
+	}, enc.Encode(parser.ParseString(source)))
+
+	data := enc.SetPositionEncoding(UTF8).Encode(parser.ParseString(`"😀" + 1`))
+	require.Equal(t, []uint32{0, 0, 6, 2, 1, 0, 7, 1, 0, 0, 0, 2, 1, 1, 0}, data)
+}
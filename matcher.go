@@ -0,0 +1,149 @@
+package tokenizer
+
+// TokenMatcher matches tokens of the stream starting from the token `t`.
+// The token `t` is nil if the stream is ended.
+// Matcher returns true and the token after the matched tokens (nil if the stream is ended) if tokens are matched.
+// Use Key, Any, Value, KeyValue, AnyToken, Seq, Or, Optional, ZeroOrMore and OneOrMore to build matchers.
+type TokenMatcher func(s *Stream, t *Token) (*Token, bool)
+
+// Key matches one token with the key.
+func Key(key TokenKey) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		if t != nil && t.Is(key) {
+			return s.nextOf(t), true
+		}
+		return nil, false
+	}
+}
+
+// Any matches one token with any of these keys.
+func Any(keys ...TokenKey) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		if t != nil && len(keys) > 0 && t.Is(keys[0], keys[1:]...) {
+			return s.nextOf(t), true
+		}
+		return nil, false
+	}
+}
+
+// Value matches one token with the value.
+func Value(value string) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		if t != nil && t.IsValid() && t.ValueString() == value {
+			return s.nextOf(t), true
+		}
+		return nil, false
+	}
+}
+
+// KeyValue matches one token with the key and the value, like the operator of the grammar.
+func KeyValue(key TokenKey, value string) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		if t != nil && t.Is(key) && t.ValueString() == value {
+			return s.nextOf(t), true
+		}
+		return nil, false
+	}
+}
+
+// AnyToken matches any one token.
+func AnyToken() TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		if t != nil && t.IsValid() {
+			return s.nextOf(t), true
+		}
+		return nil, false
+	}
+}
+
+// Seq matches the sequence of matchers.
+func Seq(matchers ...TokenMatcher) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		var ok bool
+		for _, m := range matchers {
+			if t, ok = m(s, t); !ok {
+				return nil, false
+			}
+		}
+		return t, true
+	}
+}
+
+// Or matches the first matched matcher of these matchers.
+func Or(matchers ...TokenMatcher) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		for _, m := range matchers {
+			if next, ok := m(s, t); ok {
+				return next, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// Optional matches the matcher zero or one time.
+func Optional(m TokenMatcher) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		if next, ok := m(s, t); ok {
+			return next, true
+		}
+		return t, true
+	}
+}
+
+// ZeroOrMore matches the matcher as many times as possible, including zero times.
+func ZeroOrMore(m TokenMatcher) TokenMatcher {
+	return func(s *Stream, t *Token) (*Token, bool) {
+		for t != nil {
+			next, ok := m(s, t)
+			if !ok || next == t { // stop on empty match too
+				break
+			}
+			t = next
+		}
+		return t, true
+	}
+}
+
+// OneOrMore matches the matcher as many times as possible, at least one time.
+func OneOrMore(m TokenMatcher) TokenMatcher {
+	return Seq(m, ZeroOrMore(m))
+}
+
+// Match checks if tokens from the current token match the matcher.
+// Method returns the matched span of token ids: `start` is id of the first matched token,
+// `end` is id of the token after the last matched token. The pointer stays unchanged.
+func (s *Stream) Match(m TokenMatcher) (start, end int, ok bool) {
+	if !s.IsValid() {
+		return 0, 0, false
+	}
+	next, ok := m(s, s.current)
+	if !ok {
+		return 0, 0, false
+	}
+	start, end = s.span(s.current, next)
+	return start, end, true
+}
+
+// Find searches the first sequence of tokens matching matchers (see Seq) from the current token to the end,
+// like all comparisons with the field: `stream.Find(Value("user_agent"), Key(TokenCompare))`.
+// Method returns the matched span of token ids like Match, so next occurrences may be found via `stream.GoTo(end).Find(...)`.
+// The pointer stays unchanged. In the streaming mode data will be parsed until the sequence is found.
+func (s *Stream) Find(seq ...TokenMatcher) (start, end int, ok bool) {
+	m := Seq(seq...)
+	for t := s.current; isToken(t); t = s.nextOf(t) {
+		if next, ok := m(s, t); ok {
+			start, end = s.span(t, next)
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// span returns ids of the first matched token and the token after the last matched token `next`.
+func (s *Stream) span(first, next *Token) (start, end int) {
+	if next != nil {
+		return first.id, next.id
+	}
+	return first.id, s.lastToken().id + 1
+}
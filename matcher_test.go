@@ -0,0 +1,72 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamMatch(t *testing.T) {
+	compareKey := TokenKey(10)
+	condKey := TokenKey(11)
+	tokenizer := New()
+	tokenizer.AllowKeywordUnderscore()
+	tokenizer.DefineTokens(compareKey, []string{">=", "<=", "==", ">", "<", "="})
+	tokenizer.DefineTokens(condKey, []string{"and", "or"})
+	tokenizer.DefineStringToken(TokenKey(14), "'", "'")
+	stream := tokenizer.ParseString("bytes_in <= 100 or user_agent='curl' and not")
+
+	comparison := Seq(Key(TokenKeyword), Key(compareKey), Any(TokenInteger, TokenString))
+
+	start, end, ok := stream.Match(comparison)
+	require.True(t, ok)
+	require.Equal(t, 0, start)
+	require.Equal(t, 3, end)
+	require.Equal(t, 0, stream.CurrentToken().ID())
+
+	expression := Seq(comparison, ZeroOrMore(Seq(Key(condKey), comparison)), Optional(Seq(Key(condKey), Value("not"))))
+	start, end, ok = stream.Match(expression)
+	require.True(t, ok)
+	require.Equal(t, 0, start)
+	require.Equal(t, 9, end)
+
+	_, _, ok = stream.Match(Seq(comparison, Key(condKey), Value("user_agent"), Key(compareKey), Value("'agent'")))
+	require.False(t, ok)
+
+	start, end, ok = stream.GoTo(7).Match(Seq(Key(condKey), AnyToken(), Optional(AnyToken())))
+	require.True(t, ok)
+	require.Equal(t, 7, start)
+	require.Equal(t, 9, end)
+
+	_, _, ok = stream.Match(OneOrMore(Key(compareKey)))
+	require.False(t, ok)
+	start, end, ok = stream.Match(Or(OneOrMore(Key(compareKey)), OneOrMore(Any(TokenKeyword, condKey))))
+	require.True(t, ok)
+	require.Equal(t, 7, start)
+	require.Equal(t, 9, end)
+}
+
+func TestStreamFind(t *testing.T) {
+	compareKey := TokenKey(10)
+	tokenizer := New()
+	tokenizer.DefineTokens(compareKey, []string{"==", "<", ">"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"and", "or"})
+	stream := tokenizer.ParseString("size > 10 and user == 1 or user < 5")
+	defer stream.Close()
+
+	comparison := []TokenMatcher{Value("user"), Key(compareKey), AnyToken()}
+	var spans [][2]int
+	for start, end, ok := stream.Find(comparison...); ok; start, end, ok = stream.GoTo(end).Find(comparison...) {
+		spans = append(spans, [2]int{start, end})
+	}
+	require.Equal(t, [][2]int{{4, 7}, {8, 11}}, spans)
+	require.False(t, stream.IsValid())
+
+	start, end, ok := stream.GoTo(0).Find(KeyValue(compareKey, "<"), Key(TokenInteger))
+	require.True(t, ok)
+	require.Equal(t, 9, start)
+	require.Equal(t, 11, end)
+	_, _, ok = stream.Find(KeyValue(compareKey, "=="), Value("5"))
+	require.False(t, ok)
+	require.Equal(t, 0, stream.CurrentToken().ID())
+}
@@ -0,0 +1,33 @@
+package tokenizer
+
+import (
+	"io"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeNFC enables normalization of the input to the Unicode normalization form C before matching,
+// so `é` typed as `e` with the combining acute accent (U+0065 U+0301) matches tokens and keywords
+// with the precomposed `é` (U+00E9), like identifiers pasted from different editors.
+// The input is copied only if it isn't normalized, offsets and values of tokens refer to the normalized input then.
+// Literals of the grammar should be in NFC, Compile reports other literals because they are never matched.
+func (t *Tokenizer) NormalizeNFC() *Tokenizer {
+	t.flags |= fNormalizeNFC
+	return t
+}
+
+// normalize returns the input in NFC if the normalization is enabled, see NormalizeNFC.
+func (t *Tokenizer) normalize(data []byte) []byte {
+	if t.flags&fNormalizeNFC == 0 || norm.NFC.IsNormal(data) {
+		return data
+	}
+	return norm.NFC.Bytes(data)
+}
+
+// normalizeReader returns the reader of the input in NFC if the normalization is enabled, see NormalizeNFC.
+func (t *Tokenizer) normalizeReader(r io.Reader) io.Reader {
+	if t.flags&fNormalizeNFC == 0 {
+		return r
+	}
+	return norm.NFC.Reader(r)
+}
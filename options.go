@@ -0,0 +1,139 @@
+package tokenizer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures the tokenizer created by New, like WithStopOnUnknown or WithMaxTokenLength(4096).
+// Options complement chained methods of the tokenizer: invalid values of options are ignored
+// and reported at once by Tokenizer.Compile.
+type Option func(t *Tokenizer)
+
+// KeywordSettings describes the symbols allowed in keywords, see WithKeywordSettings.
+type KeywordSettings struct {
+	// Underscore allows underscore symbol in keywords, see Tokenizer.AllowKeywordUnderscore.
+	Underscore bool
+	// Numbers allows numbers in keywords, see Tokenizer.AllowNumbersInKeyword.
+	Numbers bool
+}
+
+// WithStopOnUnknown stops parsing if unknown token detected, see Tokenizer.StopOnUndefinedToken.
+func WithStopOnUnknown() Option {
+	return func(t *Tokenizer) {
+		t.StopOnUndefinedToken()
+	}
+}
+
+// WithKeywordSettings sets the symbols allowed in keywords.
+func WithKeywordSettings(settings KeywordSettings) Option {
+	return func(t *Tokenizer) {
+		if settings.Underscore {
+			t.AllowKeywordUnderscore()
+		}
+		if settings.Numbers {
+			t.AllowNumbersInKeyword()
+		}
+	}
+}
+
+// WithWhiteSpaces sets custom whitespace symbols between tokens, see Tokenizer.SetWhiteSpaces.
+// Empty whitespaces are invalid.
+func WithWhiteSpaces(ws ...byte) Option {
+	return func(t *Tokenizer) {
+		if len(ws) == 0 {
+			t.invalidOption("WithWhiteSpaces", "whitespaces are empty")
+			return
+		}
+		t.SetWhiteSpaces(ws)
+	}
+}
+
+// WithMaxBytes limits the size of the input, see Tokenizer.SetMaxBytes.
+func WithMaxBytes(n int) Option {
+	return func(t *Tokenizer) {
+		if t.validLimit("WithMaxBytes", n) {
+			t.SetMaxBytes(n)
+		}
+	}
+}
+
+// WithMaxDuration limits the time spent for parsing, see Tokenizer.SetMaxDuration.
+func WithMaxDuration(d time.Duration) Option {
+	return func(t *Tokenizer) {
+		if d < 0 {
+			t.invalidOption("WithMaxDuration", fmt.Sprintf("negative limit %s", d))
+			return
+		}
+		t.SetMaxDuration(d)
+	}
+}
+
+// WithMaxTokenLength limits the length of any single token, see Tokenizer.SetMaxTokenLength.
+func WithMaxTokenLength(n int) Option {
+	return func(t *Tokenizer) {
+		if t.validLimit("WithMaxTokenLength", n) {
+			t.SetMaxTokenLength(n)
+		}
+	}
+}
+
+// WithMaxTokens limits the total count of tokens produced per parse, see Tokenizer.SetMaxTokens.
+func WithMaxTokens(n int) Option {
+	return func(t *Tokenizer) {
+		if t.validLimit("WithMaxTokens", n) {
+			t.SetMaxTokens(n)
+		}
+	}
+}
+
+// WithMaxDepth limits the nesting depth of injections in framed strings, see Tokenizer.SetMaxDepth.
+func WithMaxDepth(n int) Option {
+	return func(t *Tokenizer) {
+		if t.validLimit("WithMaxDepth", n) {
+			t.SetMaxDepth(n)
+		}
+	}
+}
+
+// WithCollectStats enables collecting of the detailed stats, see Tokenizer.CollectStats.
+func WithCollectStats() Option {
+	return func(t *Tokenizer) {
+		t.CollectStats()
+	}
+}
+
+// WithCopyValues enables copying of values and indents of tokens during parsing, see Tokenizer.CopyValues.
+func WithCopyValues() Option {
+	return func(t *Tokenizer) {
+		t.CopyValues()
+	}
+}
+
+// WithFoldKeywords enables recording of values of keywords in lower case, see Tokenizer.FoldKeywords.
+func WithFoldKeywords() Option {
+	return func(t *Tokenizer) {
+		t.FoldKeywords()
+	}
+}
+
+// WithNormalizeNFC enables normalization of the input to NFC, see Tokenizer.NormalizeNFC.
+func WithNormalizeNFC() Option {
+	return func(t *Tokenizer) {
+		t.NormalizeNFC()
+	}
+}
+
+// validLimit checks that the limit of the option isn't negative.
+func (t *Tokenizer) validLimit(option string, n int) bool {
+	if n < 0 {
+		t.invalidOption(option, fmt.Sprintf("negative limit %d", n))
+		return false
+	}
+	return true
+}
+
+// invalidOption records the ignored option, see Compile.
+func (t *Tokenizer) invalidOption(option, what string) {
+	t.invalid = append(t.invalid, invalidDefinition{key: TokenUndef, what: fmt.Sprintf("option %s: %s", option, what)})
+}
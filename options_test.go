@@ -0,0 +1,49 @@
+package tokenizer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	tokenizer := New(
+		WithStopOnUnknown(),
+		WithKeywordSettings(KeywordSettings{Underscore: true, Numbers: true}),
+		WithWhiteSpaces(' '),
+		WithMaxBytes(100),
+		WithMaxDuration(time.Second),
+		WithMaxTokenLength(10),
+		WithMaxTokens(20),
+		WithMaxDepth(2),
+		WithCollectStats(),
+		WithCopyValues(),
+	)
+	chained := New().StopOnUndefinedToken().AllowKeywordUnderscore().AllowNumbersInKeyword().
+		SetWhiteSpaces([]byte{' '}).SetMaxBytes(100).SetMaxDuration(time.Second).SetMaxTokenLength(10).
+		SetMaxTokens(20).SetMaxDepth(2).CollectStats().CopyValues()
+	require.Equal(t, chained.flags, tokenizer.flags)
+	require.Equal(t, chained.limits, tokenizer.limits)
+	require.Equal(t, chained.wSpaces, tokenizer.wSpaces)
+
+	_, err := tokenizer.Compile()
+	require.NoError(t, err)
+
+	stream := tokenizer.ParseString("one_1 two2")
+	defer stream.Close()
+	require.Equal(t, "one_1", stream.CurrentToken().ValueString())
+	require.Equal(t, "two2", stream.GoNext().CurrentToken().ValueString())
+}
+
+func TestNewWithInvalidOptions(t *testing.T) {
+	tokenizer := New(WithMaxTokenLength(-1), WithWhiteSpaces(), WithMaxDuration(-time.Second), WithMaxDepth(3))
+	require.Zero(t, tokenizer.limits.tokenLength)
+	require.Equal(t, defaultWhiteSpaces, tokenizer.wSpaces)
+	require.Equal(t, 3, tokenizer.limits.depth)
+
+	_, err := tokenizer.Compile()
+	require.EqualError(t, err, "option WithMaxTokenLength: negative limit -1\n"+
+		"option WithWhiteSpaces: whitespaces are empty\n"+
+		"option WithMaxDuration: negative limit -1s")
+}
@@ -0,0 +1,202 @@
+package tokenizer
+
+import (
+	"bytes"
+	"sync"
+)
+
+// ParseBytesParallel parse the bytes slice into tokens like ParseBytes on `workers` goroutines.
+// The data is split into parts at new lines, parts are parsed concurrently and stitched into one stream
+// with the same offsets, lines and ids of tokens as ParseBytes produces. If the split is inside the framed string
+// (like multi-line strings or comments), both parts are parsed again as one part.
+// Parsing is sequential if the tokenizer has hooks (see OnToken), the logger, limits, regex literals, signed numbers
+// or signed spellings of AllowInfNaN, because they depend on the order of tokens, or if tokens contain the new line
+// before the end.
+func (t *Tokenizer) ParseBytesParallel(data []byte, workers int) *Stream {
+	if workers < 2 || !t.parallel() {
+		return t.ParseBytes(data)
+	}
+	data = t.normalize(data) // offsets of parts refer to the normalized data
+	bounds := splitLines(data, workers)
+	parts := make([]*parsing, len(bounds)-1)
+	var wg sync.WaitGroup
+	for i := range parts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parts[i] = t.parsePart(data[bounds[i]:bounds[i+1]])
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < len(parts)-1; {
+		if !parts[i].unclosed && parts[i].error() == nil {
+			i++
+			continue
+		}
+		// the split is inside the string or the injection
+		parts[i].free()
+		parts[i+1].free()
+		parts[i] = t.parsePart(data[bounds[i]:bounds[i+2]])
+		parts = append(parts[:i+1], parts[i+2:]...)
+		bounds = append(bounds[:i+1], bounds[i+2:]...)
+	}
+	return stitch(t, data, bounds, parts)
+}
+
+// parallel checks if parts of data may be parsed independently.
+func (t *Tokenizer) parallel() bool {
+	if len(t.hooks) > 0 || t.logger != nil || t.limits != (limits{}) || t.flags&fSignedNumbers != 0 {
+		return false
+	}
+	for _, q := range t.quotes {
+		if q.Regex {
+			return false
+		}
+	}
+	for _, spelling := range t.infNaN {
+		if spelling[0] == '-' || spelling[0] == '+' {
+			return false // the sign depends on the previous token like in signed numbers
+		}
+	}
+	for _, refs := range t.tokens {
+		for _, ref := range refs {
+			if i := bytes.IndexByte(ref.Token, newLine); i >= 0 && i < len(ref.Token)-1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parsePart parses the part of data to the end.
+func (t *Tokenizer) parsePart(data []byte) *parsing {
+	p := newParser(t, data)
+	p.parseTokens()
+	return p
+}
+
+// free releases tokens of the finished parser and the parser.
+func (p *parsing) free() {
+	for ptr := p.head; ptr != nil; {
+		next := ptr.next
+		p.t.freeToken(ptr)
+		ptr = next
+	}
+	for _, tr := range p.trivia {
+		p.t.freeToken(tr)
+	}
+	p.release()
+}
+
+// first returns the first token or trivia token of the finished parser in source order.
+func (p *parsing) first() *Token {
+	if p.head != nil {
+		if len(p.head.trivia) > 0 {
+			return p.head.trivia[0]
+		}
+		return p.head
+	}
+	if len(p.trivia) > 0 {
+		return p.trivia[0]
+	}
+	return nil
+}
+
+// splitLines returns offsets of `n` parts of data with similar sizes, each part except the last ends with the new line.
+// The first offset is zero, the last offset is the length of data.
+func splitLines(data []byte, n int) []int {
+	bounds := []int{0}
+	for i := 1; i < n; i++ {
+		from := len(data) * i / n
+		if last := bounds[len(bounds)-1]; from < last {
+			from = last
+		}
+		nl := bytes.IndexByte(data[from:], newLine)
+		if nl < 0 {
+			break
+		}
+		if end := from + nl + 1; end < len(data) {
+			bounds = append(bounds, end)
+		}
+	}
+	return append(bounds, len(data))
+}
+
+// stitch links tokens of parsed parts into one stream and shifts offsets, lines and ids of tokens of parts.
+// Whitespaces and trivia after the last token of the part belong to the first token of the next part.
+func stitch(t *Tokenizer, data []byte, bounds []int, parts []*parsing) *Stream {
+	s := &Stream{t: t, parsed: len(data)}
+	var last *Token
+	var trivia []*Token
+	tail := 0 // offset of whitespaces after the last token
+	line, id := 0, 0
+	for i, p := range parts {
+		start := bounds[i]
+		for ptr := p.head; ptr != nil; ptr = ptr.next {
+			ptr.shift(start, line, id)
+			for _, tr := range ptr.trivia {
+				tr.shift(start, line, id)
+			}
+		}
+		for _, tr := range p.trivia {
+			tr.shift(start, line, id)
+		}
+		if first := p.first(); first != nil {
+			first.indent = data[tail:first.offset]
+		}
+		if p.head != nil {
+			p.head.trivia = append(trivia, p.head.trivia...)
+			trivia = nil
+			if last == nil {
+				s.head = p.head
+			} else {
+				last.addNext(p.head)
+			}
+			last = p.ptr
+			tail = last.offset + len(last.value)
+		}
+		if n := len(p.trivia); n > 0 {
+			trivia = append(trivia, p.trivia...)
+			tail = p.trivia[n-1].offset + len(p.trivia[n-1].value)
+		}
+		s.stats.merge(p.statistics())
+		if i == len(parts)-1 {
+			s.err = p.error()
+		}
+		line += countNewLines(data[start:bounds[i+1]])
+		id += p.n
+		p.release()
+	}
+	s.len = id
+	s.current = s.head
+	s.trivia = trivia
+	s.wsTail = data[tail:]
+	s.stats.Bytes = len(data)
+	return s
+}
+
+// shift moves the token of the part of data which starts at `offset` byte, `line` is the count of lines before the part.
+func (t *Token) shift(offset, line, id int) {
+	t.offset += offset
+	t.line += line
+	t.id += id
+}
+
+// merge adds stats of the part of data.
+func (st *Stats) merge(part Stats) {
+	st.Tokens += part.Tokens
+	st.Trivia += part.Trivia
+	st.Lines += part.Lines
+	st.Duration += part.Duration
+	if part.Longest > st.Longest {
+		st.Longest = part.Longest
+	}
+	if part.Keys != nil {
+		if st.Keys == nil {
+			st.Keys = map[TokenKey]int{}
+		}
+		for k, v := range part.Keys {
+			st.Keys[k] += v
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytesParallel(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"=", "{{", ";"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`).AddInjection(10, 11)
+	tokenizer.DefineStringToken(TokenKey(21), "/*", "*/")
+	tokenizer.DefineStringToken(TokenKey(22), "//", "\n")
+	tokenizer.DefineTrivia(TokenKey(21), TokenKey(22))
+
+	sources := []string{
+		"",
+		"one\n",
+		"\n\n  a = 1;\n",
+		strings.Repeat("key = \"value\" // comment\n\tnext = 10.5;\n", 50),
+		strings.Repeat("a = \"multi\nline {{ b\n }} string\";\n/* block\ncomment */\n", 20) + "  \n",
+		strings.Repeat("// only comments\n", 10),
+		"x = \"unclosed\n" + strings.Repeat("y\n", 10),
+	}
+	for _, src := range sources {
+		expected := tokenizer.ParseString(src)
+		for _, workers := range []int{2, 3, 4, 8, 16} {
+			stream := tokenizer.ParseBytesParallel([]byte(src), workers)
+			require.Equal(t, expected.String(), stream.String(), "%d workers of %q", workers, src)
+			require.Equal(t, expected.TrailingTrivia(), stream.TrailingTrivia(), "%d workers of %q", workers, src)
+			require.Equal(t, expected.Stats(), stream.Stats())
+			require.Equal(t, expected.Err(), stream.Err())
+			for e, a := expected.HeadToken(), stream.HeadToken(); isToken(e); e, a = expected.nextOf(e), stream.nextOf(a) {
+				require.Equal(t, e.Column(), a.Column())
+				require.Equal(t, e.Trivia(), a.Trivia())
+				if e.next == nil {
+					require.Nil(t, a.next)
+					break
+				}
+			}
+			require.Equal(t, src, NewRewriter(stream).String())
+			stream.Close()
+		}
+		expected.Close()
+	}
+
+	// tokens depend on the previous token
+	require.True(t, tokenizer.parallel())
+	require.False(t, tokenizer.Clone().AllowSignedNumbers().parallel())
+	require.False(t, tokenizer.Clone().AllowInfNaN().parallel())
+	require.True(t, tokenizer.Clone().AllowInfNaN("Inf", "NaN").parallel())
+	regex := tokenizer.Clone()
+	regex.DefineRegexToken(TokenKey(23))
+	require.False(t, regex.parallel())
+}
+
+func TestParseBytesParallelStats(t *testing.T) {
+	tokenizer := New().CollectStats()
+	tokenizer.DefineTokens(TokenKey(10), []string{"="})
+	src := []byte(strings.Repeat("key = value\n", 100) + "last")
+
+	require.Equal(t, []int{0, 612, 1204}, splitLines(src, 2))
+	require.Equal(t, []int{0, 4}, splitLines([]byte("last"), 4))
+	expected, stream := tokenizer.ParseBytes(src).Stats(), tokenizer.ParseBytesParallel(src, 4).Stats()
+	require.Equal(t, expected.Keys, stream.Keys)
+	require.Equal(t, 101, stream.Lines)
+	require.Equal(t, expected.Longest, stream.Longest)
+	require.Equal(t, expected.Tokens, stream.Tokens)
+}
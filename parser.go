@@ -1,7 +1,12 @@
 package tokenizer
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"sync"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -9,37 +14,93 @@ import (
 // DefaultChunkSize default chunk size for reader.
 const DefaultChunkSize = 4096
 
+// sizes of blocks of tokens allocated by the parser
+const (
+	minSlabSize = 16
+	maxSlabSize = 1024
+)
+
+// size of blocks of folded values of keywords, see foldValue
+const foldedBlockSize = 4096
+
 // parsing is main parser
 type parsing struct {
-	t         *Tokenizer
-	curr      byte
-	pos       int
-	line      int
-	str       []byte
-	err       error
-	reader    io.Reader
-	token     *Token
-	head      *Token
-	ptr       *Token
-	tail      []byte
-	stopKeys  []*tokenRef
-	n         int // tokens id generator
-	chunkSize int // chunks size for infinite buffer
-	offset    int
-	resume    bool
-	parsed    int
-}
-
-// newParser creates new parser for string
+	t           *Tokenizer
+	g           *Tokenizer // grammar of the current region, differs from t in injections with own tokenizer
+	curr        byte
+	pos         int
+	line        int
+	str         []byte
+	err         error
+	stop        error           // the error which aborted parsing, like the error of the context
+	ctx         context.Context // checked periodically to abort parsing, see Tokenizer.ParseBytesCtx
+	steps       int             // count of iterations of the parsing loop
+	depth       int             // nesting depth of injections
+	started     time.Time       // start of the current parsing of data-chunks
+	spent       time.Duration   // time spent for parsing of previous data-chunks
+	reader      io.Reader
+	stats       Stats // counters of trivia, keys and duration, see Stream.Stats
+	token       *Token
+	head        *Token
+	ptr         *Token
+	tail        []byte
+	trivia      []*Token // pending trivia tokens for the next token
+	lineStart   int      // offset of the beginning of the current line
+	tokenLine   int      // offset of the beginning of the line of the next token
+	stopKeys    []*tokenRef
+	stopAfter   int // stops parsing of the injection when the token with this id is parsed
+	n           int // tokens id generator
+	chunkSize   int // chunks size for infinite buffer
+	offset      int
+	resume      bool
+	parsed      int
+	tokenLength int        // limit of the length of tokens, see Tokenizer.SetMaxTokenLength and StreamOptions
+	unclosed    bool       // the framed string isn't closed at the end of data
+	discard     bool       // emitted tokens are released instead of linking, see Tokenizer.Count
+	slab        []Token    // block of new tokens, see alloc
+	slabSize    int        // size of the last block of tokens
+	folded      []byte     // block of folded values of keywords, see foldValue
+	arena       Arena      // allocator of tokens instead of the pool and slabs, see Tokenizer.ParseBytesArena
+	file        string     // file name of the source for log events
+	mu          sync.Mutex // locks parser if the stream is forked
+}
+
+// newParser creates new parser for string, the parser is taken from the pool of the tokenizer (see release).
 func newParser(t *Tokenizer, str []byte) *parsing {
-	tok := t.allocToken()
-	tok.line = 1
-	return &parsing{
-		t:     t,
-		str:   str,
-		line:  1,
-		token: tok,
+	return newArenaParser(t, nil, str)
+}
+
+// newArenaParser creates new parser for string which allocates tokens from the arena if it's not nil.
+func newArenaParser(t *Tokenizer, arena Arena, str []byte) *parsing {
+	p, _ := t.parsers.Get().(*parsing)
+	if p == nil {
+		p = &parsing{}
+	}
+	p.t = t
+	if arena != nil {
+		// the rest of the slab of the pooled parser is allocated from the heap
+		p.arena, p.slab, p.slabSize = arena, nil, 0
 	}
+	p.str = t.normalize(str)
+	p.line = 1
+	p.token = p.alloc()
+	p.token.line = 1
+	p.init()
+	return p
+}
+
+// release returns the finished parser of the bytes slice to the pool of the tokenizer.
+// The rest of the slab of tokens is kept for the next parser. The parser must not be used after release.
+func (p *parsing) release() {
+	t := p.t
+	slab, size := p.slab, p.slabSize
+	if p.arena != nil {
+		slab, size = nil, 0
+	} else {
+		t.freeToken(p.token)
+	}
+	*p = parsing{slab: slab, slabSize: size}
+	t.parsers.Put(p)
 }
 
 func newInfParser(t *Tokenizer, reader io.Reader, bufferSize uint) *parsing {
@@ -49,14 +110,25 @@ func newInfParser(t *Tokenizer, reader io.Reader, bufferSize uint) *parsing {
 	buffer := make([]byte, bufferSize)
 	tok := t.allocToken()
 	tok.line = 1
-	return &parsing{
+	p := &parsing{
 		t:         t,
 		str:       buffer,
-		reader:    reader,
+		reader:    t.normalizeReader(reader),
 		line:      1,
 		chunkSize: int(bufferSize),
 		token:     tok,
 	}
+	p.init()
+	return p
+}
+
+// init prepares collecting of stats (see Tokenizer.CollectStats) and limits.
+func (p *parsing) init() {
+	p.g = p.t
+	p.tokenLength = p.t.limits.tokenLength
+	if p.t.flags&fCollectStats != 0 {
+		p.stats.Keys = map[TokenKey]int{}
+	}
 }
 
 func (p *parsing) prev() {
@@ -67,14 +139,11 @@ func (p *parsing) prev() {
 }
 
 func (p *parsing) ensureBytes(n int) bool {
-	if p.pos+n >= len(p.str) {
-		if p.reader != nil {
-			p.loadChunk()
-			if p.pos+n < len(p.str) {
-				return true
-			}
+	// the lookahead may be longer than the data-chunk
+	for p.pos+n >= len(p.str) {
+		if p.reader == nil || p.loadChunk() == 0 {
+			return false
 		}
-		return false
 	}
 	return true
 }
@@ -105,38 +174,32 @@ func (p *parsing) slice(from, to int) []byte {
 }
 
 func (p *parsing) preload() {
-	n, err := p.reader.Read(p.str)
-	if n < p.chunkSize {
-		p.str = p.str[:n]
-		p.reader = nil
-	}
+	p.str = p.str[:p.read(p.str)]
+}
+
+// read fills the buffer from the reader, so short reads of the reader aren't the end of data.
+// The reader is released at the end of data or on the error.
+func (p *parsing) read(buf []byte) int {
+	n, err := io.ReadFull(p.reader, buf)
 	if err != nil {
 		p.reader = nil
-		if err != io.EOF {
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
 			p.err = err
 		}
 	}
+	return n
 }
 
 func (p *parsing) loadChunk() int {
+	if p.aborted() {
+		p.reader = nil
+		return 0
+	}
 	// chunk size = new chunk size + size of tail of prev chunk
 	chunk := make([]byte, len(p.str)+p.chunkSize)
 	copy(chunk, p.str)
-	n, err := p.reader.Read(chunk[len(p.str):])
-
-	if n < p.chunkSize {
-		p.str = chunk[:len(p.str)+n]
-		p.reader = nil
-	} else {
-		p.str = chunk
-	}
-
-	if err != nil {
-		p.reader = nil
-		if err != io.EOF {
-			p.err = err
-		}
-	}
+	n := p.read(chunk[len(p.str):])
+	p.str = chunk[:len(p.str)+n]
 	p.resume = false
 	return n
 }
@@ -157,14 +220,18 @@ func (p *parsing) checkPoint() bool {
 
 // parse bytes (p.str) to tokens and append them to the end if stream of tokens.
 func (p *parsing) parse() {
-	if len(p.str) == 0 {
+	if p.stop != nil {
+		return
+	}
+	if p.pos >= len(p.str) {
 		if p.reader == nil || p.loadChunk() == 0 { // if it's not infinite stream or this is the end of stream
 			return
 		}
 	}
 	p.curr = p.str[p.pos]
 	p.resume = true
-	for p.checkPoint() {
+	// the injection is parsed to the end regardless of data-chunks
+	for p.checkPoint() || p.depth > 0 {
 		if p.stopKeys != nil {
 			for _, t := range p.stopKeys {
 				if p.ptr.key == t.Key {
@@ -172,10 +239,40 @@ func (p *parsing) parse() {
 				}
 			}
 		}
+		if p.stopAfter > 0 && p.n >= p.stopAfter {
+			return
+		}
+		if p.steps++; p.steps%checkSteps == 0 && p.aborted() || p.checkLimits() {
+			return
+		}
 		p.parseWhitespace()
 		if p.curr == 0 {
 			break
 		}
+		if p.parseRegex() {
+			continue
+		}
+		if p.g.uuid != TokenUndef && p.parseUUID() {
+			continue
+		}
+		if (p.g.ip != TokenUndef || p.g.cidr != TokenUndef) && p.parseIP() {
+			continue
+		}
+		if len(p.g.dateTimes) > 0 && p.parseDateTime() {
+			continue
+		}
+		if p.g.duration != TokenUndef && p.parseDuration() {
+			continue
+		}
+		if p.g.flags&fSignedNumbers != 0 && (p.curr == '-' || p.curr == '+') && p.parseSignedNumber() {
+			continue
+		}
+		if p.g.flags&fLeadingDotFloat != 0 && p.curr == '.' && p.parseNumber() {
+			continue
+		}
+		if p.g.flags&fInfNaN != 0 && p.parseInfNaN() {
+			continue
+		}
 		if p.parseToken() {
 			continue
 		}
@@ -200,13 +297,20 @@ func (p *parsing) parse() {
 		if p.curr == 0 {
 			break
 		}
-		if p.t.flags&fStopOnUnknown != 0 {
+		if p.g.flags&fStopOnUnknown != 0 {
+			if p.t.logs(LogWarning) {
+				p.logf(LogWarning, p.offset+p.pos, "stop on unknown byte %q", p.curr)
+			}
 			break
 		}
+		if p.t.logs(LogTrace) {
+			p.logf(LogTrace, p.offset+p.pos, "unknown byte %q: no token, keyword, number or string starts with it", p.curr)
+		}
 		p.token.key = TokenUnknown
 		p.token.value = p.str[p.pos : p.pos+1]
 		p.token.offset = p.offset + p.pos
 		p.next()
+		p.newLines(p.token.value)
 		p.emmitToken()
 		if p.curr == 0 {
 			break
@@ -214,6 +318,83 @@ func (p *parsing) parse() {
 	}
 	if len(p.token.indent) > 0 {
 		p.tail = p.token.indent
+		if p.t.flags&fCopyValues != 0 {
+			p.tail = bytes.Clone(p.tail)
+		}
+	}
+}
+
+// checkSteps is the count of iterations of the parsing loop between checks of the context.
+const checkSteps = 1024
+
+// aborted checks if the context is done and sets the stop error.
+func (p *parsing) aborted() bool {
+	if p.ctx != nil && p.stop == nil {
+		select {
+		case <-p.ctx.Done():
+			p.stop = p.ctx.Err()
+		default:
+		}
+	}
+	return p.stop != nil
+}
+
+// error returns the error which stopped parsing.
+func (p *parsing) error() error {
+	if p.stop != nil {
+		return p.stop
+	}
+	return p.err
+}
+
+// unterminated stops parsing with UnterminatedInjectionError at the start token of the injection.
+func (p *parsing) unterminated(open Token) {
+	// the injection may be left open across lines, so the position is of the token, not of the current line
+	pos := open.Position()
+	pos.File = p.file
+	p.stop = &UnterminatedInjectionError{Token: open, Position: pos}
+	if p.t.logs(LogWarning) {
+		p.t.logger.Log(LogWarning, pos, fmt.Sprintf("injection opened by %q isn't closed", open.value))
+	}
+}
+
+// trailingDotNumber stops parsing with TrailingDotError at the number which starts at `start`, see TrailingDotReject.
+func (p *parsing) trailingDotNumber(start int) {
+	number := Token{
+		key:    TokenFloat,
+		value:  bytes.Clone(p.str[start : p.pos+1]),
+		line:   p.line,
+		offset: p.offset + start,
+	}
+	number.column = number.offset - p.tokenLine + 1
+	p.stop = &TrailingDotError{Token: number, Position: p.position(number.offset)}
+	if p.t.logs(LogWarning) {
+		p.logf(LogWarning, number.offset, "number %q has the trailing dot", number.value)
+	}
+}
+
+// parseTokens parses data-chunks until at least one token is added to the stream or the data is over.
+// Data-chunks may contain only trivia tokens.
+func (p *parsing) parseTokens() {
+	if p.stats.Keys != nil || p.t.limits.duration > 0 {
+		p.started = time.Now()
+		defer p.measure()
+	}
+	if p.t.flags&fProfileLabels != 0 {
+		p.labeled(labelsParse, p.parseLoop)
+		return
+	}
+	p.parseLoop()
+}
+
+func (p *parsing) parseLoop() {
+	n := p.n
+	for {
+		parsed := p.offset + p.pos
+		p.parse()
+		if p.n > n || p.offset+p.pos == parsed {
+			return
+		}
 	}
 }
 
@@ -221,7 +402,7 @@ func (p *parsing) parseWhitespace() bool {
 	var start = -1
 	for p.curr != 0 {
 		var matched = false
-		for _, ws := range p.t.wSpaces {
+		for _, ws := range p.g.wSpaces {
 			if p.curr == ws {
 				if start == -1 {
 					start = p.pos
@@ -234,12 +415,13 @@ func (p *parsing) parseWhitespace() bool {
 			break
 		}
 		if p.curr == newLine {
-			p.line++
+			p.newLine()
 		}
 		p.next()
 	}
 	if start != -1 {
 		p.token.line = p.line
+		p.tokenLine = p.lineStart
 		p.token.indent = p.str[start:p.pos]
 		return true
 	}
@@ -247,6 +429,9 @@ func (p *parsing) parseWhitespace() bool {
 }
 
 func (p *parsing) parseKeyword() bool {
+	if p.prefixedQuote() {
+		return false
+	}
 	var start = -1
 	for p.curr != 0 {
 		var r rune
@@ -254,11 +439,13 @@ func (p *parsing) parseKeyword() bool {
 		p.ensureBytes(4)
 		r, size = utf8.DecodeRune(p.slice(p.pos, p.pos+4))
 		if unicode.IsLetter(r) ||
-			(p.t.flags&fAllowKeywordUnderscore != 0 && p.curr == '_') ||
-			(p.t.flags&fAllowNumberInKeyword != 0 && start != -1 && isNumberByte(p.curr)) {
+			(p.g.flags&fAllowKeywordUnderscore != 0 && p.curr == '_') ||
+			(p.g.flags&fAllowNumberInKeyword != 0 && start != -1 && isNumberByte(p.curr)) {
 
 			if start == -1 {
 				start = p.pos
+			} else if p.tooLong(start) {
+				return true
 			}
 			p.pos += size - 1 // rune may be more than 1 byte
 		} else {
@@ -270,6 +457,9 @@ func (p *parsing) parseKeyword() bool {
 		p.token.key = TokenKeyword
 		p.token.value = p.str[start:p.pos]
 		p.token.offset = p.offset + start
+		if p.t.logs(LogTrace) {
+			p.logf(LogTrace, p.token.offset, "keyword %q", p.token.value)
+		}
 		p.emmitToken()
 		return true
 	}
@@ -282,21 +472,122 @@ const (
 	stagePower
 )
 
+// parseSignedNumber parses the number with the unary sign at the current position, see Tokenizer.AllowSignedNumbers.
+// The position is unchanged if the sign isn't unary.
+func (p *parsing) parseSignedNumber() bool {
+	if !p.numberAfter(1) || p.signOperand() {
+		return false
+	}
+	sign := p.pos
+	p.next()
+	return p.parseNumberFrom(sign)
+}
+
+// signOperand checks if the last token is the operand, so the sign after it is the binary operator.
+func (p *parsing) signOperand() bool {
+	if p.ptr == nil {
+		return false
+	}
+	switch p.ptr.key {
+	case TokenKeyword, TokenInteger, TokenFloat, TokenString, TokenChar:
+		return true
+	}
+	for _, key := range p.g.signOperands {
+		if p.ptr.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseInfNaN parses the spelling of infinity or not-a-number at the current position, see Tokenizer.AllowInfNaN.
+func (p *parsing) parseInfNaN() bool {
+	for _, spelling := range p.g.infNaN {
+		if spelling[0] != p.curr || !p.match(spelling, false, false) || p.isWordRune(len(spelling)) {
+			continue
+		}
+		if (p.curr == '-' || p.curr == '+') && p.signOperand() {
+			return false
+		}
+		start := p.pos
+		p.pos += len(spelling) - 1
+		p.next()
+		p.token.key = TokenFloat
+		p.token.value = p.str[start:p.pos]
+		p.token.offset = p.offset + start
+		if p.t.logs(LogTrace) {
+			p.logf(LogTrace, p.token.offset, "number %q (%s)", p.token.value, p.g.KeyName(p.token.key))
+		}
+		p.emmitToken()
+		return true
+	}
+	return false
+}
+
+// thousandsGroup checks if the separator of thousands at the current position is followed by exactly 3 digits.
+func (p *parsing) thousandsGroup() bool {
+	for i := 1; i <= 3; i++ {
+		if !p.ensureBytes(i) || !isNumberByte(p.str[p.pos+i]) {
+			return false
+		}
+	}
+	return !p.ensureBytes(4) || !isNumberByte(p.str[p.pos+4])
+}
+
+// numberAfter checks if the number starts `n` bytes after the current position:
+// the digit or the dot followed by the digit, see Tokenizer.AllowLeadingDotFloat.
+func (p *parsing) numberAfter(n int) bool {
+	if !p.ensureBytes(n) {
+		return false
+	}
+	if b := p.str[p.pos+n]; isNumberByte(b) {
+		return true
+	} else if b != '.' || p.g.flags&fLeadingDotFloat == 0 {
+		return false
+	}
+	return p.ensureBytes(n+1) && isNumberByte(p.str[p.pos+n+1])
+}
+
 func (p *parsing) parseNumber() bool {
+	return p.parseNumberFrom(-1)
+}
+
+// parseNumberFrom parses the number, the value starts from the position of the sign if it isn't negative.
+func (p *parsing) parseNumberFrom(sign int) bool {
 	var start = -1
 	var needNumber = true
+	// the offset of digits after the start and the first separator of thousands
+	var digits, grouped = 0, false
+	if sign >= 0 {
+		digits = 1
+	}
 
 	var stage uint8 = 0
 	for p.curr != 0 {
+		if start != -1 && p.tooLong(start) {
+			return true
+		}
 		if isNumberByte(p.curr) {
 			needNumber = false
 			if start == -1 {
 				if stage == 0 {
 					stage = stageCoefficient
 					start = p.pos
+					if sign >= 0 {
+						start = sign
+					}
 				}
 			}
-		} else if p.t.flags&fAllowNumberUnderscore != 0 && p.curr == '_' {
+			if start != -1 && p.tokenLength == 0 {
+				p.skipDigits()
+			}
+		} else if p.g.thousands != 0 && p.curr == p.g.thousands {
+			// the first group has 1-3 digits, others have 3, see Tokenizer.SetThousandsSeparator
+			if stage != stageCoefficient || !grouped && p.pos-start-digits > 3 || !p.thousandsGroup() {
+				break
+			}
+			grouped = true
+		} else if p.g.flags&fAllowNumberUnderscore != 0 && p.curr == '_' {
 			if stage != stageCoefficient {
 				break
 			}
@@ -305,8 +596,22 @@ func (p *parsing) parseNumber() bool {
 			if stage != stageCoefficient {
 				break
 			}
+			if p.g.trailingDot != TrailingDotFloat && !isNumberByte(p.nextByte()) {
+				if p.g.trailingDot == TrailingDotReject {
+					p.trailingDotNumber(start)
+					return true
+				}
+				break // the dot is the next token, see TrailingDotInteger
+			}
 			stage = stageMantissa
 			needNumber = true
+		} else if stage == 0 && p.curr == '.' && p.numberAfter(0) {
+			// the float without the integer part, see Tokenizer.AllowLeadingDotFloat
+			stage = stageMantissa
+			start = p.pos
+			if sign >= 0 {
+				start = sign
+			}
 		} else if !needNumber && (p.curr == 'e' || p.curr == 'E') {
 			if stage != stageMantissa && stage != stageCoefficient {
 				break
@@ -342,13 +647,19 @@ func (p *parsing) parseNumber() bool {
 		p.token.key = TokenFloat
 		p.token.offset = p.offset + start
 	}
+	if len(p.g.units) > 0 && p.parseUnit(start) {
+		return true
+	}
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "number %q (%s)", p.token.value, p.g.KeyName(p.token.key))
+	}
 	p.emmitToken()
 	return true
 }
 
 func (p *parsing) isWhitespace(pos int) bool {
 	if p.ensureBytes(pos - p.pos + 1) {
-		for _, w := range p.t.wSpaces {
+		for _, w := range p.g.wSpaces {
 			if p.str[pos] == w {
 				return true
 			}
@@ -388,12 +699,52 @@ func (p *parsing) match(r []byte, seek bool, checkWhitespaces bool) bool {
 	return false
 }
 
+// matchToken compares next bytes from data with the user defined token according to its settings.
+func (p *parsing) matchToken(ref *tokenRef, seek bool) bool {
+	if !ref.Fold && !ref.Word && ref.Mode == "" {
+		return p.match(ref.Token, seek, ref.IsFull)
+	}
+	if ref.Mode != "" && !p.g.modes[ref.Mode] {
+		return false
+	}
+	n := len(ref.Token)
+	if !p.ensureBytes(n - 1) {
+		return false
+	}
+	if ref.Fold && !bytes.EqualFold(ref.Token, p.str[p.pos:p.pos+n]) || !ref.Fold && !p.match(ref.Token, false, false) {
+		return false
+	}
+	if ref.IsFull && n > 1 && !p.isWhitespace(p.pos+n) || ref.Word && p.isWordRune(n) {
+		return false
+	}
+	if seek {
+		p.pos += n - 1
+		p.next()
+	}
+	return true
+}
+
+// isWordRune checks if the rune `n` bytes after the current position is the letter, digit or underscore.
+func (p *parsing) isWordRune(n int) bool {
+	if !p.ensureBytes(n) {
+		return false
+	}
+	p.ensureBytes(n + 3)
+	r, _ := utf8.DecodeRune(p.slice(p.pos+n, p.pos+n+4))
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
 // parseQuote parses quoted string.
 func (p *parsing) parseQuote() bool {
 	var quote *StringSettings
 	var start = p.pos
-	for _, q := range p.t.quotes {
-		if p.match(q.StartToken, true, false) {
+	for _, q := range p.g.quotes {
+		if q.Char && p.parseChar(q) {
+			return true
+		}
+	}
+	for _, q := range p.g.quotes {
+		if !q.Char && !q.Regex && p.match(q.StartToken, true, false) {
 			quote = q
 			break
 		}
@@ -401,22 +752,58 @@ func (p *parsing) parseQuote() bool {
 	if quote == nil {
 		return false
 	}
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.offset+start, "string %s opened by %q", p.g.KeyName(quote.Key), quote.StartToken)
+	}
 	p.token.key = TokenString
 	p.token.offset = p.offset + start
 	p.token.string = quote
-	escapes := false
+	escapes, closed := false, false
+	fast := quote.Injects == nil && p.tokenLength == 0
 	for p.curr != 0 {
+		if p.stop != nil || p.tooLong(start) { // the limit is exceeded, maybe in the injection
+			p.token.key = TokenUndef
+			p.token.string = nil
+			return true
+		}
+		if fast && !escapes && p.skipString(quote) {
+			continue
+		}
+		if quote.SingleLine && !escapes && p.curr == newLine {
+			break
+		}
 		if escapes {
 			escapes = false
 		} else if p.curr == quote.EscapeSymbol {
 			escapes = true
+		} else if quote.DoubledEscape && p.matchDoubled(quote.EndToken) {
+			continue
 		} else if p.match(quote.EndToken, true, false) {
+			p.newLines(quote.EndToken)
+			closed = true
+			if p.t.logs(LogTrace) {
+				p.logf(LogTrace, p.offset+p.pos-len(quote.EndToken), "string %s closed by %q", p.g.KeyName(quote.Key), quote.EndToken)
+			}
 			break
 		} else if quote.Injects != nil {
 			loop := true
 			for _, inject := range quote.Injects {
-				for _, token := range p.t.tokens[inject.StartKey] {
+				for _, token := range p.g.tokens[inject.StartKey] {
+					if quote.DoubledInjection && p.matchDoubled(token.Token) {
+						// escaped start token is the part of the string
+						loop = false
+						break
+					}
 					if p.match(token.Token, true, false) {
+						if p.t.limits.depth > 0 && p.depth >= p.t.limits.depth {
+							p.exceed(LimitDepth, int64(p.t.limits.depth), p.position(p.offset+p.pos-len(token.Token)))
+							p.token.key = TokenUndef
+							p.token.string = nil
+							return true
+						}
+						if p.t.logs(LogTrace) {
+							p.logf(LogTrace, p.offset+p.pos-len(token.Token), "injection opened by %q in string %s", token.Token, p.g.KeyName(quote.Key))
+						}
 						p.token.key = TokenStringFragment
 						p.token.value = p.str[start : p.pos-len(token.Token)]
 						p.emmitToken()
@@ -424,10 +811,37 @@ func (p *parsing) parseQuote() bool {
 						p.token.value = token.Token
 						p.token.offset = p.offset + p.pos - len(token.Token)
 						p.emmitToken()
-						stopKeys := p.stopKeys // may be recursive quotes
-						p.stopKeys = p.t.tokens[inject.EndKey]
-						p.parse()
-						p.stopKeys = stopKeys
+						open := p.ptr.copy()
+						stopKeys, stopAfter := p.stopKeys, p.stopAfter // may be recursive quotes
+						p.stopKeys, p.stopAfter = p.g.tokens[inject.EndKey], 0
+						if inject.EndKey == TokenUndef {
+							p.stopAfter = p.n + 1
+						}
+						g := p.g
+						if inject.Tokenizer != nil {
+							p.g = inject.Tokenizer
+						}
+						p.depth++
+						if p.t.flags&fProfileLabels != 0 {
+							p.labeled(labelsInjection, p.parse)
+						} else {
+							p.parse()
+						}
+						p.depth--
+						p.g = g
+						if p.stop == nil && p.curr == 0 && (inject.EndKey == TokenUndef && p.n < p.stopAfter ||
+							inject.EndKey != TokenUndef && p.ptr.key != inject.EndKey) {
+							p.unterminated(open)
+						}
+						p.stopKeys, p.stopAfter = stopKeys, stopAfter
+						if p.stop != nil {
+							p.token.key = TokenUndef
+							p.token.string = nil
+							return true
+						}
+						if p.t.logs(LogTrace) {
+							p.logf(LogTrace, p.ptr.offset, "injection closed by %q in string %s", p.ptr.value, p.g.KeyName(quote.Key))
+						}
 						p.token.key = TokenStringFragment
 						p.token.offset = p.offset + p.pos
 						p.token.string = quote
@@ -440,40 +854,343 @@ func (p *parsing) parseQuote() bool {
 					break
 				}
 			}
+			if !loop {
+				// the current byte is right after the injection
+				continue
+			}
 		}
 		if p.curr == newLine {
-			p.line++
+			p.newLine()
 		}
 		p.next()
 	}
+	p.unclosed = !closed
+	if !closed && p.t.logs(LogWarning) {
+		end := "data"
+		if p.curr == newLine {
+			end = "line"
+		}
+		p.logf(LogWarning, p.offset+p.pos, "string %s isn't closed by %q at the end of %s", p.g.KeyName(quote.Key), quote.EndToken, end)
+	}
 	p.token.value = p.str[start:p.pos]
 	p.emmitToken()
 	return true
 }
 
+// skipString skips bytes of the framed string before the first byte of the end token, the escape symbol
+// or the new line of the single-line string in the current data-chunk via bytes.IndexByte instead of matching byte by byte. New lines of skipped bytes are counted.
+// Method returns false if there is nothing to skip.
+func (p *parsing) skipString(q *StringSettings) bool {
+	rest := p.str[p.pos:]
+	n := bytes.IndexByte(rest, q.EndToken[0])
+	if n < 0 {
+		n = len(rest)
+	}
+	if q.EscapeSymbol != 0 {
+		if i := bytes.IndexByte(rest[:n], q.EscapeSymbol); i >= 0 {
+			n = i
+		}
+	}
+	if q.SingleLine {
+		if i := bytes.IndexByte(rest[:n], newLine); i >= 0 {
+			n = i
+		}
+	}
+	if n == 0 {
+		return false
+	}
+	if last := bytes.LastIndexByte(rest[:n], newLine); last >= 0 {
+		p.line += countNewLines(rest[:last+1])
+		p.lineStart = p.offset + p.pos + last + 1
+	}
+	p.pos += n - 1
+	p.next()
+	return true
+}
+
+// skipDigits skips digits after the current digit in the current data-chunk,
+// the current byte is the last digit of the run.
+func (p *parsing) skipDigits() {
+	for p.pos+1 < len(p.str) && isNumberByte(p.str[p.pos+1]) {
+		p.pos++
+	}
+	p.curr = p.str[p.pos]
+}
+
+// parseChar parses the char literal of `q` at the current position.
+// The position is unchanged if the char literal doesn't start here.
+func (p *parsing) parseChar(q *StringSettings) bool {
+	if !p.match(q.StartToken, false, false) {
+		return false
+	}
+	i := len(q.StartToken)
+	if !p.ensureBytes(i + len(q.EndToken)) {
+		return false
+	}
+	size := 0
+	if p.str[p.pos+i] == q.EscapeSymbol && q.EscapeSymbol != 0 {
+		size = 2
+		switch p.str[p.pos+i+1] {
+		case 'x':
+			size = 4
+		case 'u':
+			size = 6
+		case 'U':
+			size = 10
+		}
+		if !p.ensureBytes(i + size + len(q.EndToken) - 1) {
+			return false
+		}
+		for _, b := range p.str[p.pos+i+2 : p.pos+i+size] {
+			if !isHexByte(b) {
+				return false
+			}
+		}
+	} else {
+		p.ensureBytes(i + 3)
+		r, n := utf8.DecodeRune(p.slice(p.pos+i, p.pos+i+4))
+		if r == utf8.RuneError && n <= 1 || r == newLine || bytesStarts(q.EndToken, p.str[p.pos+i:]) {
+			return false
+		}
+		size = n
+	}
+	end := i + size
+	if !p.ensureBytes(end+len(q.EndToken)-1) || !bytesStarts(q.EndToken, p.str[p.pos+end:]) {
+		return false
+	}
+	end += len(q.EndToken)
+	p.token.key = TokenChar
+	p.token.value = p.str[p.pos : p.pos+end]
+	p.token.offset = p.offset + p.pos
+	p.token.string = q
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "char %q (%s)", p.token.value, p.g.KeyName(q.Key))
+	}
+	p.pos += end - 1
+	p.next()
+	p.emmitToken()
+	return true
+}
+
+// parseRegex parses the regex literal /pattern/flags in the expression position, see Tokenizer.DefineRegexToken.
+func (p *parsing) parseRegex() bool {
+	for _, q := range p.g.quotes {
+		if q.Regex && p.curr == q.StartToken[0] && !p.operand(q) && p.matchRegex(q) {
+			return true
+		}
+	}
+	return false
+}
+
+// operand checks if the last token is the operand, so the regex literal `q` isn't expected after it.
+func (p *parsing) operand(q *StringSettings) bool {
+	if p.ptr == nil {
+		return false
+	}
+	switch p.ptr.key {
+	case TokenKeyword, TokenInteger, TokenFloat, TokenString, TokenChar:
+		return true
+	}
+	for _, key := range q.Operands {
+		if p.ptr.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRegex parses the regex literal of `q` at the current position.
+// The position is unchanged if the pattern isn't terminated on the line.
+func (p *parsing) matchRegex(q *StringSettings) bool {
+	i, class := 1, false
+	for ; ; i++ {
+		if !p.ensureBytes(i) || p.str[p.pos+i] == newLine {
+			return false
+		}
+		b := p.str[p.pos+i]
+		if b == q.EscapeSymbol && q.EscapeSymbol != 0 {
+			i++
+		} else if b == '[' {
+			class = true
+		} else if b == ']' {
+			class = false
+		} else if b == q.EndToken[0] && !class {
+			break
+		}
+	}
+	if i == 1 { // `//` is not the empty pattern
+		return false
+	}
+	i++
+	for p.ensureBytes(i) && isLetterByte(p.str[p.pos+i]) {
+		i++
+	}
+	if p.tokenLength > 0 && i > p.tokenLength {
+		p.exceed(LimitTokenLength, int64(p.tokenLength), p.position(p.offset+p.pos))
+		return true
+	}
+	p.token.key = TokenString
+	p.token.value = p.str[p.pos : p.pos+i]
+	p.token.offset = p.offset + p.pos
+	p.token.string = q
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "regex %q (%s)", p.token.value, p.g.KeyName(q.Key))
+	}
+	p.pos += i - 1
+	p.next()
+	p.emmitToken()
+	return true
+}
+
+// matchDoubled checks if `r` is repeated twice at the current position and skips both.
+func (p *parsing) matchDoubled(r []byte) bool {
+	if !p.match(r, false, false) || !p.ensureBytes(2*len(r)-1) {
+		return false
+	}
+	if b2s(p.str[p.pos+len(r):p.pos+2*len(r)]) != b2s(r) {
+		return false
+	}
+	p.pos += 2*len(r) - 1
+	p.next()
+	return true
+}
+
 // parseToken search any rune sequence from tokenItem.
 func (p *parsing) parseToken() bool {
 	if p.curr != 0 {
-		toks := p.t.index[p.curr]
+		toks := p.g.index[p.curr]
 		if toks != nil {
 			start := p.pos
+			quoteLen := p.quoteStartLen()
 			for _, t := range toks {
-				if p.match(t.Token, true, t.IsFull) {
+				if len(t.Token) < quoteLen {
+					// the longer start of framed string wins, like `--` comment over `-` token
+					if p.t.logs(LogTrace) {
+						p.logf(LogTrace, p.offset+start, "token %q (%s) skipped: the start of string is longer", t.Token, p.g.KeyName(t.Key))
+					}
+					continue
+				}
+				if t.Priority < 0 && p.matchToken(t, false) && p.keywordLen() > len(t.Token) {
+					// the token with negative priority yields to the longer keyword, like `in` in `int`
+					if p.t.logs(LogTrace) {
+						p.logf(LogTrace, p.offset+start, "token %q (%s) skipped: the keyword is longer", t.Token, p.g.KeyName(t.Key))
+					}
+					continue
+				}
+				if p.matchToken(t, true) {
+					if p.t.logs(LogTrace) {
+						p.logf(LogTrace, p.offset+start, "token %q (%s) matched", t.Token, p.g.KeyName(t.Key))
+					}
 					p.token.key = t.Key
 					p.token.offset = p.offset + start
 					p.token.value = t.Token
+					if t.Fold {
+						p.token.value = p.str[start:p.pos]
+					}
+					p.newLines(t.Token)
 					p.emmitToken()
 					return true
 				}
+				if p.t.logs(LogTrace) {
+					if t.IsFull && p.match(t.Token, false, false) {
+						p.logf(LogTrace, p.offset+start, "token %q (%s) isn't matched: it isn't followed by whitespace", t.Token, p.g.KeyName(t.Key))
+					} else {
+						p.logf(LogTrace, p.offset+start, "token %q (%s) isn't matched", t.Token, p.g.KeyName(t.Key))
+					}
+				}
 			}
 		}
 	}
 	return false
 }
 
+// keywordLen returns the length of the keyword at the current position or zero. The position is unchanged.
+func (p *parsing) keywordLen() int {
+	n := 0
+	for p.ensureBytes(n) {
+		p.ensureBytes(n + 3)
+		r, size := utf8.DecodeRune(p.slice(p.pos+n, p.pos+n+4))
+		b := p.str[p.pos+n]
+		if unicode.IsLetter(r) ||
+			(p.g.flags&fAllowKeywordUnderscore != 0 && b == '_') ||
+			(p.g.flags&fAllowNumberInKeyword != 0 && n > 0 && isNumberByte(b)) {
+			n += size
+		} else {
+			break
+		}
+	}
+	return n
+}
+
+// quoteStartLen returns the length of the longest start token of framed strings at the current position or zero.
+func (p *parsing) quoteStartLen() int {
+	n := 0
+	for _, q := range p.g.quotes {
+		if len(q.StartToken) > n && p.match(q.StartToken, false, false) {
+			n = len(q.StartToken)
+		}
+	}
+	return n
+}
+
+// prefixedQuote checks if the framed string with the prefix starts at the current position,
+// so the prefix isn't parsed as the keyword.
+func (p *parsing) prefixedQuote() bool {
+	for _, q := range p.g.quotes {
+		if len(q.Prefix) > 0 && p.match(q.StartToken, false, false) {
+			return true
+		}
+	}
+	return false
+}
+
 // emmitToken add new p.token to stream
 func (p *parsing) emmitToken() {
-	if p.ptr == nil {
+	if p.stop != nil || p.tooMany() {
+		p.token.key = TokenUndef
+		p.token.value = nil
+		p.token.string = nil
+		return
+	}
+	p.token.column = p.token.offset - p.tokenLine + 1
+	if p.t.flags&fCopyValues != 0 {
+		p.copyValue()
+	}
+	if p.t.flags&fFoldKeywords != 0 && p.token.key == TokenKeyword {
+		p.foldValue()
+	}
+	if len(p.t.hooks) > 0 && !p.hook() {
+		p.token.key = TokenUndef
+		p.token.value = nil
+		p.token.string = nil
+		p.token.data = nil
+		p.token.indent = nil
+		p.token.line = p.line
+		p.tokenLine = p.lineStart
+		return
+	}
+	if len(p.g.trivia) > 0 {
+		if p.token.hasAnyKey(p.g.trivia) {
+			p.stats.Trivia++
+			p.count(p.token)
+			p.trivia = append(p.trivia, p.token)
+			p.token = p.alloc()
+			p.token.id = p.n
+			p.token.line = p.line
+			p.tokenLine = p.lineStart
+			return
+		}
+		p.token.trivia = p.trivia
+		p.trivia = nil
+	}
+	p.count(p.token)
+	if p.discard {
+		// only the last token is kept for checks of injections
+		if p.ptr != nil {
+			p.t.freeToken(p.ptr)
+		}
+		p.ptr = p.token
+	} else if p.ptr == nil {
 		p.ptr = p.token
 		p.head = p.ptr
 	} else {
@@ -481,7 +1198,119 @@ func (p *parsing) emmitToken() {
 		p.ptr = p.token
 	}
 	p.n++
-	p.token = p.t.allocToken()
+	p.token = p.alloc()
 	p.token.id = p.n
 	p.token.line = p.line
+	p.tokenLine = p.lineStart
+}
+
+// copyValue copies the indent and the value of the token into one new buffer, see Tokenizer.CopyValues.
+func (p *parsing) copyValue() {
+	n := len(p.token.indent)
+	buf := make([]byte, n+len(p.token.value))
+	copy(buf, p.token.indent)
+	copy(buf[n:], p.token.value)
+	p.token.indent, p.token.value = buf[:n:n], buf[n:]
+}
+
+// foldValue records the value of the keyword in lower case, see Tokenizer.FoldKeywords.
+// Only values with upper-case letters are folded, they are appended to the block shared by tokens of the parser,
+// so there is no allocation per token.
+func (p *parsing) foldValue() {
+	v := p.token.value
+	i := 0
+	for ; i < len(v) && v[i] < utf8.RuneSelf; i++ {
+		if 'A' <= v[i] && v[i] <= 'Z' {
+			break
+		}
+	}
+	if i == len(v) {
+		return
+	}
+	if cap(p.folded)-len(p.folded) < 2*len(v) {
+		size := foldedBlockSize
+		if size < 2*len(v) {
+			size = 2 * len(v)
+		}
+		p.folded = make([]byte, 0, size)
+	}
+	start := len(p.folded)
+	p.folded = append(p.folded, v[:i]...)
+	for _, r := range b2s(v[i:]) {
+		p.folded = utf8.AppendRune(p.folded, unicode.ToLower(r))
+	}
+	if b2s(p.folded[start:]) == b2s(v) {
+		p.folded = p.folded[:start]
+		return
+	}
+	p.token.folded = p.folded[start:len(p.folded):len(p.folded)]
+}
+
+// alloc returns the released token from the pool of the tokenizer or the new token from the slab of the parser,
+// so tokens are allocated by blocks instead of one by one. Blocks grow up to maxSlabSize tokens.
+// The block is garbage collected only when all its tokens are unreachable, so the token which outlives its stream
+// keeps the whole block, see Token.Copy. The parser with the arena allocates blocks from the arena only.
+func (p *parsing) alloc() *Token {
+	if p.arena == nil {
+		if token, ok := p.t.pool.Get().(*Token); ok {
+			return token
+		}
+	}
+	if len(p.slab) == 0 {
+		p.slabSize *= 2
+		if p.slabSize < minSlabSize {
+			p.slabSize = minSlabSize
+		} else if p.slabSize > maxSlabSize {
+			p.slabSize = maxSlabSize
+		}
+		if p.arena != nil {
+			p.slab = p.arena.AllocTokens(p.slabSize)
+		} else {
+			p.slab = make([]Token, p.slabSize)
+		}
+	}
+	token := &p.slab[0]
+	p.slab = p.slab[1:]
+	return token
+}
+
+// reserve allocates the slab for n tokens at once if the rest of the slab is smaller, see Tokenizer.ParseBytesHint.
+func (p *parsing) reserve(n int) {
+	if n > len(p.slab) {
+		p.slab = make([]Token, n)
+		if p.slabSize < maxSlabSize {
+			p.slabSize = maxSlabSize
+		}
+	}
+}
+
+// newLine counts the new line at the current position.
+func (p *parsing) newLine() {
+	p.line++
+	p.lineStart = p.offset + p.pos + 1
+}
+
+// newLines counts new lines of the value which ends at the current position.
+func (p *parsing) newLines(value []byte) {
+	for i, b := range value {
+		if b == newLine {
+			p.line++
+			p.lineStart = p.offset + p.pos - len(value) + i + 1
+		}
+	}
+}
+
+// logf sends the event at the offset of the current line to the logger, see Tokenizer.SetLogger.
+func (p *parsing) logf(level LogLevel, offset int, format string, args ...interface{}) {
+	p.t.logger.Log(level, p.position(offset), fmt.Sprintf(format, args...))
+}
+
+// position returns the position of the offset of the current line.
+func (p *parsing) position(offset int) Position {
+	return Position{File: p.file, Line: p.line, Column: offset - p.lineStart + 1, Offset: offset}
+}
+
+// tokenPosition returns the position of the offset on the line where the next token starts.
+func (p *parsing) tokenPosition(offset int) Position {
+	return Position{File: p.file, Line: p.token.line, Column: offset - p.tokenLine + 1, Offset: offset}
 }
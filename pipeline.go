@@ -0,0 +1,163 @@
+package tokenizer
+
+// Filter is the stage of the token pipeline, see Stream.Pipe. The filter gets the next stage and returns
+// the function which receives tokens one by one and passes zero or more tokens to the next stage,
+// so filters may skip, merge, split or rewrite tokens. The end of tokens is signaled by the nil token
+// and filters which buffer tokens (like MergeKeys) pass them before passing nil. Tokens are detached copies,
+// a filter may change passed tokens but shouldn't keep them after passing.
+type Filter func(next func(t *Token)) func(t *Token)
+
+// Pipe passes tokens from the current token to the end through filters and returns the stream of resulting tokens.
+// The result is the usual stream with sequential ids and the same tokenizer, so parsers work with it
+// as with the parsed stream. The pointer of the stream stays unchanged, in the streaming mode all data will be parsed.
+//
+//	stream := source.Pipe(tokenizer.SkipKeys(TokenComment), tokenizer.MergeKeys(tokenizer.TokenKeyword))
+func (s *Stream) Pipe(filters ...Filter) *Stream {
+	out := &Stream{t: s.t, file: s.file}
+	var ptr *Token
+	emit := func(t *Token) {
+		if t == nil {
+			return
+		}
+		tok := s.t.detach(t)
+		tok.id = out.len
+		out.len++
+		if ptr == nil {
+			out.head = tok
+		} else {
+			ptr.addNext(tok)
+		}
+		ptr = tok
+	}
+	for i := len(filters) - 1; i >= 0; i-- {
+		emit = filters[i](emit)
+	}
+	for t := s.current; t != nil && t != undefToken; t = s.nextOf(t) {
+		tok := t.copy()
+		emit(&tok)
+	}
+	emit(nil)
+
+	for _, t := range s.trailingTrivia() {
+		out.trivia = append(out.trivia, s.t.detach(t))
+	}
+	out.wsTail = s.tail()
+	if ptr != nil {
+		out.parsed = ptr.offset + len(ptr.value)
+	}
+	out.parsed += len(out.wsTail)
+	out.stats = Stats{Tokens: out.len, Bytes: out.parsed}
+	out.current = out.head
+	return out
+}
+
+// detach returns the copy of the token from the pool with copies of trivia tokens, so the copy may be freed separately.
+func (t *Tokenizer) detach(token *Token) *Token {
+	c := t.allocToken()
+	*c = token.copy()
+	c.trivia = nil
+	for _, tr := range token.trivia {
+		c.trivia = append(c.trivia, t.detach(tr))
+	}
+	return c
+}
+
+// SkipKeys returns the filter which skips tokens with any of the keys, strings are matched by the string key.
+func SkipKeys(keys ...TokenKey) Filter {
+	return func(next func(t *Token)) func(t *Token) {
+		return func(t *Token) {
+			if t == nil || !t.hasAnyKey(keys) {
+				next(t)
+			}
+		}
+	}
+}
+
+// MergeKeys returns the filter which merges adjacent tokens with the same key (any of the keys) into one token,
+// like adjacent string fragments. The value of the merged token includes indents between tokens.
+// Tokens with trivia are not merged with the previous token.
+func MergeKeys(keys ...TokenKey) Filter {
+	return func(next func(t *Token)) func(t *Token) {
+		var merged *Token
+		return func(t *Token) {
+			if merged != nil && t != nil && t.key == merged.key && len(t.trivia) == 0 {
+				value := make([]byte, 0, len(merged.value)+len(t.indent)+len(t.value))
+				value = append(append(append(value, merged.value...), t.indent...), t.value...)
+				merged.value = value
+				merged.folded = nil
+				merged.unit = 0
+				return
+			}
+			if merged != nil {
+				next(merged)
+				merged = nil
+			}
+			if t != nil && t.hasAnyKey(keys) {
+				c := *t
+				merged = &c
+				return
+			}
+			next(t)
+		}
+	}
+}
+
+// RewriteValues returns the filter which replaces values of tokens with any of the keys (all tokens if no keys)
+// by the result of `rewrite`.
+func RewriteValues(rewrite func(t *Token) []byte, keys ...TokenKey) Filter {
+	return func(next func(t *Token)) func(t *Token) {
+		return func(t *Token) {
+			if t != nil && (len(keys) == 0 || t.hasAnyKey(keys)) {
+				t.value = rewrite(t)
+				t.folded = nil
+				t.unit = 0
+			}
+			next(t)
+		}
+	}
+}
+
+// Filtered returns the stream without tokens with any of the keys (strings are matched by the string key),
+// like comments or line breaks. Skipped tokens are not lost: they are the leading trivia of the next token
+// (see Token.Trivia) or trailing trivia of the stream (see Stream.TrailingTrivia), so parsers don't need skip-loops
+// and formatters still can restore the source. The result is the separate stream, see Stream.Pipe.
+func (s *Stream) Filtered(skip ...TokenKey) *Stream {
+	var trailing []*Token
+	out := s.Pipe(skipAsTrivia(skip, &trailing))
+	trivia := make([]*Token, 0, len(trailing)+len(out.trivia))
+	for _, t := range trailing {
+		trivia = append(trivia, s.t.detach(t))
+	}
+	out.trivia = append(trivia, out.trivia...)
+	out.stats.Trivia = len(out.trivia)
+	for t := out.head; t != nil; t = t.next {
+		out.stats.Trivia += len(t.trivia)
+	}
+	return out
+}
+
+// skipAsTrivia returns the filter which moves tokens with any of the keys into trivia of the next token.
+// Skipped tokens after the last token are stored into `trailing`.
+func skipAsTrivia(keys []TokenKey, trailing *[]*Token) Filter {
+	return func(next func(t *Token)) func(t *Token) {
+		var pending []*Token
+		return func(t *Token) {
+			if t == nil {
+				*trailing = pending
+				next(nil)
+				return
+			}
+			if t.hasAnyKey(keys) {
+				c := *t
+				c.trivia = nil
+				pending = append(append(pending, t.trivia...), &c)
+				return
+			}
+			if len(pending) > 0 {
+				t.trivia = append(pending, t.trivia...)
+				pending = nil
+			}
+			next(t)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package tokenizer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamPipe(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, "#", "\n")
+
+	source := tokenizer.ParseString("one two = three  four # comment\n5")
+	defer source.Close()
+	source.GoNext()
+	stream := source.Pipe(
+		SkipKeys(20),
+		MergeKeys(TokenKeyword),
+		RewriteValues(func(t *Token) []byte { return bytes.ToUpper(t.Value()) }, TokenKeyword),
+	)
+	defer stream.Close()
+	require.Equal(t, 1, source.CurrentToken().ID())
+
+	var values []string
+	for ; stream.IsValid(); stream.GoNext() {
+		values = append(values, stream.CurrentToken().ValueString())
+	}
+	require.Equal(t, []string{"TWO", "=", "THREE  FOUR", "5"}, values)
+	require.Equal(t, 2, stream.GoTo(2).CurrentToken().ID())
+	require.Equal(t, 4, stream.len)
+}
+
+func TestStreamFiltered(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{";"})
+	tokenizer.DefineStringToken(20, "//", "\n")
+
+	source := tokenizer.ParseString("one // first\ntwo; // second\n")
+	defer source.Close()
+	stream := source.Filtered(20)
+	defer stream.Close()
+
+	require.Equal(t, "one", stream.CurrentToken().ValueString())
+	require.True(t, stream.GoNext().CurrentToken().Is(TokenKeyword))
+	trivia := stream.CurrentToken().Trivia()
+	require.Len(t, trivia, 2)
+	require.Equal(t, " ", string(trivia[0].Value))
+	require.Equal(t, "// first\n", string(trivia[1].Value))
+	require.True(t, trivia[1].IsComment())
+	require.Equal(t, 1, stream.CurrentToken().ID())
+	require.True(t, stream.GoNext().CurrentToken().Is(10))
+	require.False(t, stream.GoNext().IsValid())
+
+	trailing := stream.TrailingTrivia()
+	require.Len(t, trailing, 2)
+	require.Equal(t, "// second\n", string(trailing[1].Value))
+	require.Equal(t, 2, stream.Stats().Trivia)
+}
@@ -0,0 +1,133 @@
+package presets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/psyton/tokenizer"
+)
+
+// CSV token keys.
+const (
+	// CSVSeparator is the field separator, like `,` or `\t`.
+	CSVSeparator tokenizer.TokenKey = iota + 1
+	// CSVRecordEnd is the end of the record, `\n` or `\r\n`.
+	CSVRecordEnd
+	// CSVQuoted is the quoted field, like "one, ""two""".
+	CSVQuoted
+)
+
+var (
+	// ErrBareQuote is returned by CSVReader if the quote appears in the unquoted field.
+	ErrBareQuote = errors.New(`bare " in non-quoted field`)
+	// ErrQuote is returned by CSVReader if the quoted field is unterminated or has text after the closing quote.
+	ErrQuote = errors.New(`extraneous or missing " in quoted field`)
+)
+
+// CSV creates the tokenizer of delimiter-separated values with the separator, like ',' for CSV or '\t' for TSV.
+// There are no whitespaces: spaces are part of fields. The text of the unquoted field may be split into
+// several tokens (keywords, numbers, unknown tokens), CSVReader joins them into fields.
+// Quoted fields may contain separators and newlines, the quote inside the quoted field is escaped by doubling.
+func CSV(separator byte) *tokenizer.Tokenizer {
+	t := tokenizer.New().SetWhiteSpaces([]byte{})
+	t.DefineTokens(CSVSeparator, []string{string([]byte{separator})}).
+		DefineTokens(CSVRecordEnd, []string{"\r\n", "\n"})
+	t.DefineStringToken(CSVQuoted, `"`, `"`).EscapeByDoubling()
+	return t
+}
+
+// CSVError describes the invalid field and its position.
+type CSVError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVError) Unwrap() error {
+	return e.Err
+}
+
+// CSVReader reads records from the stream of the CSV tokenizer (see CSV).
+type CSVReader struct {
+	s *tokenizer.Stream
+}
+
+// NewCSVReader creates the reader of records from the stream.
+// The stream may read an infinite buffer (see tokenizer.Tokenizer.ParseStream).
+func NewCSVReader(s *tokenizer.Stream) *CSVReader {
+	return &CSVReader{s: s}
+}
+
+// Read reads the next record. Empty lines are skipped. Read returns io.EOF if there are no more records,
+// or *CSVError if the field is invalid.
+func (r *CSVReader) Read() ([]string, error) {
+	for r.s.CurrentToken().Is(CSVRecordEnd) {
+		r.s.GoNext()
+	}
+	if !r.s.IsValid() {
+		return nil, io.EOF
+	}
+	var record []string
+	for {
+		field, err := r.field()
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, field)
+		if r.s.CurrentToken().Is(CSVSeparator) {
+			r.s.GoNext()
+			continue
+		}
+		r.s.GoNextIfNextIs(CSVRecordEnd)
+		if r.s.CurrentToken().Is(CSVRecordEnd) {
+			r.s.GoNext()
+		}
+		return record, nil
+	}
+}
+
+// field reads tokens of the field until the separator, the end of the record or the end of the stream.
+func (r *CSVReader) field() (string, error) {
+	var value []byte
+	quoted := false
+	for ; r.s.IsValid(); r.s.GoNext() {
+		t := r.s.CurrentToken()
+		if t.Is(CSVSeparator, CSVRecordEnd) {
+			break
+		}
+		if t.IsString() {
+			if len(value) > 0 {
+				return "", r.error(t, ErrBareQuote)
+			}
+			if quoted || !isQuoteClosed(t.Value()) {
+				return "", r.error(t, ErrQuote)
+			}
+			value = append(value, t.ValueUnescaped()...)
+			quoted = true
+			continue
+		}
+		if quoted {
+			return "", r.error(t, ErrQuote)
+		}
+		value = append(value, t.Value()...)
+	}
+	return string(value), nil
+}
+
+func (r *CSVReader) error(t *tokenizer.Token, err error) *CSVError {
+	return &CSVError{Line: t.Line(), Column: t.Column(), Err: err}
+}
+
+// isQuoteClosed checks if the quoted field ends with the closing quote: the count of trailing quotes is odd.
+func isQuoteClosed(value []byte) bool {
+	n := 0
+	for i := len(value) - 1; i > 0 && value[i] == '"'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
@@ -0,0 +1,53 @@
+package presets
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSV(t *testing.T) {
+	data := "id,name, note\r\n1,\"Doe, \"\"John\"\"\",3.5 kg\n\n2,\"multi\nline\",\n3,,\"\""
+	records := [][]string{
+		{"id", "name", " note"},
+		{"1", `Doe, "John"`, "3.5 kg"},
+		{"2", "multi\nline", ""},
+		{"3", "", ""},
+	}
+	reader := NewCSVReader(CSV(',').ParseString(data))
+	for _, record := range records {
+		r, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, record, r)
+	}
+	_, err := reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	reader = NewCSVReader(CSV('\t').ParseStream(bytes.NewBufferString("a b\tc\n\"d\te\"\tf\n"), 3))
+	r, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a b", "c"}, r)
+	r, err = reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, []string{"d\te", "f"}, r)
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestCSVErrors(t *testing.T) {
+	for data, expected := range map[string]string{
+		"a,b\nc,d\"e\"": `line 2, column 4: bare " in non-quoted field`,
+		"a,\"b\"c\n":    `line 1, column 6: extraneous or missing " in quoted field`,
+		"a,\"b\n\nc":    `line 1, column 3: extraneous or missing " in quoted field`,
+		"a,\"b\"\"\n":   `line 1, column 3: extraneous or missing " in quoted field`,
+	} {
+		reader := NewCSVReader(CSV(',').ParseString(data))
+		var err error
+		for err == nil {
+			_, err = reader.Read()
+		}
+		require.EqualError(t, err, expected, data)
+	}
+}
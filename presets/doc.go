@@ -0,0 +1,12 @@
+// Package presets provides ready-made tokenizers for common languages and data formats.
+// Presets are built on the public API of the tokenizer package and may be extended with custom tokens.
+package presets
+
+import "github.com/psyton/tokenizer"
+
+// skip moves the pointer of the stream n tokens forward.
+func skip(s *tokenizer.Stream, n int) {
+	for ; n > 0; n-- {
+		s.GoNext()
+	}
+}
@@ -0,0 +1,103 @@
+package presets
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/psyton/tokenizer"
+)
+
+// JSON token keys.
+const (
+	// JSONObjectOpen is `{`.
+	JSONObjectOpen tokenizer.TokenKey = iota + 1
+	// JSONObjectClose is `}`.
+	JSONObjectClose
+	// JSONArrayOpen is `[`.
+	JSONArrayOpen
+	// JSONArrayClose is `]`.
+	JSONArrayClose
+	// JSONColon is `:`.
+	JSONColon
+	// JSONComma is `,`.
+	JSONComma
+	// JSONMinus is the sign of the negative number.
+	JSONMinus
+	// JSONTrue is `true`.
+	JSONTrue
+	// JSONFalse is `false`.
+	JSONFalse
+	// JSONNull is `null`.
+	JSONNull
+	// JSONString is the string in double quotes.
+	JSONString
+)
+
+// ErrJSONNumber is returned by JSONNumber if the number doesn't conform to RFC 8259.
+var ErrJSONNumber = errors.New("invalid JSON number")
+
+// JSON creates the tokenizer of JSON (RFC 8259).
+// Numbers are tokenizer.TokenInteger and tokenizer.TokenFloat tokens, the sign is the separate JSONMinus token,
+// use JSONNumber to read and validate the number. Use JSONString to decode strings with `\uXXXX` escapes.
+func JSON() *tokenizer.Tokenizer {
+	t := tokenizer.New()
+	t.DefineTokens(JSONObjectOpen, []string{"{"}).
+		DefineTokens(JSONObjectClose, []string{"}"}).
+		DefineTokens(JSONArrayOpen, []string{"["}).
+		DefineTokens(JSONArrayClose, []string{"]"}).
+		DefineTokens(JSONColon, []string{":"}).
+		DefineTokens(JSONComma, []string{","}).
+		DefineTokens(JSONMinus, []string{"-"}).
+		DefineTokens(JSONTrue, []string{"true"}).
+		DefineTokens(JSONFalse, []string{"false"}).
+		DefineTokens(JSONNull, []string{"null"})
+	t.DefineStringToken(JSONString, `"`, `"`).
+		SetEscapeSymbol(tokenizer.BackSlash).
+		SetSpecialSymbols(map[byte]byte{
+			'"':  '"',
+			'\\': '\\',
+			'/':  '/',
+			'b':  '\b',
+			'f':  '\f',
+			'n':  '\n',
+			'r':  '\r',
+			't':  '\t',
+		})
+	return t
+}
+
+// DecodeJSONString decodes the JSONString token including `\uXXXX` escapes and surrogate pairs.
+func DecodeJSONString(t *tokenizer.Token) (string, error) {
+	var s string
+	if err := json.Unmarshal(t.Value(), &s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// JSONNumber reads the number from the current position of the stream: optional JSONMinus and the number token
+// without whitespaces between them. The stream pointer is moved to the token after the number.
+// ErrJSONNumber is returned and the pointer stays unchanged if the number doesn't conform to RFC 8259,
+// like `01`, `1.` or `- 1`, or the stream is ended.
+func JSONNumber(s *tokenizer.Stream) (json.Number, error) {
+	if !s.IsValid() {
+		return "", ErrJSONNumber
+	}
+	tok := s.CurrentToken()
+	value := tok.ValueString()
+	n := 1
+	if tok.Is(JSONMinus) {
+		next := s.NextToken()
+		if !next.IsNumber() || len(next.Indent()) > 0 {
+			return "", ErrJSONNumber
+		}
+		value += next.ValueString()
+		tok = next
+		n++
+	}
+	if !tok.IsNumber() || !json.Valid([]byte(value)) {
+		return "", ErrJSONNumber
+	}
+	skip(s, n)
+	return json.Number(value), nil
+}
@@ -0,0 +1,45 @@
+package presets
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	stream := JSON().ParseString(`{"na\"me": "é\n", "list": [-1.5e3, 0, true, false, null]}`)
+	defer stream.Close()
+
+	require.Equal(t, []tokenizer.TokenKey{
+		JSONObjectOpen, JSONString, JSONColon, JSONString, JSONComma, JSONString, JSONColon, JSONArrayOpen,
+		JSONMinus, tokenizer.TokenFloat, JSONComma, tokenizer.TokenInteger, JSONComma, JSONTrue, JSONComma,
+		JSONFalse, JSONComma, JSONNull, JSONArrayClose, JSONObjectClose,
+	}, keysOf(stream))
+
+	require.Equal(t, `na"me`, stream.GoTo(1).CurrentToken().ValueUnescapedString())
+	s, err := DecodeJSONString(stream.GoTo(3).CurrentToken())
+	require.NoError(t, err)
+	require.Equal(t, "é\n", s)
+
+	n, err := JSONNumber(stream.GoTo(8))
+	require.NoError(t, err)
+	require.Equal(t, json.Number("-1.5e3"), n)
+	require.Equal(t, 10, stream.CurrentToken().ID())
+	n, err = JSONNumber(stream.GoTo(11))
+	require.NoError(t, err)
+	require.Equal(t, json.Number("0"), n)
+	require.Equal(t, 12, stream.CurrentToken().ID())
+
+	for _, str := range []string{"01", "1.", "- 1", "-x"} {
+		stream := JSON().ParseString(str)
+		_, err := JSONNumber(stream)
+		require.ErrorIs(t, err, ErrJSONNumber, str)
+		require.Equal(t, 0, stream.CurrentToken().ID())
+	}
+	_, err = JSONNumber(JSON().ParseString(""))
+	require.ErrorIs(t, err, ErrJSONNumber)
+	_, err = JSONNumber(stream.GoTo(12).GoNext())
+	require.ErrorIs(t, err, ErrJSONNumber)
+}
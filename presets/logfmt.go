@@ -0,0 +1,148 @@
+package presets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/psyton/tokenizer"
+)
+
+// Logfmt token keys.
+const (
+	// LogfmtAssign is `=` between the key and the value.
+	LogfmtAssign tokenizer.TokenKey = iota + 1
+	// LogfmtLineEnd is the end of the log line, `\n` or `\r\n`.
+	LogfmtLineEnd
+	// LogfmtQuoted is the quoted value, like "one \"two\"".
+	LogfmtQuoted
+)
+
+var (
+	// ErrUnexpectedAssign is returned by LogfmtReader if `=` appears without the key.
+	ErrUnexpectedAssign = errors.New("unexpected '='")
+	// ErrQuotedKey is returned by LogfmtReader if the key is quoted, like `"k"=v`.
+	ErrQuotedKey = errors.New("quoted key")
+)
+
+// LogfmtError describes the invalid log line and its position.
+type LogfmtError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *LogfmtError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *LogfmtError) Unwrap() error {
+	return e.Err
+}
+
+// Logfmt creates the tokenizer of structured log lines, like `ts=2021-10-06T12:30:44Z level=info msg="done" cached`.
+// The text of the bare key or value may be split into several tokens, LogfmtReader joins them.
+func Logfmt() *tokenizer.Tokenizer {
+	t := tokenizer.New().SetWhiteSpaces([]byte{' ', '\t'})
+	t.DefineTokens(LogfmtAssign, []string{"="}).
+		DefineTokens(LogfmtLineEnd, []string{"\r\n", "\n"})
+	t.DefineStringToken(LogfmtQuoted, `"`, `"`).
+		SetEscapeSymbol(tokenizer.BackSlash).
+		SetSpecialSymbols(tokenizer.DefaultStringEscapes)
+	return t
+}
+
+// LogfmtPair is the key/value pair of the log line.
+type LogfmtPair struct {
+	Key   string
+	Value string
+	// Bare is true if the key has no value, like `cached`.
+	Bare bool
+	// Line and Column are the position of the key.
+	Line   int
+	Column int
+}
+
+var logfmtTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Time parses the value as the timestamp, like `2021-10-06T12:30:44Z` or `2021-10-06 12:30:44`.
+func (p LogfmtPair) Time() (time.Time, bool) {
+	for _, layout := range logfmtTimeLayouts {
+		if t, err := time.Parse(layout, p.Value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// LogfmtReader reads log lines from the stream of the Logfmt tokenizer.
+type LogfmtReader struct {
+	s *tokenizer.Stream
+}
+
+// NewLogfmtReader creates the reader of log lines from the stream.
+// The stream may read an infinite buffer (see tokenizer.Tokenizer.ParseStream).
+func NewLogfmtReader(s *tokenizer.Stream) *LogfmtReader {
+	return &LogfmtReader{s: s}
+}
+
+// Read reads pairs of the next log line. Empty lines are skipped. Read returns io.EOF if there are no more lines,
+// or *LogfmtError if the line is invalid.
+func (r *LogfmtReader) Read() ([]LogfmtPair, error) {
+	for r.s.CurrentToken().Is(LogfmtLineEnd) {
+		r.s.GoNext()
+	}
+	if !r.s.IsValid() {
+		return nil, io.EOF
+	}
+	var pairs []LogfmtPair
+	for r.s.IsValid() && !r.s.CurrentToken().Is(LogfmtLineEnd) {
+		t := r.s.CurrentToken()
+		if t.Is(LogfmtAssign) {
+			return nil, r.error(t, ErrUnexpectedAssign)
+		} else if t.IsString() {
+			return nil, r.error(t, ErrQuotedKey)
+		}
+		pair := LogfmtPair{Key: r.word(), Line: t.Line(), Column: t.Column()}
+		if t = r.s.CurrentToken(); t.Is(LogfmtAssign) && len(t.Indent()) == 0 {
+			next := r.s.GoNext().CurrentToken()
+			if next.IsString() {
+				if !isQuoteClosedBy(next.Value()[1:], '"', tokenizer.BackSlash) {
+					return nil, r.error(next, ErrQuote)
+				}
+				pair.Value = next.ValueUnescapedString()
+				r.s.GoNext()
+			} else if len(next.Indent()) == 0 && !next.Is(LogfmtLineEnd) {
+				pair.Value = r.word()
+			}
+		} else {
+			pair.Bare = true
+		}
+		pairs = append(pairs, pair)
+	}
+	r.s.GoNext()
+	return pairs, nil
+}
+
+func (r *LogfmtReader) error(t *tokenizer.Token, err error) *LogfmtError {
+	return &LogfmtError{Line: t.Line(), Column: t.Column(), Err: err}
+}
+
+// word joins values of tokens without whitespaces between them until `=`, the quoted value or the end of the line.
+func (r *LogfmtReader) word() string {
+	var b strings.Builder
+	for t := r.s.CurrentToken(); t.IsValid(); t = r.s.GoNext().CurrentToken() {
+		if b.Len() > 0 && len(t.Indent()) > 0 || t.Is(LogfmtAssign, LogfmtLineEnd) || t.IsString() {
+			break
+		}
+		b.Write(t.Value())
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package presets
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmt(t *testing.T) {
+	data := "ts=2021-10-06T12:30:44.5Z level=info msg=\"user \\\"me\\\" logged in\" path=/api/v1 cached dur=1.5ms\n\n" +
+		"level=error err= at=\"2021-10-06 12:30:44\"\r\n"
+	reader := NewLogfmtReader(Logfmt().ParseStream(bytes.NewBufferString(data), 16))
+
+	pairs, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, []LogfmtPair{
+		{Key: "ts", Value: "2021-10-06T12:30:44.5Z", Line: 1, Column: 1},
+		{Key: "level", Value: "info", Line: 1, Column: 27},
+		{Key: "msg", Value: `user "me" logged in`, Line: 1, Column: 38},
+		{Key: "path", Value: "/api/v1", Line: 1, Column: 66},
+		{Key: "cached", Bare: true, Line: 1, Column: 79},
+		{Key: "dur", Value: "1.5ms", Line: 1, Column: 86},
+	}, pairs)
+	ts, ok := pairs[0].Time()
+	require.True(t, ok)
+	require.Equal(t, time.Date(2021, 10, 6, 12, 30, 44, 5e8, time.UTC), ts)
+	_, ok = pairs[1].Time()
+	require.False(t, ok)
+
+	pairs, err = reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, []LogfmtPair{
+		{Key: "level", Value: "error", Line: 3, Column: 1},
+		{Key: "err", Line: 3, Column: 13},
+		{Key: "at", Value: "2021-10-06 12:30:44", Line: 3, Column: 18},
+	}, pairs)
+	ts, ok = pairs[2].Time()
+	require.True(t, ok)
+	require.Equal(t, time.Date(2021, 10, 6, 12, 30, 44, 0, time.UTC), ts)
+
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	_, err = NewLogfmtReader(Logfmt().ParseString("a=1 =2")).Read()
+	require.EqualError(t, err, "line 1, column 5: unexpected '='")
+	_, err = NewLogfmtReader(Logfmt().ParseString(`a=1 "k"=v`)).Read()
+	require.EqualError(t, err, "line 1, column 5: quoted key")
+	_, err = NewLogfmtReader(Logfmt().ParseString(`a="1`)).Read()
+	require.ErrorIs(t, err, ErrQuote)
+}
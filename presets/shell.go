@@ -0,0 +1,166 @@
+package presets
+
+import (
+	"errors"
+
+	"github.com/psyton/tokenizer"
+)
+
+// Shell token keys.
+const (
+	// ShellOperator is the control or redirection operator, like `|`, `&&`, `;` or `>>`.
+	ShellOperator tokenizer.TokenKey = iota + 1
+	// ShellVar is `$` of the variable `$VAR`.
+	ShellVar
+	// ShellVarOpen is `${` of the variable `${VAR}`.
+	ShellVarOpen
+	// ShellVarClose is `}` of the variable `${VAR}`.
+	ShellVarClose
+	// ShellEscape is the backslash outside quotes which escapes the next symbol.
+	ShellEscape
+	// ShellSingleQuoted is the string in single quotes without escapes and variables.
+	ShellSingleQuoted
+	// ShellDoubleQuoted is the string in double quotes with variables and backslash escapes of `$`, "`", `"`, `\`.
+	ShellDoubleQuoted
+)
+
+// ErrShellQuote is returned by ShellWords if the quoted string is unterminated.
+var ErrShellQuote = errors.New("unterminated quoted string")
+
+// Shell creates the tokenizer of POSIX shell words.
+// A word may consist of several tokens without whitespaces between them, like `one"two"'three'`,
+// variables `$VAR` and `${VAR}` are injections in double-quoted strings. Use ShellWords to split words.
+func Shell() *tokenizer.Tokenizer {
+	t := tokenizer.New().
+		AllowKeywordUnderscore().
+		AllowNumbersInKeyword()
+	t.DefineTokens(ShellOperator, []string{"||", "&&", "|", "&", ";", ">>", ">", "<", "(", ")"}).
+		DefineTokens(ShellVar, []string{"$"}).
+		DefineTokens(ShellVarOpen, []string{"${"}).
+		DefineTokens(ShellVarClose, []string{"}"}).
+		DefineTokens(ShellEscape, []string{"\\"})
+	t.DefineStringToken(ShellSingleQuoted, "'", "'")
+	t.DefineStringToken(ShellDoubleQuoted, `"`, `"`).
+		SetEscapeSymbol(tokenizer.BackSlash).
+		AddInjection(ShellVarOpen, ShellVarClose).
+		AddInjection(ShellVar, tokenizer.TokenUndef)
+	return t
+}
+
+// ShellWords splits tokens of the Shell tokenizer into words, from the current token to the end of the stream.
+// Quotes are removed, variables are expanded via `lookup`. Operators are separate words.
+// *tokenizer.UnexpectedTokenError is returned if `${` isn't closed, ErrShellQuote if the quoted string is unterminated.
+func ShellWords(s *tokenizer.Stream, lookup func(name string) string) ([]string, error) {
+	var words []string
+	var word []byte
+	inWord := false
+	flush := func() {
+		if inWord {
+			words = append(words, string(word))
+			word = word[:0]
+			inWord = false
+		}
+	}
+	quoted := false // inside the double-quoted string with injections
+	joined := false // the token is joined with the previous one by the escaped whitespace
+	for ; s.IsValid(); s.GoNext() {
+		t := s.CurrentToken()
+		if !quoted && !joined && len(t.Indent()) > 0 {
+			flush()
+		}
+		joined = false
+		switch {
+		case t.Is(ShellOperator):
+			flush()
+			words = append(words, t.ValueString())
+			continue
+		case t.Is(tokenizer.TokenString):
+			value := t.Value()[1:]
+			if !isQuoteClosedBy(value, t.StringSettings().EndToken[0], t.StringSettings().EscapeSymbol) {
+				return nil, ErrShellQuote
+			}
+			value = value[:len(value)-1]
+			if t.StringKey() == ShellDoubleQuoted {
+				word = appendShellUnescaped(word, value)
+			} else {
+				word = append(word, value...)
+			}
+		case t.Is(tokenizer.TokenStringFragment):
+			value := t.Value()
+			if !quoted { // the first fragment
+				value = value[1:]
+				quoted = true
+			} else if isQuoteClosedBy(value, '"', '\\') { // the last fragment
+				value = value[:len(value)-1]
+				quoted = false
+			}
+			word = appendShellUnescaped(word, value)
+		case t.Is(ShellVar):
+			if next := s.NextToken(); next.IsValid() && len(next.Indent()) == 0 && !next.Is(ShellOperator) && !next.IsString() {
+				word = append(word, lookup(next.ValueString())...)
+				s.GoNext()
+			} else {
+				word = append(word, '$')
+			}
+		case t.Is(ShellVarOpen):
+			name := s.GoNext().CurrentToken().ValueString()
+			if !s.GoNext().CurrentToken().Is(ShellVarClose) {
+				_, err := s.Expect(ShellVarClose)
+				return nil, err
+			}
+			word = append(word, lookup(name)...)
+		case t.Is(ShellEscape):
+			next := s.NextToken()
+			if indent := next.Indent(); len(indent) > 0 {
+				if indent[0] != '\n' { // backslash-newline is the line continuation
+					word = append(word, indent[0])
+					inWord = true
+				}
+				joined = len(indent) == 1
+			} else if next.IsValid() {
+				word = append(word, next.Value()...)
+				inWord = true
+				s.GoNext()
+			}
+			continue
+		default:
+			word = append(word, t.Value()...)
+		}
+		inWord = true
+	}
+	if quoted {
+		return nil, ErrShellQuote
+	}
+	flush()
+	return words, nil
+}
+
+// isQuoteClosedBy checks if the value ends with the unescaped quote.
+func isQuoteClosedBy(value []byte, quote, escape byte) bool {
+	if len(value) == 0 || value[len(value)-1] != quote {
+		return false
+	}
+	n := 0
+	for i := len(value) - 2; i >= 0 && escape != 0 && value[i] == escape; i-- {
+		n++
+	}
+	return n%2 == 0
+}
+
+// appendShellUnescaped appends the value of the double-quoted string, where backslash escapes only
+// `$`, "`", `"`, `\` and the newline.
+func appendShellUnescaped(word, value []byte) []byte {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			switch value[i+1] {
+			case '$', '`', '"', '\\':
+				i++
+			case '\n':
+				i++
+				continue
+			}
+		}
+		word = append(word, value[i])
+	}
+	return word
+}
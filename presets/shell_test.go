@@ -0,0 +1,33 @@
+package presets
+
+import (
+	"testing"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellWords(t *testing.T) {
+	env := map[string]string{"HOME": "/home/me", "USER": "me"}
+	lookup := func(name string) string {
+		return env[name]
+	}
+	for str, expected := range map[string][]string{
+		`ls -la $HOME/bin`:                      {"ls", "-la", "/home/me/bin"},
+		`echo "hi $USER, \"${HOME}\"" 'it $s'`:  {"echo", `hi me, "/home/me"`, "it $s"},
+		`cp a\ b "c d"e'f' g`:                   {"cp", "a b", "c def", "g"},
+		`echo "$USER$HOME" "a\b" | wc -l && x;`: {"echo", "me/home/me", `a\b`, "|", "wc", "-l", "&&", "x", ";"},
+		"one \\\ntwo\\\nthree \"\"":             {"one", "twothree", ""},
+	} {
+		words, err := ShellWords(Shell().ParseString(str), lookup)
+		require.NoError(t, err, str)
+		require.Equal(t, expected, words, str)
+	}
+
+	for _, str := range []string{`echo "abc`, `echo 'abc`, `echo "a $USER`, `echo "a\"`} {
+		_, err := ShellWords(Shell().ParseString(str), lookup)
+		require.ErrorIs(t, err, ErrShellQuote, str)
+	}
+	_, err := ShellWords(Shell().ParseString(`echo ${HOME`), lookup)
+	require.IsType(t, &tokenizer.UnexpectedTokenError{}, err)
+}
@@ -0,0 +1,113 @@
+package presets
+
+import (
+	"strings"
+
+	"github.com/psyton/tokenizer"
+)
+
+// SQL token keys.
+const (
+	// SQLOperator is the comparison, arithmetic or other operator, like `>=`, `+` or `||`.
+	SQLOperator tokenizer.TokenKey = iota + 1
+	// SQLComma is `,`.
+	SQLComma
+	// SQLSemicolon is `;`.
+	SQLSemicolon
+	// SQLDot is `.`, like in `users.id`.
+	SQLDot
+	// SQLParenOpen is `(`.
+	SQLParenOpen
+	// SQLParenClose is `)`.
+	SQLParenClose
+	// SQLParameter is the placeholder `?` or `$` of the positional parameter `$1` (Postgres).
+	SQLParameter
+	// SQLString is the string literal, like 'it''s'.
+	SQLString
+	// SQLIdentifier is the quoted identifier, like "user" or `user` (MySQL).
+	SQLIdentifier
+	// SQLComment is the comment `-- ...` or `/* ... */`. Comments are trivia (see tokenizer.Token.Trivia).
+	SQLComment
+)
+
+// SQLDialect selects quirks of the SQL dialect.
+type SQLDialect uint8
+
+const (
+	// SQLStandard is ANSI SQL: 'strings' and "identifiers".
+	SQLStandard SQLDialect = iota
+	// MySQL allows "strings" and backslash escapes in strings, `identifiers` and `#` comments.
+	MySQL
+	// Postgres adds $1 parameters, $$dollar-quoted$$ strings and operators like `::`, `->>` and `@>`.
+	Postgres
+)
+
+var sqlOperators = []string{"=", "<>", "!=", "<", "<=", ">", ">=", "+", "-", "*", "/", "%", "||"}
+
+var postgresOperators = []string{"::", "->", "->>", "#>", "#>>", "@>", "<@", "~", "~*", "!~", "!~*", "?|", "?&"}
+
+// SQL creates the tokenizer of SQL dialect.
+// Keywords are tokenizer.TokenKeyword tokens, use IsSQLKeyword to check reserved words.
+// Numbers are tokenizer.TokenInteger and tokenizer.TokenFloat tokens.
+func SQL(dialect SQLDialect) *tokenizer.Tokenizer {
+	t := tokenizer.New().
+		AllowKeywordUnderscore().
+		AllowNumbersInKeyword()
+	t.DefineTokens(SQLComma, []string{","}).
+		DefineTokens(SQLSemicolon, []string{";"}).
+		DefineTokens(SQLDot, []string{"."}).
+		DefineTokens(SQLParenOpen, []string{"("}).
+		DefineTokens(SQLParenClose, []string{")"})
+
+	operators := sqlOperators
+	parameters := []string{"?"}
+	if dialect == Postgres {
+		operators = append(append([]string{}, sqlOperators...), postgresOperators...)
+		parameters = []string{"$"}
+	}
+	t.DefineTokens(SQLOperator, operators)
+	t.DefineTokens(SQLParameter, parameters)
+
+	switch dialect {
+	case MySQL:
+		t.DefineStringToken(SQLString, "'", "'").EscapeByDoubling().
+			SetEscapeSymbol(tokenizer.BackSlash).SetSpecialSymbols(tokenizer.DefaultStringEscapes)
+		t.DefineStringToken(SQLString, `"`, `"`).EscapeByDoubling().
+			SetEscapeSymbol(tokenizer.BackSlash).SetSpecialSymbols(tokenizer.DefaultStringEscapes)
+		t.DefineStringToken(SQLIdentifier, "`", "`").EscapeByDoubling()
+		t.DefineStringToken(SQLComment, "#", "\n")
+	case Postgres:
+		t.DefineStringToken(SQLString, "'", "'").EscapeByDoubling()
+		t.DefineStringToken(SQLString, "$$", "$$")
+		t.DefineStringToken(SQLIdentifier, `"`, `"`).EscapeByDoubling()
+	default:
+		t.DefineStringToken(SQLString, "'", "'").EscapeByDoubling()
+		t.DefineStringToken(SQLIdentifier, `"`, `"`).EscapeByDoubling()
+	}
+	t.DefineStringToken(SQLComment, "--", "\n")
+	t.DefineStringToken(SQLComment, "/*", "*/")
+	t.DefineTrivia(SQLComment)
+
+	return t
+}
+
+var sqlKeywords = map[string]struct{}{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		ALL ALTER AND ANY AS ASC BETWEEN BY CASE CAST CHECK COLUMN CONSTRAINT CREATE CROSS DEFAULT DELETE DESC
+		DISTINCT DROP ELSE END EXCEPT EXISTS FALSE FETCH FOR FOREIGN FROM FULL GROUP HAVING IN INDEX INNER INSERT
+		INTERSECT INTO IS JOIN KEY LEFT LIKE LIMIT NOT NULL OFFSET ON OR ORDER OUTER PRIMARY REFERENCES RETURNING
+		RIGHT SELECT SET TABLE THEN TRUE UNION UNIQUE UPDATE USING VALUES VIEW WHEN WHERE WITH`) {
+		sqlKeywords[k] = struct{}{}
+	}
+}
+
+// IsSQLKeyword checks if the token is the reserved word of SQL, like `SELECT` or `where`. Keywords are case-insensitive.
+func IsSQLKeyword(t *tokenizer.Token) bool {
+	if !t.IsKeyword() {
+		return false
+	}
+	_, ok := sqlKeywords[strings.ToUpper(t.ValueString())]
+	return ok
+}
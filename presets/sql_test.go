@@ -0,0 +1,72 @@
+package presets
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+// keysOf returns keys of tokens from the current token, the pointer stays unchanged.
+func keysOf(s *tokenizer.Stream) []tokenizer.TokenKey {
+	var keys []tokenizer.TokenKey
+	cp := s.Mark()
+	defer s.Reset(cp)
+	for _, t := range s.Tokens() {
+		if t.IsString() {
+			keys = append(keys, t.StringKey())
+		} else {
+			keys = append(keys, t.Key())
+		}
+	}
+	return keys
+}
+
+func TestSQL(t *testing.T) {
+	stream := SQL(SQLStandard).ParseString(`-- users
+SELECT u.id, "order" FROM users u WHERE name <> 'it''s' /* x */ AND age >= 1.5 AND id = ?;`)
+	defer stream.Close()
+
+	require.True(t, IsSQLKeyword(stream.CurrentToken()))
+	require.Equal(t, "-- users\n", string(stream.CurrentToken().Trivia()[0].Value))
+	require.Equal(t, []tokenizer.TokenKey{
+		tokenizer.TokenKeyword, tokenizer.TokenKeyword, SQLDot, tokenizer.TokenKeyword, SQLComma, SQLIdentifier,
+		tokenizer.TokenKeyword, tokenizer.TokenKeyword, tokenizer.TokenKeyword, tokenizer.TokenKeyword,
+		tokenizer.TokenKeyword, SQLOperator, SQLString, tokenizer.TokenKeyword, tokenizer.TokenKeyword, SQLOperator,
+		tokenizer.TokenFloat, tokenizer.TokenKeyword, tokenizer.TokenKeyword, SQLOperator, SQLParameter, SQLSemicolon,
+	}, keysOf(stream))
+	require.Equal(t, "it's", stream.GoTo(12).CurrentToken().ValueUnescapedString())
+	require.False(t, IsSQLKeyword(stream.GoTo(3).CurrentToken()))
+	require.True(t, IsSQLKeyword(stream.GoTo(13).CurrentToken()))
+}
+
+func TestSQLDialects(t *testing.T) {
+	stream := SQL(MySQL).ParseString("SELECT `id` FROM t WHERE s = \"a\\n\" # note\n")
+	require.Equal(t, []tokenizer.TokenKey{
+		tokenizer.TokenKeyword, SQLIdentifier, tokenizer.TokenKeyword, tokenizer.TokenKeyword, tokenizer.TokenKeyword,
+		tokenizer.TokenKeyword, SQLOperator, SQLString,
+	}, keysOf(stream))
+	require.Equal(t, "a\n", stream.GoTo(7).CurrentToken().ValueUnescapedString())
+
+	stream = SQL(Postgres).ParseString(`SELECT data->>'name', $$raw 'text'$$::text FROM t WHERE id = $1`)
+	require.Equal(t, []tokenizer.TokenKey{
+		tokenizer.TokenKeyword, tokenizer.TokenKeyword, SQLOperator, SQLString, SQLComma, SQLString, SQLOperator,
+		tokenizer.TokenKeyword, tokenizer.TokenKeyword, tokenizer.TokenKeyword, tokenizer.TokenKeyword,
+		tokenizer.TokenKeyword, SQLOperator, SQLParameter, tokenizer.TokenInteger,
+	}, keysOf(stream))
+	require.Equal(t, "raw 'text'", stream.GoTo(5).CurrentToken().ValueUnescapedString())
+}
+
+func TestSQLMinify(t *testing.T) {
+	sql := SQL(SQLStandard)
+	source := sql.ParseString("SELECT 'a' 'b', \"c\" \"d\" FROM t -- note\nWHERE x = 'it''s'")
+	defer source.Close()
+	out := bytes.NewBuffer(nil)
+	_, err := source.Minify(out)
+	require.NoError(t, err)
+	require.Equal(t, `SELECT'a' 'b',"c" "d"FROM t-- note`+"\nWHERE x='it''s'", out.String())
+	minified := sql.ParseString(out.String())
+	defer minified.Close()
+	require.Nil(t, tokenizer.Diff(source, minified, false))
+}
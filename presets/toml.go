@@ -0,0 +1,145 @@
+package presets
+
+import (
+	"strings"
+	"time"
+
+	"github.com/psyton/tokenizer"
+)
+
+// TOML and INI token keys.
+const (
+	// TOMLBracketOpen is `[` of the section header `[section]` or the array.
+	TOMLBracketOpen tokenizer.TokenKey = iota + 1
+	// TOMLBracketClose is `]`.
+	TOMLBracketClose
+	// TOMLBraceOpen is `{` of the inline table.
+	TOMLBraceOpen
+	// TOMLBraceClose is `}`.
+	TOMLBraceClose
+	// TOMLAssign is `=` between the key and the value, INI allows `:` as well.
+	TOMLAssign
+	// TOMLDot is `.` of dotted keys, like `server.port`.
+	TOMLDot
+	// TOMLComma is `,`.
+	TOMLComma
+	// TOMLMinus is `-` of negative numbers and dates.
+	TOMLMinus
+	// TOMLPlus is `+` of positive numbers and time offsets.
+	TOMLPlus
+	// TOMLColon is `:` of times.
+	TOMLColon
+	// TOMLString is the basic string with escapes, like "one\ttwo" or """multi-line""".
+	TOMLString
+	// TOMLLiteral is the literal string without escapes, like 'C:\path' or '''multi-line'''.
+	TOMLLiteral
+	// TOMLComment is the comment `# ...` or `; ...` (INI). Comments are trivia (see tokenizer.Token.Trivia).
+	TOMLComment
+)
+
+var tomlEscapes = map[byte]byte{
+	'b':  '\b',
+	't':  '\t',
+	'n':  '\n',
+	'f':  '\f',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// TOML creates the tokenizer of TOML-like configuration.
+// Keys and booleans are tokenizer.TokenKeyword tokens, key/value pairs are separated by lines (see tokenizer.Token.Line).
+// Dates and times are split into several tokens, use TOMLDateTime to read them.
+// Escapes `\uXXXX` in basic strings aren't decoded by tokenizer.Token.ValueUnescaped.
+func TOML() *tokenizer.Tokenizer {
+	return newTOML([]string{"="}, []string{":"})
+}
+
+// INI creates the tokenizer of INI configuration: TOML tokens with `:` as TOMLAssign and `;` comments.
+func INI() *tokenizer.Tokenizer {
+	t := newTOML([]string{"=", ":"}, nil)
+	t.DefineStringToken(TOMLComment, ";", "\n")
+	return t
+}
+
+func newTOML(assign, colon []string) *tokenizer.Tokenizer {
+	t := tokenizer.New().
+		AllowKeywordUnderscore().
+		AllowNumbersInKeyword()
+	t.DefineTokens(TOMLBracketOpen, []string{"["}).
+		DefineTokens(TOMLBracketClose, []string{"]"}).
+		DefineTokens(TOMLBraceOpen, []string{"{"}).
+		DefineTokens(TOMLBraceClose, []string{"}"}).
+		DefineTokens(TOMLAssign, assign).
+		DefineTokens(TOMLDot, []string{"."}).
+		DefineTokens(TOMLComma, []string{","}).
+		DefineTokens(TOMLMinus, []string{"-"}).
+		DefineTokens(TOMLPlus, []string{"+"}).
+		DefineTokens(TOMLColon, colon)
+	t.DefineStringToken(TOMLString, `"""`, `"""`).SetEscapeSymbol(tokenizer.BackSlash).SetSpecialSymbols(tomlEscapes)
+	t.DefineStringToken(TOMLString, `"`, `"`).SetEscapeSymbol(tokenizer.BackSlash).SetSpecialSymbols(tomlEscapes)
+	t.DefineStringToken(TOMLLiteral, "'''", "'''")
+	t.DefineStringToken(TOMLLiteral, "'", "'")
+	t.DefineStringToken(TOMLComment, "#", "\n")
+	t.DefineTrivia(TOMLComment)
+	return t
+}
+
+var tomlDateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+// TOMLDateTime reads the offset date-time, the local date-time, the local date or the local time from the current
+// position of the stream, like `1979-05-27T07:32:00Z`, `1979-05-27 07:32:00` or `07:32:00`.
+// The stream pointer is moved to the token after the date-time. If there is no date-time the pointer stays unchanged.
+func TOMLDateTime(s *tokenizer.Stream) (time.Time, bool) {
+	value, n := tomlAdjacent(s, 0)
+	if !strings.Contains(value, "-") && !strings.Contains(value, ":") {
+		return time.Time{}, false
+	}
+	if len(value) == len("2006-01-02") {
+		// the date and the time may be separated by the space
+		if next := s.PeekN(n); next.IsInteger() && string(next.Indent()) == " " {
+			if t, m := tomlAdjacent(s, n); strings.Contains(t, ":") {
+				if dt, ok := parseTOMLDateTime(value + "T" + t); ok {
+					skip(s, n+m)
+					return dt, true
+				}
+			}
+		}
+	}
+	dt, ok := parseTOMLDateTime(value)
+	if ok {
+		skip(s, n)
+	}
+	return dt, ok
+}
+
+// tomlAdjacent joins values of tokens without whitespaces between them, starting from the token `from` positions
+// after the current one. Returns the value and the count of tokens.
+func tomlAdjacent(s *tokenizer.Stream, from int) (string, int) {
+	var b strings.Builder
+	n := 0
+	for t := s.PeekN(from); t.IsValid(); t = s.PeekN(from + n) {
+		if n > 0 && len(t.Indent()) > 0 || !t.Is(tokenizer.TokenInteger, tokenizer.TokenFloat, tokenizer.TokenKeyword,
+			TOMLMinus, TOMLPlus, TOMLColon) {
+			break
+		}
+		b.Write(t.Value())
+		n++
+	}
+	return b.String(), n
+}
+
+func parseTOMLDateTime(value string) (time.Time, bool) {
+	value = strings.ToUpper(value)
+	for _, layout := range tomlDateTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,64 @@
+package presets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psyton/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOML(t *testing.T) {
+	stream := TOML().ParseString(`# config
+[server.http]
+host = "local\thost" # inline
+path = 'C:\dir'
+ports = [8001, -8002]
+created = 1979-05-27T07:32:00-08:00
+day = 1979-05-27
+at = 1979-05-27 07:32:00.5
+`)
+	defer stream.Close()
+
+	require.Equal(t, []tokenizer.TokenKey{
+		TOMLBracketOpen, tokenizer.TokenKeyword, TOMLDot, tokenizer.TokenKeyword, TOMLBracketClose,
+		tokenizer.TokenKeyword, TOMLAssign, TOMLString,
+		tokenizer.TokenKeyword, TOMLAssign, TOMLLiteral,
+		tokenizer.TokenKeyword, TOMLAssign, TOMLBracketOpen, tokenizer.TokenInteger, TOMLComma, TOMLMinus,
+		tokenizer.TokenInteger, TOMLBracketClose,
+	}, keysOf(stream)[:19])
+	require.Equal(t, 2, stream.CurrentToken().Line())
+	require.Equal(t, "local\thost", stream.GoTo(7).CurrentToken().ValueUnescapedString())
+	require.Equal(t, `C:\dir`, stream.GoTo(10).CurrentToken().ValueUnescapedString())
+	require.Equal(t, "# inline\n", string(stream.GoTo(8).CurrentToken().Trivia()[1].Value))
+
+	stream.GoTo(21)
+	dt, ok := TOMLDateTime(stream)
+	require.True(t, ok)
+	require.True(t, time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC).Equal(dt))
+	require.Equal(t, "day", stream.CurrentToken().ValueString())
+
+	dt, ok = TOMLDateTime(stream.GoNext().GoNext())
+	require.True(t, ok)
+	require.Equal(t, time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC), dt)
+	require.Equal(t, "at", stream.CurrentToken().ValueString())
+
+	dt, ok = TOMLDateTime(stream.GoNext().GoNext())
+	require.True(t, ok)
+	require.Equal(t, time.Date(1979, 5, 27, 7, 32, 0, 5e8, time.UTC), dt)
+	require.False(t, stream.IsValid())
+
+	stream = TOML().ParseString("-8002, 1")
+	_, ok = TOMLDateTime(stream)
+	require.False(t, ok)
+	require.Equal(t, 0, stream.CurrentToken().ID())
+}
+
+func TestINI(t *testing.T) {
+	stream := INI().ParseString("; comment\n[main]\nname: value\n")
+	require.Equal(t, []tokenizer.TokenKey{
+		TOMLBracketOpen, tokenizer.TokenKeyword, TOMLBracketClose, tokenizer.TokenKeyword, TOMLAssign,
+		tokenizer.TokenKeyword,
+	}, keysOf(stream))
+	require.Equal(t, "; comment\n", string(stream.CurrentToken().Trivia()[0].Value))
+}
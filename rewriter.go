@@ -0,0 +1,128 @@
+package tokenizer
+
+import (
+	"io"
+	"strings"
+)
+
+// Rewriter records insert, replace and delete operations over tokens of the stream and emits the modified source.
+// Operations are keyed by token id, the stream is not modified.
+// Untouched tokens are emitted with their indents exactly as in the source.
+// The rewriter emits tokens from the head token, so in the streaming mode the history should not be limited (see SetHistorySize).
+type Rewriter struct {
+	s     *Stream
+	edits map[int]*tokenEdit
+}
+
+// tokenEdit describes operations over one token.
+type tokenEdit struct {
+	// text inserted before the value of the token (after the indent)
+	before string
+	// text inserted after the value of the token
+	after string
+	// replacement of the value of the token
+	replace *string
+	// the token is deleted with its indent
+	delete bool
+}
+
+// NewRewriter creates new rewriter for the stream.
+func NewRewriter(s *Stream) *Rewriter {
+	return &Rewriter{
+		s:     s,
+		edits: map[int]*tokenEdit{},
+	}
+}
+
+func (r *Rewriter) edit(id int) *tokenEdit {
+	e := r.edits[id]
+	if e == nil {
+		e = &tokenEdit{}
+		r.edits[id] = e
+	}
+	return e
+}
+
+// InsertBefore inserts the text before the token with specific id. The text is placed after the indent of the token.
+func (r *Rewriter) InsertBefore(id int, text string) *Rewriter {
+	e := r.edit(id)
+	e.before += text
+	return r
+}
+
+// InsertAfter inserts the text after the token with specific id.
+func (r *Rewriter) InsertAfter(id int, text string) *Rewriter {
+	e := r.edit(id)
+	e.after = text + e.after
+	return r
+}
+
+// Replace replaces the tokens from `from` to `to` id (inclusive) by the text.
+// The indent of the first token is kept, the tokens after the first are deleted with their indents.
+func (r *Rewriter) Replace(from, to int, text string) *Rewriter {
+	r.edit(from).replace = &text
+	for id := from + 1; id <= to; id++ {
+		r.edit(id).delete = true
+	}
+	return r
+}
+
+// Delete deletes the tokens from `from` to `to` id (inclusive) with their indents.
+func (r *Rewriter) Delete(from, to int) *Rewriter {
+	for id := from; id <= to; id++ {
+		r.edit(id).delete = true
+	}
+	return r
+}
+
+// Rename replaces values of all keywords (TokenKeyword tokens) equal to `from` by `to`, like renaming of the identifier.
+// Keywords are matched by the whole value, so renaming of `user` keeps `user_agent` and strings containing `user`.
+// Tokens are searched from the head token, formatting of the source is kept.
+func (r *Rewriter) Rename(from, to string) *Rewriter {
+	for ptr := r.s.head; ptr != nil && ptr != undefToken; ptr = r.s.nextOf(ptr) {
+		if ptr.key == TokenKeyword && b2s(ptr.value) == from {
+			r.Replace(ptr.id, ptr.id, to)
+		}
+	}
+	return r
+}
+
+// WriteTo writes the modified source to the writer. The method implements io.WriterTo.
+func (r *Rewriter) WriteTo(w io.Writer) (int64, error) {
+	out := writer{w: w}
+	for ptr := r.s.head; ptr != nil && ptr != undefToken; ptr = r.s.nextOf(ptr) {
+		for _, t := range ptr.trivia {
+			out.write(t.indent)
+			out.write(t.value)
+		}
+		e := r.edits[ptr.id]
+		if e == nil {
+			out.write(ptr.indent)
+			out.write(ptr.value)
+			continue
+		}
+		if !e.delete {
+			out.write(ptr.indent)
+		}
+		out.writeString(e.before)
+		if e.replace != nil {
+			out.writeString(*e.replace)
+		} else if !e.delete {
+			out.write(ptr.value)
+		}
+		out.writeString(e.after)
+	}
+	for _, t := range r.s.trailingTrivia() {
+		out.write(t.indent)
+		out.write(t.value)
+	}
+	out.write(r.s.tail())
+	return out.n, out.err
+}
+
+// String returns the modified source.
+func (r *Rewriter) String() string {
+	var b strings.Builder
+	_, _ = r.WriteTo(&b)
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package tokenizer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriter(t *testing.T) {
+	compareKey := TokenKey(10)
+	condKey := TokenKey(11)
+	tokenizer := New()
+	tokenizer.AllowKeywordUnderscore()
+	tokenizer.DefineTokens(compareKey, []string{">=", "<=", "==", ">", "<", "="})
+	tokenizer.DefineTokens(condKey, []string{"and", "or"})
+	tokenizer.DefineStringToken(TokenKey(14), "'", "'")
+	str := " bytes_in <= 100 or\n\tuser_agent='curl' "
+
+	require.Equal(t, str, NewRewriter(tokenizer.ParseString(str)).String())
+
+	rewriter := NewRewriter(tokenizer.ParseString(str)).
+		InsertBefore(0, "(").
+		InsertAfter(2, ")").
+		Replace(1, 1, ">=").
+		Replace(4, 6, "agent IS NULL").
+		InsertAfter(6, ";")
+	require.Equal(t, " (bytes_in >= 100) or\n\tagent IS NULL; ", rewriter.String())
+
+	rewriter = NewRewriter(tokenizer.ParseString(str)).Delete(0, 3).InsertBefore(4, "not ")
+	require.Equal(t, "\n\tnot user_agent='curl' ", rewriter.String())
+
+	buffer := bytes.NewBufferString(strings.Repeat("one two ", 10))
+	rewriter = NewRewriter(tokenizer.ParseStream(buffer, 8)).Delete(1, 1).Replace(19, 19, "three")
+	out := bytes.NewBuffer(nil)
+	n, err := rewriter.WriteTo(out)
+	require.NoError(t, err)
+	require.Equal(t, "one"+strings.Repeat(" one two", 8)+" one three ", out.String())
+	require.Equal(t, int64(out.Len()), n)
+}
+
+func TestRewriterRename(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AllowKeywordUnderscore()
+	tokenizer.DefineTokens(TokenKey(10), []string{"=", "+", "."})
+	tokenizer.DefineStringToken(TokenKey(11), "'", "'")
+	tokenizer.DefineStringToken(TokenKey(12), "#", "\n")
+	tokenizer.DefineTrivia(TokenKey(12))
+	str := "user = user_agent + 'user' # user\n  user.name=user\n"
+
+	rewriter := NewRewriter(tokenizer.ParseString(str)).Rename("user", "account")
+	require.Equal(t, "account = user_agent + 'user' # user\n  account.name=account\n", rewriter.String())
+	require.Equal(t, str, NewRewriter(tokenizer.ParseString(str)).Rename("name_", "x").String())
+}
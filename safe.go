@@ -0,0 +1,13 @@
+//go:build purego || safe
+
+package tokenizer
+
+// b2s returns the copy of bytes as the string, the conversion for environments forbidding the unsafe package.
+func b2s(b []byte) string {
+	return string(b)
+}
+
+// s2b returns the copy of the string as bytes.
+func s2b(s string) (b []byte) {
+	return []byte(s)
+}
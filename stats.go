@@ -0,0 +1,86 @@
+package tokenizer
+
+import "time"
+
+// Stats describes the work of the parser for the stream, see Stream.Stats.
+type Stats struct {
+	// Tokens is the count of tokens produced, without trivia tokens.
+	Tokens int
+	// Trivia is the count of trivia tokens produced, like comments.
+	Trivia int
+	// Bytes is the count of consumed bytes of the source.
+	Bytes int
+	// Keys are counts of tokens and trivia tokens by keys, framed strings are counted by TokenString
+	// and TokenStringFragment keys. Collected only if Tokenizer.CollectStats is enabled.
+	Keys map[TokenKey]int
+	// Lines is the count of lines of the consumed source, the last line is counted if it isn't empty.
+	// Collected only if Tokenizer.CollectStats is enabled.
+	Lines int
+	// Longest is the length in bytes of the longest token or trivia token, like huge string literals.
+	// Collected only if Tokenizer.CollectStats is enabled.
+	Longest int
+	// Duration is the time spent for parsing. Collected only if Tokenizer.CollectStats is enabled.
+	Duration time.Duration
+}
+
+// CollectStats enables collecting of per-key counts, lines, the longest token and time spent for parsing (see Stream.Stats),
+// so services tokenizing user input may monitor throughput and detect pathological inputs.
+// Counts of tokens and bytes are available without the option.
+func (t *Tokenizer) CollectStats() *Tokenizer {
+	t.flags |= fCollectStats
+	return t
+}
+
+// Stats returns counters of the parser of the stream.
+// In the streaming mode counters describe data parsed so far.
+func (s *Stream) Stats() Stats {
+	if s.p == nil {
+		return s.stats.copy()
+	}
+	s.lock()
+	defer s.unlock()
+	return s.p.statistics()
+}
+
+// copy returns stats with the copy of the counts by keys.
+func (st Stats) copy() Stats {
+	if st.Keys != nil {
+		keys := make(map[TokenKey]int, len(st.Keys))
+		for k, v := range st.Keys {
+			keys[k] = v
+		}
+		st.Keys = keys
+	}
+	return st
+}
+
+// statistics returns the current stats of the parser.
+func (p *parsing) statistics() Stats {
+	st := p.stats
+	st.Tokens = p.n
+	st.Bytes = p.parsed + p.pos
+	if st.Keys != nil {
+		st.Duration = p.spent
+		st.Lines = p.line
+		if p.offset+p.pos == p.lineStart {
+			st.Lines--
+		}
+	}
+	return st.copy()
+}
+
+// count counts the emitted token if stats are collected.
+func (p *parsing) count(t *Token) {
+	if p.stats.Keys != nil {
+		p.stats.Keys[t.key]++
+		if len(t.value) > p.stats.Longest {
+			p.stats.Longest = len(t.value)
+		}
+	}
+}
+
+// measure adds the time spent since the start of parsing of data-chunks.
+func (p *parsing) measure() {
+	p.spent += time.Since(p.started)
+	p.started = time.Time{}
+}
@@ -0,0 +1,51 @@
+package tokenizer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamStats(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"=="})
+	tokenizer.DefineStringToken(TokenKey(11), "#", "\n")
+	tokenizer.DefineTrivia(TokenKey(11))
+	source := "# note\na == 1 # tail\n"
+
+	stream := tokenizer.ParseString(source)
+	require.Equal(t, Stats{Tokens: 3, Trivia: 2, Bytes: len(source)}, stream.Stats())
+	stream.Close()
+
+	tokenizer.CollectStats()
+	stream = tokenizer.ParseString(source)
+	stats := stream.Stats()
+	require.Equal(t, map[TokenKey]int{TokenString: 2, TokenKeyword: 1, TokenKey(10): 1, TokenInteger: 1}, stats.Keys)
+	require.Positive(t, stats.Duration)
+	require.Equal(t, 2, stats.Lines)
+	require.Equal(t, len("# note\n"), stats.Longest)
+	require.Equal(t, 2, tokenizer.ParseString("a\nb").Stats().Lines)
+	stats.Keys[TokenKeyword] = 10
+	require.Equal(t, 1, stream.Stats().Keys[TokenKeyword])
+	require.Equal(t, 3, stream.Fork().Stats().Tokens)
+	stream.Close()
+
+	stream = tokenizer.ParseStream(strings.NewReader(source), 4)
+	defer stream.Close()
+	require.Less(t, stream.Stats().Bytes, len(source))
+	stream.GoTo(2).GoNext()
+	stats = stream.Stats()
+	require.Equal(t, 3, stats.Tokens)
+	require.Equal(t, len(source), stats.Bytes)
+	require.Equal(t, 1, stats.Keys[TokenInteger])
+	require.Equal(t, 2, stats.Trivia)
+	require.Equal(t, 2, stats.Lines)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, tokenizer.ParseString(source).WriteBinary(buf))
+	restored, err := tokenizer.ReadBinary(buf)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Tokens: 3, Trivia: 2, Bytes: len(source)}, restored.Stats())
+}
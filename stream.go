@@ -1,10 +1,35 @@
 package tokenizer
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// TokenStream is a cursor over tokens.
+// The interface is implemented by *Stream, libraries may accept the interface to work with any source of tokens.
+type TokenStream interface {
+	// CurrentToken returns the current token or TokenUndef token, see Stream.CurrentToken.
+	CurrentToken() *Token
+	// PrevToken returns the previous token or TokenUndef token, see Stream.PrevToken.
+	PrevToken() *Token
+	// NextToken returns the next token or TokenUndef token, see Stream.NextToken.
+	NextToken() *Token
+	// IsValid checks if the pointer has not reached the end of the stream, see Stream.IsValid.
+	IsValid() bool
+	// Next moves the pointer to the next token, see Stream.GoNext.
+	Next()
+	// Prev moves the pointer to the previous token, see Stream.GoPrev.
+	Prev()
+	// GoNextIfNextIs moves the pointer to the next token if the next token has specific token keys, see Stream.GoNextIfNextIs.
+	GoNextIfNextIs(key TokenKey, otherKeys ...TokenKey) bool
+}
+
+var _ TokenStream = (*Stream)(nil)
+
 // Stream iterator via parsed tokens.
 // If data reads from an infinite buffer then the iterator will be read data from reader chunk-by-chunk.
 type Stream struct {
@@ -22,95 +47,372 @@ type Stream struct {
 
 	// last whitespaces before end of source
 	wsTail []byte
+	// trivia tokens after the last token
+	trivia []*Token
 	// count of parsed bytes
 	parsed int
+	// stats of the finished parser
+	stats Stats
+	// error of the finished parser
+	err error
 
+	// file name of the source
+	file        string
 	p           *parsing
 	historySize int
+	// the tokens are shared between the stream and its forks
+	shared bool
+	// the stream is a fork and doesn't own the tokens
+	forked bool
+	// the stream is returned to the pool of the tokenizer on Close, see Tokenizer.ParseBytesPooled
+	pooled bool
+	// tokens are allocated from the arena and aren't released, see Tokenizer.ParseBytesArena
+	arena bool
+	// unmaps the source file on Close, see Tokenizer.ParseFile
+	unmap func()
+}
+
+// Checkpoint is a saved position of the stream pointer.
+// See Stream.Mark and Stream.Reset.
+type Checkpoint struct {
+	id int
+	// pointer was moved out of the end (1) or out of the begin (-1) of the list
+	bound int8
 }
 
 // NewStream creates new parsed stream of tokens.
 func NewStream(p *parsing) *Stream {
-	return &Stream{
+	s := &Stream{}
+	s.init(p)
+	return s
+}
+
+// init sets the stream of tokens of the finished parser.
+func (s *Stream) init(p *parsing) {
+	*s = Stream{
 		t:       p.t,
 		head:    p.head,
 		current: p.head,
 		len:     p.n,
 		wsTail:  p.tail,
+		trivia:  p.trivia,
 		parsed:  p.parsed + p.pos,
+		stats:   p.statistics(),
+		err:     p.error(),
 	}
+	s.undefined()
 }
 
 // NewInfStream creates new stream with active parser.
 func NewInfStream(p *parsing) *Stream {
-	return &Stream{
+	s := &Stream{
 		t:       p.t,
 		p:       p,
 		len:     p.n,
 		head:    p.head,
 		current: p.head,
 	}
+	s.undefined()
+	return s
+}
+
+// undefined points the empty stream to the TokenUndef token, so the current token is never nil.
+func (s *Stream) undefined() {
+	if s.current == nil {
+		s.current = undefToken
+	}
+}
+
+// NewStreamFromTokens creates new stream of tokens without parsing.
+// Tokens are copied and linked in the given order, ids are assigned sequentially from zero.
+// Useful in tests to hand-build token sequences, see NewToken.
+func NewStreamFromTokens(tokens []Token) *Stream {
+	s := &Stream{
+		t:   New(),
+		len: len(tokens),
+	}
+	var ptr *Token
+	for i := range tokens {
+		t := s.t.allocToken()
+		*t = tokens[i].copy()
+		t.id = i
+		if ptr == nil {
+			s.head = t
+		} else {
+			ptr.addNext(t)
+		}
+		ptr = t
+	}
+	if ptr != nil {
+		s.parsed = ptr.offset + len(ptr.value)
+	}
+	s.stats = Stats{Tokens: len(tokens), Bytes: s.parsed}
+	s.current = s.head
+	s.undefined()
+	return s
 }
 
-// SetHistorySize sets the number of tokens that should remain after the current token
+// SetHistorySize sets the number of tokens that should remain before the current token.
+// The history limits the depth of backward navigation in the streaming mode (see GoPrev, GoPrevN, PrevN).
 func (s *Stream) SetHistorySize(size int) *Stream {
 	s.historySize = size
 	return s
 }
 
+// Fork returns an independent cursor over the same tokens.
+// The fork starts from the current position, its pointer moves independently of the stream pointer,
+// so the stream and its forks may be walked concurrently from different goroutines.
+// In the streaming mode the stream and its forks share the reader, the next data-chunk is parsed under the lock.
+// Shared tokens aren't released from the history anymore (see SetHistorySize).
+// The fork doesn't own the tokens: closing the fork doesn't release tokens, close the origin stream when all forks are done.
+func (s *Stream) Fork() *Stream {
+	s.shared = true
+	return &Stream{
+		t:       s.t,
+		len:     s.len,
+		current: s.current,
+		prev:    s.prev,
+		next:    s.next,
+		head:    s.head,
+		wsTail:  s.wsTail,
+		trivia:  s.trivia,
+		parsed:  s.parsed,
+		stats:   s.stats,
+		err:     s.err,
+		file:    s.file,
+		p:       s.p,
+		shared:  true,
+		forked:  true,
+	}
+}
+
 // Close releases all token objects to pool
 func (s *Stream) Close() {
-	for ptr := s.head; ptr != nil; {
+	if s.forked {
+		s.head = undefToken
+		s.current = undefToken
+		s.next = nil
+		s.prev = nil
+		s.len = 0
+		return
+	}
+	for ptr := s.head; ptr != nil && !s.arena; {
 		p := ptr.next
 		s.t.freeToken(ptr)
 		ptr = p
 	}
+	for _, t := range s.trivia {
+		s.free(t)
+	}
+	s.trivia = nil
 	s.next = nil
 	s.prev = nil
 	s.head = undefToken
 	s.current = undefToken
 	s.len = 0
+	if s.unmap != nil {
+		s.unmap()
+		s.unmap = nil
+	}
+	if s.pooled {
+		t := s.t
+		*s = Stream{}
+		t.streams.Put(s)
+	}
+}
+
+// free releases the token to the pool if it isn't allocated from the arena.
+func (s *Stream) free(t *Token) {
+	if !s.arena {
+		s.t.freeToken(t)
+	}
 }
 
+// String returns the dump of tokens from the head token, keys are shown by names (see Tokenizer.SetKeyName).
 func (s *Stream) String() string {
 	items := make([]string, 0, s.len)
 	ptr := s.head
 	for ptr != nil {
-		items = append(items, strconv.Itoa(ptr.id)+": "+ptr.String())
+		items = append(items, strconv.Itoa(ptr.id)+": "+ptr.format(s.t.KeyName(ptr.key)))
 		ptr = ptr.next
 	}
 
 	return strings.Join(items, "\n")
 }
 
+// WriteTo writes the source reconstructed from tokens and their indents to the writer.
+// The source is reproduced byte-for-byte if the whole data is parsed (see StopOnUndefinedToken).
+// Tokens are written from the head token, so in the streaming mode the history should not be limited (see SetHistorySize).
+// The pointer stays unchanged. The method implements io.WriterTo.
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	out := writer{w: w}
+	s.each(func(t *Token) {
+		out.write(t.indent)
+		out.write(t.value)
+	})
+	out.write(s.tail())
+	return out.n, out.err
+}
+
+// each calls `f` for each token from the head token, including trivia tokens, in source order.
+func (s *Stream) each(f func(t *Token)) {
+	for ptr := s.head; ptr != nil && ptr != undefToken; ptr = s.nextOf(ptr) {
+		for _, t := range ptr.trivia {
+			f(t)
+		}
+		f(ptr)
+	}
+	for _, t := range s.trailingTrivia() {
+		f(t)
+	}
+}
+
+// FileName returns the file name of the source, see Tokenizer.ParseBytesNamed.
+func (s *Stream) FileName() string {
+	return s.file
+}
+
+// Position returns the position of the token of the stream with the file name of the source.
+func (s *Stream) Position(t *Token) Position {
+	pos := t.Position()
+	pos.File = s.file
+	return pos
+}
+
+// Err returns the error which stopped parsing: the error of the reader, the error of the context
+// (see Tokenizer.ParseReaderCtx), LimitError, UnterminatedInjectionError or TrailingDotError. The stream ends on the error.
+func (s *Stream) Err() error {
+	if s.p == nil {
+		return s.err
+	}
+	s.lock()
+	defer s.unlock()
+	return s.p.error()
+}
+
 // GetParsedLength returns currently count parsed bytes.
 func (s *Stream) GetParsedLength() int {
 	if s.p == nil {
 		return s.parsed
 	}
+	s.lock()
+	defer s.unlock()
 	return s.p.parsed + s.p.pos
 }
 
+// lock locks the parser if it's shared between forks.
+func (s *Stream) lock() {
+	if s.shared && s.p != nil {
+		s.p.mu.Lock()
+	}
+}
+
+// unlock unlocks the parser if it's shared between forks.
+func (s *Stream) unlock() {
+	if s.shared && s.p != nil {
+		s.p.mu.Unlock()
+	}
+}
+
+// trailingTrivia returns trivia tokens after the last token.
+func (s *Stream) trailingTrivia() []*Token {
+	if s.p != nil {
+		s.lock()
+		defer s.unlock()
+		return s.p.trivia
+	}
+	return s.trivia
+}
+
+// tail returns whitespaces after the last token.
+func (s *Stream) tail() []byte {
+	if s.p != nil {
+		s.lock()
+		defer s.unlock()
+		return s.p.token.indent
+	}
+	return s.wsTail
+}
+
+// TrailingTrivia returns trivia after the last token: comments and whitespaces before the end of the source.
+// In the streaming mode all data will be parsed.
+func (s *Stream) TrailingTrivia() []Trivia {
+	s.parseAll()
+	var trivia []Trivia
+	line, offset := 1, 0
+	last := s.lastToken()
+	for _, c := range s.trailingTrivia() {
+		trivia = appendComment(trivia, c)
+		last = c
+	}
+	if last != nil {
+		line, offset = last.line+countNewLines(last.value), last.offset+len(last.value)
+	}
+	if tail := s.tail(); len(tail) > 0 {
+		trivia = append(trivia, Trivia{Value: tail, Line: line, Offset: offset})
+	}
+	return trivia
+}
+
+// parseNext parses the next data-chunk if the stream reads data from an infinite buffer.
+// Method returns false if no new tokens were produced.
+// The parser must be locked.
+func (s *Stream) parseNext() bool {
+	if s.p == nil {
+		return false
+	}
+	n := s.p.n
+	s.p.parseTokens()
+	if s.shared {
+		s.len = s.p.n - s.head.id
+	} else {
+		s.len += s.p.n - n
+	}
+	return s.p.n > n
+}
+
+// parseAll parses all remaining data if the stream reads data from an infinite buffer.
+func (s *Stream) parseAll() {
+	s.lock()
+	defer s.unlock()
+	for s.parseNext() {
+	}
+}
+
+// nextOf returns the token after the token `t` or nil.
+// If the token `t` is the last parsed token the next data-chunk will be parsed.
+func (s *Stream) nextOf(t *Token) *Token {
+	if t == nil || t == undefToken {
+		return nil
+	}
+	s.lock()
+	defer s.unlock()
+	if t.next == nil {
+		s.parseNext()
+	} else if s.shared && s.p != nil {
+		s.len = s.p.n - s.head.id
+	}
+	return t.next
+}
+
 // GoNext moves stream pointer to the next token.
 // If there is no token, it initiates the parsing of the next chunk of data.
 // If there is no data, the pointer will point to the TokenUndef token.
 func (s *Stream) GoNext() *Stream {
-	if s.current.next != nil {
-		s.current = s.current.next
-		if s.current.next == nil && s.p != nil { // lazy load and parse next data-chunk
-			n := s.p.n
-			s.p.parse()
-			s.len += s.p.n - n
-		}
-		if s.historySize != 0 && s.current.id-s.head.id > s.historySize {
+	if next := s.nextOf(s.current); next != nil {
+		s.current = next
+		s.nextOf(s.current) // lazy load and parse next data-chunk
+		if s.historySize != 0 && !s.shared && s.current.id-s.head.id > s.historySize {
 			t := s.head
 			s.head = s.head.unlink()
-			s.t.freeToken(t)
+			s.free(t)
 			s.len--
 		}
 	} else if s.current == undefToken {
-		s.current = s.prev
-		s.prev = nil
+		if s.next != nil { // return from out of the begin of the list
+			s.current = s.next
+			s.next = nil
+		}
 	} else {
 		s.prev = s.current
 		s.current = undefToken
@@ -118,15 +420,28 @@ func (s *Stream) GoNext() *Stream {
 	return s
 }
 
-// GoPrev moves pointer of stream to the next token.
+// Next is the alias of GoNext without the result.
+// GoNext returns *Stream, so other implementations of TokenStream can't have it, the interface declares Next instead.
+func (s *Stream) Next() {
+	s.GoNext()
+}
+
+// Prev is the alias of GoPrev without the result, see Next.
+func (s *Stream) Prev() {
+	s.GoPrev()
+}
+
+// GoPrev moves pointer of stream to the previous token.
 // The number of possible calls is limited if you specified SetHistorySize.
 // If the beginning of the stream or the end of the history is reached, the pointer will point to the TokenUndef token.
 func (s *Stream) GoPrev() *Stream {
 	if s.current.prev != nil {
 		s.current = s.current.prev
 	} else if s.current == undefToken {
-		s.current = s.next
-		s.prev = nil
+		if s.prev != nil { // return from out of the end of the list
+			s.current = s.prev
+			s.prev = nil
+		}
 	} else {
 		s.next = s.current
 		s.current = undefToken
@@ -137,18 +452,159 @@ func (s *Stream) GoPrev() *Stream {
 // GoTo moves pointer of stream to specific token.
 // The search is done by token ID.
 func (s *Stream) GoTo(id int) *Stream {
+	if s.current == undefToken && !s.seek(id) {
+		return s
+	}
 	if id > s.current.id {
-		for s.current != nil && id != s.current.id {
+		for s.IsValid() && id != s.current.id {
 			s.GoNext()
 		}
 	} else if id < s.current.id {
-		for s.current != nil && id != s.current.id {
+		for s.IsValid() && id != s.current.id {
 			s.GoPrev()
 		}
 	}
 	return s
 }
 
+// Mark saves the current position of the stream pointer.
+// Use Reset to move the pointer back to the saved position, for example, after a failed attempt to parse an alternative.
+func (s *Stream) Mark() Checkpoint {
+	if s.current == nil {
+		return Checkpoint{id: -1}
+	} else if s.current == undefToken {
+		if s.prev != nil {
+			return Checkpoint{id: s.prev.id, bound: 1}
+		} else if s.next != nil {
+			return Checkpoint{id: s.next.id, bound: -1}
+		}
+	}
+	return Checkpoint{id: s.current.id}
+}
+
+// Reset moves the stream pointer to the position saved by Mark.
+// In the streaming mode only the tokens of the history are available (see SetHistorySize),
+// so the history should be large enough to cover the backtracking.
+// Method returns false if the position is no longer available, the pointer stays unchanged in this case.
+func (s *Stream) Reset(cp Checkpoint) bool {
+	if cp.id == -1 {
+		return s.current == nil || s.current == undefToken
+	}
+	if !s.seek(cp.id) {
+		return false
+	}
+	if cp.bound > 0 {
+		s.prev = s.current
+		s.current = undefToken
+	} else if cp.bound < 0 {
+		s.next = s.current
+		s.current = undefToken
+	}
+	return true
+}
+
+// Seek moves the stream pointer directly to the previously parsed token with specific id.
+// Unlike GoTo, the method doesn't parse new data and doesn't walk token-by-token through the stream.
+// Method returns false if the token is not found (not parsed yet or released from the history),
+// the pointer stays unchanged in this case.
+func (s *Stream) Seek(id int) bool {
+	return s.seek(id)
+}
+
+// seek moves the pointer to already parsed token with specific id.
+func (s *Stream) seek(id int) bool {
+	s.lock()
+	defer s.unlock()
+	if s.head == nil || s.head == undefToken || id < s.head.id {
+		return false
+	}
+	ptr := s.current
+	if ptr == undefToken {
+		if s.prev != nil {
+			ptr = s.prev
+		} else {
+			ptr = s.next
+		}
+	}
+	for ptr != nil && ptr.id > id {
+		ptr = ptr.prev
+	}
+	for ptr != nil && ptr.id < id {
+		ptr = ptr.next
+	}
+	if ptr == nil || ptr.id != id {
+		return false
+	}
+	s.current = ptr
+	s.prev = nil
+	s.next = nil
+	if s.current.next == nil {
+		s.parseNext()
+	}
+	return true
+}
+
+// Unread pushes the copy of the token back onto the front of the stream: the token is inserted before the current token
+// and the pointer moves to it. Use NewToken to push a synthetic token, for example, when a parser splits `>>` into two `>`.
+// The pushed token takes the id of the current token, the ids of the next tokens are shifted by one.
+// Do not call Unread while the stream has forks, they will see the ids shifting.
+func (s *Stream) Unread(tok *Token) *Stream {
+	s.lock()
+	defer s.unlock()
+	t := s.t.allocToken()
+	t.key = tok.key
+	t.value = tok.value
+	t.line = tok.line
+	t.column = tok.column
+	t.offset = tok.offset
+	t.indent = tok.indent
+	t.string = tok.string
+	t.folded = tok.folded
+	t.unit = tok.unit
+	t.date = tok.date
+
+	var ptr *Token // the token before which the new token is inserted
+	if s.head == nil {
+		s.head = t
+	} else if s.current == undefToken {
+		if s.prev != nil { // out of the end of the list
+			t.id = s.prev.id + 1
+			s.prev.addNext(t)
+			s.prev = nil
+		} else if s.next != nil { // out of the begin of the list
+			ptr = s.next
+			s.next = nil
+		}
+	} else {
+		ptr = s.current
+	}
+	if ptr != nil {
+		t.id = ptr.id
+		if ptr.prev != nil {
+			ptr.prev.addNext(t)
+		} else {
+			s.head = t
+		}
+		t.addNext(ptr)
+		for ; ptr != nil; ptr = ptr.next {
+			ptr.id++
+		}
+	}
+	if s.p != nil {
+		if s.p.ptr == nil {
+			s.p.head = t
+		}
+		if t.next == nil {
+			s.p.ptr = t
+		}
+		s.p.n++
+		s.p.token.id = s.p.n
+	}
+	s.current = t
+	s.len++
+	return s
+}
+
 // IsValid checks if stream is valid.
 // This means that the pointer has not reached the end of the stream.
 func (s *Stream) IsValid() bool {
@@ -237,16 +693,71 @@ func (s *Stream) PrevToken() *Token {
 	return undefToken
 }
 
+// PrevN returns the n-th token before the current token without moving the pointer.
+// PrevN(0) returns the current token, PrevN(1) is the same as PrevToken.
+// The depth is limited by the history if you specified SetHistorySize.
+// If the token doesn't exist method returns TokenUndef token.
+// Do not save result (Token) into variables — the token may be changed at any time.
+func (s *Stream) PrevN(n int) *Token {
+	ptr := s.current
+	for ; n > 0 && ptr != nil; n-- {
+		ptr = ptr.prev
+	}
+	if ptr == nil || n < 0 {
+		return undefToken
+	}
+	return ptr
+}
+
+// GoPrevN moves pointer of stream n tokens back.
+// The depth is limited by the history if you specified SetHistorySize.
+// If the beginning of the stream or the end of the history is reached, the pointer will point to the TokenUndef token.
+func (s *Stream) GoPrevN(n int) *Stream {
+	for ; n > 0 && s.IsValid(); n-- {
+		s.GoPrev()
+	}
+	return s
+}
+
 // NextToken returns next token from the stream.
 // If next token doesn't exist method return TypeUndef token.
 // Do not save result (Token) into variables — next token may be changed at any time.
 func (s *Stream) NextToken() *Token {
-	if s.current.next != nil {
-		return s.current.next
+	if next := s.nextOf(s.current); next != nil {
+		return next
 	}
 	return undefToken
 }
 
+// PeekN returns the n-th token after the current token without moving the pointer.
+// PeekN(0) returns the current token, PeekN(1) is the same as NextToken.
+// If the token doesn't exist method returns TokenUndef token.
+// Do not save result (Token) into variables — the token may be changed at any time.
+func (s *Stream) PeekN(n int) *Token {
+	ptr := s.current
+	for ; n > 0 && ptr != nil; n-- {
+		ptr = s.nextOf(ptr)
+	}
+	if ptr == nil || n < 0 {
+		return undefToken
+	}
+	return ptr
+}
+
+// NextTokens returns up to n tokens after the current token without moving the pointer.
+// The result is shorter than n if the stream ends.
+// Do not save result (Tokens) into variables — the tokens may be changed at any time.
+func (s *Stream) NextTokens(n int) []*Token {
+	if n <= 0 {
+		return nil
+	}
+	tokens := make([]*Token, 0, n)
+	for ptr := s.nextOf(s.current); ptr != nil && len(tokens) < n; ptr = s.nextOf(ptr) {
+		tokens = append(tokens, ptr)
+	}
+	return tokens
+}
+
 // GoNextIfNextIs moves stream pointer to the next token if the next token has specific token keys.
 // If keys matched pointer will be updated and method returned true. Otherwise, returned false.
 func (s *Stream) GoNextIfNextIs(key TokenKey, otherKeys ...TokenKey) bool {
@@ -257,23 +768,154 @@ func (s *Stream) GoNextIfNextIs(key TokenKey, otherKeys ...TokenKey) bool {
 	return false
 }
 
-// GetSnippet returns slice of tokens.
-// Slice generated from current token position and include tokens before and after current token.
-func (s *Stream) GetSnippet(before, after int) []Token {
-	var segment []Token
-	if s.current == undefToken {
-		if s.prev != nil && before > s.prev.id-s.head.id {
-			before = s.prev.id - s.head.id
+// Tokens returns copies of all tokens from the current token to the end of the stream.
+// The stream is drained: all remaining data will be parsed, and the pointer will point to the TokenUndef token.
+func (s *Stream) Tokens() []Token {
+	if !s.IsValid() {
+		return nil
+	}
+	s.parseAll()
+	tokens := make([]Token, 0, s.len-(s.current.id-s.head.id))
+	for s.IsValid() {
+		tokens = append(tokens, s.current.copy())
+		s.GoNext()
+	}
+	return tokens
+}
+
+// Chan returns a channel that delivers tokens from the current token to the end of the stream.
+// Each token is a detached copy, so it stays valid after the stream has moved on or released the token.
+// The channel is unbuffered, the stream advances only when the consumer receives the token.
+// The channel is closed when the stream ends or the context is canceled.
+// Do not use the stream in other goroutines until the channel is closed.
+func (s *Stream) Chan(ctx context.Context) <-chan *Token {
+	ch := make(chan *Token)
+	go func() {
+		defer close(ch)
+		for s.IsValid() {
+			token := s.current.copy()
+			select {
+			case ch <- &token:
+			case <-ctx.Done():
+				return
+			}
+			s.GoNext()
+		}
+	}()
+	return ch
+}
+
+// Expect checks that the current token has the key and moves the pointer to the next token.
+// Method returns the matched token or UnexpectedTokenError if the key doesn't match, the pointer stays unchanged in this case.
+// Do not save result (Token) into variables — the token may be changed at any time.
+func (s *Stream) Expect(key TokenKey) (*Token, error) {
+	if !s.current.Is(key) {
+		return nil, s.unexpected(s.current, key)
+	}
+	t := s.current
+	s.GoNext()
+	return t, nil
+}
+
+// ConsumeIf moves the pointer to the next token if the current token has any of these keys.
+// Method returns the matched token or nil if the current token doesn't match.
+// Do not save result (Token) into variables — the token may be changed at any time.
+func (s *Stream) ConsumeIf(keys ...TokenKey) *Token {
+	if !s.IsValid() || len(keys) == 0 || !s.current.Is(keys[0], keys[1:]...) {
+		return nil
+	}
+	t := s.current
+	s.GoNext()
+	return t
+}
+
+// ExpectSequence checks that the current and next tokens have exactly the same sequence of keys
+// and moves the pointer to the token after the sequence.
+// Method returns the matched tokens or UnexpectedTokenError for the first mismatched token,
+// the pointer stays unchanged in this case.
+// Do not save result (Tokens) into variables — the tokens may be changed at any time.
+func (s *Stream) ExpectSequence(keys ...TokenKey) ([]*Token, error) {
+	tokens := make([]*Token, 0, len(keys))
+	ptr := s.current
+	for _, key := range keys {
+		if ptr == nil || !ptr.Is(key) {
+			if ptr == nil {
+				ptr = undefToken
+			}
+			return nil, s.unexpected(ptr, key)
+		}
+		tokens = append(tokens, ptr)
+		ptr = s.nextOf(ptr)
+	}
+	for range keys {
+		s.GoNext()
+	}
+	return tokens, nil
+}
+
+// SkipUntil moves the pointer forward to the first token which has any of these keys.
+// The found token is not skipped. If no one token found the pointer will point to the TokenUndef token.
+func (s *Stream) SkipUntil(key TokenKey, otherKeys ...TokenKey) *Stream {
+	for s.IsValid() && !s.current.Is(key, otherKeys...) {
+		s.GoNext()
+	}
+	return s
+}
+
+// SkipWhile moves the pointer forward while the predicate returns true for the current token.
+// If the predicate returns true for all tokens the pointer will point to the TokenUndef token.
+func (s *Stream) SkipWhile(pred func(t *Token) bool) *Stream {
+	for s.IsValid() && pred(s.current) {
+		s.GoNext()
+	}
+	return s
+}
+
+// SkipBalanced skips the group of tokens from the current `open` token to the matching `close` token,
+// tracking the nesting depth, and moves the pointer to the token after the `close` token.
+// For example, with `(` and `)` keys the pointer moves from the first `(` of `(a (b) c) d` to the `d`.
+// Method returns UnexpectedTokenError if the current token is not `open` (the pointer stays unchanged)
+// or if the stream ends before the matching `close` token (the pointer will point to the TokenUndef token).
+func (s *Stream) SkipBalanced(open, close TokenKey) error {
+	if !s.current.Is(open) {
+		return s.unexpected(s.current, open)
+	}
+	depth := 0
+	for s.IsValid() {
+		if s.current.Is(open) {
+			depth++
+		} else if s.current.Is(close) {
+			depth--
+		}
+		s.GoNext()
+		if depth == 0 {
+			return nil
+		}
+	}
+	return s.unexpected(s.current, close)
+}
+
+// lastToken returns the last parsed token of the stream or nil.
+func (s *Stream) lastToken() *Token {
+	s.lock()
+	defer s.unlock()
+	ptr := s.current
+	if ptr == undefToken {
+		if s.prev != nil {
+			ptr = s.prev
 		} else {
-			before = 0
+			ptr = s.next
 		}
-	} else if before > s.current.id-s.head.id {
-		before = s.current.id - s.head.id
 	}
-	if after > s.len-before-1 {
-		after = s.len - before - 1
+	for ptr != nil && ptr.next != nil {
+		ptr = ptr.next
 	}
-	segment = make([]Token, before+after+1)
+	return ptr
+}
+
+// GetSnippet returns slice of tokens.
+// Slice generated from current token position and include tokens before and after current token.
+func (s *Stream) GetSnippet(before, after int) []Token {
 	var ptr *Token
 	if s.next != nil {
 		ptr = s.next
@@ -282,33 +924,20 @@ func (s *Stream) GetSnippet(before, after int) []Token {
 	} else {
 		ptr = s.current
 	}
-	for p := ptr; p != nil; p, before = ptr.prev, before-1 {
-		segment[before] = Token{
-			id:     ptr.id,
-			key:    ptr.key,
-			value:  ptr.value,
-			line:   ptr.line,
-			offset: ptr.offset,
-			indent: ptr.indent,
-			string: ptr.string,
-		}
-		if before <= 0 {
-			break
-		}
+	if ptr == nil || ptr == undefToken {
+		return nil
 	}
-	for p, i := ptr.next, 1; p != nil; p, i = p.next, i+1 {
-		segment[before+i] = Token{
-			id:     p.id,
-			key:    p.key,
-			value:  p.value,
-			line:   p.line,
-			offset: p.offset,
-			indent: p.indent,
-			string: p.string,
-		}
-		if i >= after {
-			break
-		}
+	start := ptr
+	for ; before > 0 && start.prev != nil; before-- {
+		start = start.prev
+	}
+	segment := make([]Token, 0, ptr.id-start.id+after+1)
+	for p := start; p != ptr; p = p.next {
+		segment = append(segment, p.copy())
+	}
+	segment = append(segment, ptr.copy())
+	for p := s.nextOf(ptr); p != nil && after > 0; p, after = s.nextOf(p), after-1 {
+		segment = append(segment, p.copy())
 	}
 	return segment
 }
@@ -330,3 +959,139 @@ func (s *Stream) GetSnippetAsString(before, after, maxStringLength int) string {
 
 	return strings.Join(str, "")
 }
+
+// GetLines returns tokens on source lines from `from` to `to` inclusive, line numbers start from 1.
+// Multiline tokens, like strings, are returned if any of their lines is in the range.
+// Tokens are searched from the head token, so in the streaming mode the history should cover the lines
+// (see SetHistorySize). The pointer stays unchanged.
+func (s *Stream) GetLines(from, to int) []Token {
+	var tokens []Token
+	for ptr := s.head; ptr != nil && ptr != undefToken && ptr.line <= to; ptr = s.nextOf(ptr) {
+		if ptr.line+countNewLines(ptr.value) >= from {
+			tokens = append(tokens, ptr.copy())
+		}
+	}
+	return tokens
+}
+
+// GetLinesAsString returns the source of lines from `from` to `to` inclusive, see GetLines.
+// The source is reconstructed from tokens, their indents and trivia, without the line break after the last line.
+func (s *Stream) GetLinesAsString(from, to int) string {
+	var b strings.Builder
+	write := func(t *Token) {
+		// the line break at the end of the token, like the end of the line comment, belongs to the line of the token
+		if t.line <= to && t.line+countNewLines(bytes.TrimSuffix(t.value, []byte{newLine})) >= from {
+			writeLinePart(&b, t, from)
+		}
+	}
+	ptr := s.head
+	for ; ptr != nil && ptr != undefToken; ptr = s.nextOf(ptr) {
+		for _, tr := range ptr.trivia {
+			write(tr)
+		}
+		if ptr.line > to {
+			break
+		}
+		write(ptr)
+	}
+	if ptr == nil {
+		for _, tr := range s.trailingTrivia() {
+			write(tr)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeLinePart writes the indent and the value of the token, the indent before the line `from` is skipped.
+func writeLinePart(b *strings.Builder, t *Token, from int) {
+	indent := t.indent
+	if b.Len() == 0 {
+		// the indent starts on the line of the previous token
+		for line := t.line - countNewLines(indent); line < from; line++ {
+			i := bytes.IndexByte(indent, newLine)
+			if i < 0 {
+				break
+			}
+			indent = indent[i+1:]
+		}
+	}
+	b.Write(indent)
+	b.Write(t.value)
+}
+
+// FormatError formats the compiler-style diagnostic for the token `tok` of the stream:
+// the `file:line:column: msg` prefix (see Position), the source line of the token and the caret with the underline under the token.
+//
+//	2:5: unexpected token
+//	b = < c
+//	    ^
+//
+// The token may be the TokenUndef token of UnexpectedTokenError or the current token out of the stream (even nil),
+// then the caret points to the end of the last token. The source line is reconstructed from tokens from the head token, so in the streaming mode
+// the history should cover the line (see SetHistorySize). The pointer stays unchanged.
+func (s *Stream) FormatError(tok *Token, msg string) string {
+	if tok == nil || tok == undefToken {
+		end := s.endToken()
+		tok = &end
+	}
+	var src []byte
+	start := -1
+	add := func(t *Token) bool {
+		if start < 0 {
+			start = t.offset - len(t.indent)
+		}
+		src = append(src, t.indent...)
+		src = append(src, t.value...)
+		// stop at the end of the line of the token or if the token is before the source (like the trimmed history)
+		pos := tok.offset - start
+		if pos < 0 {
+			return true
+		}
+		return t.offset+len(t.value) > tok.offset && pos <= len(src) && bytes.IndexByte(src[pos:], newLine) >= 0
+	}
+	done := false
+	for ptr := s.head; !done && ptr != nil && ptr != undefToken; ptr = s.nextOf(ptr) {
+		for _, t := range ptr.trivia {
+			if done = add(t); done {
+				break
+			}
+		}
+		done = done || add(ptr)
+	}
+	if !done && start >= 0 {
+		src = append(src, s.tail()...)
+	}
+	position := s.Position(tok)
+	pos := tok.offset - start
+	if start < 0 || pos < 0 || pos > len(src) {
+		return position.String() + ": " + msg
+	}
+	lineStart := bytes.LastIndexByte(src[:pos], newLine) + 1
+	lineEnd := len(src)
+	if i := bytes.IndexByte(src[pos:], newLine); i >= 0 {
+		lineEnd = pos + i
+	}
+	line := bytes.TrimRight(src[lineStart:lineEnd], "\r")
+
+	var out strings.Builder
+	position.Column = pos - lineStart + 1
+	out.WriteString(position.String() + ": " + msg + "\n")
+	out.Write(line)
+	out.WriteByte(newLine)
+	for _, r := range string(src[lineStart:pos]) {
+		if r == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+	value := tok.value
+	if i := bytes.IndexByte(value, newLine); i >= 0 {
+		value = value[:i]
+	}
+	out.WriteByte('^')
+	if width := utf8.RuneCount(value); width > 1 {
+		out.WriteString(strings.Repeat("~", width-1))
+	}
+	return out.String()
+}
@@ -2,8 +2,13 @@ package tokenizer
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/stretchr/testify/require"
@@ -120,6 +125,552 @@ func TestCustomToken(t *testing.T) {
 	r.True(stream.CurrentToken().Is(TCategory))
 }
 
+func TestStreamTokens(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TCategory, []string{"=="})
+
+	stream := tokenizer.ParseString(`one == 2`)
+	require.Equal(t, []Token{
+		{id: 0, key: TokenKeyword, value: []byte("one"), offset: 0, line: 1, column: 1},
+		{id: 1, key: TCategory, value: []byte("=="), offset: 4, indent: []byte(" "), line: 1, column: 5},
+		{id: 2, key: TokenInteger, value: []byte("2"), offset: 7, indent: []byte(" "), line: 1, column: 8},
+	}, stream.Tokens())
+	require.False(t, stream.IsValid())
+	require.Nil(t, stream.Tokens())
+
+	buffer := bytes.NewBufferString(strings.Repeat("one == 2 ", 100))
+	stream = tokenizer.ParseStream(buffer, 10)
+	stream.GoNext()
+	tokens := stream.Tokens()
+	require.Len(t, tokens, 299)
+	require.Equal(t, 1, tokens[0].ID())
+	require.Equal(t, 299, tokens[298].ID())
+	require.Equal(t, cap(tokens), len(tokens))
+}
+
+func TestStreamChan(t *testing.T) {
+	tokenizer := New()
+	buffer := bytes.NewBufferString(strings.Repeat("one 2 ", 100))
+	stream := tokenizer.ParseStream(buffer, 10).SetHistorySize(2)
+
+	n := 0
+	for token := range stream.Chan(context.Background()) {
+		require.Equal(t, n, token.ID())
+		if n%2 == 0 {
+			require.Equal(t, "one", token.ValueString())
+		} else {
+			require.Equal(t, int64(2), token.ValueInt())
+		}
+		n++
+	}
+	require.Equal(t, 200, n)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream = tokenizer.ParseString("one two three")
+	ch := stream.Chan(ctx)
+	require.Equal(t, "one", (<-ch).ValueString())
+	cancel()
+	for range ch { // channel must be closed after cancellation
+	}
+}
+
+func TestStreamMarkReset(t *testing.T) {
+	tokenizer := New()
+	stream := tokenizer.ParseString("0 1 2 3 4")
+
+	stream.GoNext()
+	cp := stream.Mark()
+	stream.GoNext().GoNext()
+	require.Equal(t, int64(3), stream.CurrentToken().ValueInt())
+	require.True(t, stream.Reset(cp))
+	require.Equal(t, int64(1), stream.CurrentToken().ValueInt())
+
+	for stream.IsValid() {
+		stream.GoNext()
+	}
+	end := stream.Mark()
+	require.True(t, stream.Reset(cp))
+	require.True(t, stream.Reset(end))
+	require.False(t, stream.IsValid())
+	require.Equal(t, int64(4), stream.GoPrev().CurrentToken().ValueInt())
+	require.False(t, stream.IsNextSequence(TokenInteger))
+	require.Equal(t, 4, stream.CurrentToken().ID())
+
+	buffer := bytes.NewBufferString(strings.Repeat("0 1 2 3 4 ", 10))
+	stream = tokenizer.ParseStream(buffer, 8).SetHistorySize(3)
+	cp = stream.GoNext().Mark()
+	stream.GoNext().GoNext()
+	require.True(t, stream.Reset(cp))
+	require.Equal(t, 1, stream.CurrentToken().ID())
+	for i := 0; i < 10; i++ {
+		stream.GoNext()
+	}
+	require.False(t, stream.Reset(cp))
+	require.Equal(t, 11, stream.CurrentToken().ID())
+}
+
+// Before Mark/Reset GoNext after the end returned the pointer back to the last token and GoPrev before the begin
+// returned it to the first token, GoNext before the begin lost the pointer.
+func TestStreamOutOfBounds(t *testing.T) {
+	stream := New().ParseString("0 1")
+
+	stream.GoNext().GoNext()
+	require.False(t, stream.IsValid())
+	stream.GoNext()
+	require.False(t, stream.IsValid(), "the pointer stays after the end")
+	require.Equal(t, int64(1), stream.GoPrev().CurrentToken().ValueInt())
+
+	stream.GoPrev().GoPrev()
+	require.False(t, stream.IsValid())
+	stream.GoPrev()
+	require.False(t, stream.IsValid(), "the pointer stays before the begin")
+	require.Equal(t, int64(0), stream.GoNext().CurrentToken().ValueInt())
+}
+
+func TestStreamSeek(t *testing.T) {
+	tokenizer := New()
+	stream := tokenizer.ParseString("0 1 2 3 4")
+
+	require.True(t, stream.Seek(3))
+	require.Equal(t, int64(3), stream.CurrentToken().ValueInt())
+	require.True(t, stream.Seek(1))
+	require.Equal(t, int64(1), stream.CurrentToken().ValueInt())
+	require.False(t, stream.Seek(5))
+	require.False(t, stream.Seek(-1))
+	require.Equal(t, 1, stream.CurrentToken().ID())
+
+	for stream.IsValid() {
+		stream.GoNext()
+	}
+	require.True(t, stream.Seek(0))
+	require.Equal(t, int64(0), stream.CurrentToken().ValueInt())
+	require.Equal(t, 1, stream.GoNext().CurrentToken().ID())
+}
+
+func TestStreamFork(t *testing.T) {
+	tokenizer := New()
+	stream := tokenizer.ParseString("0 1 2 3 4")
+	stream.GoNext()
+	fork := stream.Fork()
+	fork.GoNext().GoNext()
+	require.Equal(t, int64(1), stream.CurrentToken().ValueInt())
+	require.Equal(t, int64(3), fork.CurrentToken().ValueInt())
+	fork.Close()
+	require.Equal(t, int64(2), stream.GoNext().CurrentToken().ValueInt())
+
+	buffer := bytes.NewBufferString(strings.Repeat("0 1 2 3 4 ", 100))
+	stream = tokenizer.ParseStream(buffer, 16).SetHistorySize(5)
+	forks := []*Stream{stream, stream.Fork(), stream.Fork()}
+	sums := make([]int64, len(forks))
+	wg := sync.WaitGroup{}
+	for i, f := range forks {
+		wg.Add(1)
+		go func(i int, f *Stream) {
+			defer wg.Done()
+			for f.IsValid() {
+				sums[i] += f.CurrentToken().ValueInt()
+				f.GoNext()
+			}
+		}(i, f)
+	}
+	wg.Wait()
+	require.Equal(t, []int64{1000, 1000, 1000}, sums)
+	require.Equal(t, 0, stream.HeadToken().ID())
+}
+
+func TestStreamPeekN(t *testing.T) {
+	tokenizer := New()
+	stream := tokenizer.ParseString("0 1 2 3 4")
+
+	require.Equal(t, int64(0), stream.PeekN(0).ValueInt())
+	require.Equal(t, int64(3), stream.PeekN(3).ValueInt())
+	require.False(t, stream.PeekN(5).IsValid())
+	require.False(t, stream.PeekN(-1).IsValid())
+
+	tokens := stream.GoNext().NextTokens(2)
+	require.Len(t, tokens, 2)
+	require.Equal(t, int64(2), tokens[0].ValueInt())
+	require.Equal(t, int64(3), tokens[1].ValueInt())
+	require.Len(t, stream.NextTokens(10), 3)
+
+	buffer := bytes.NewBufferString(strings.Repeat("0 1 2 3 4 ", 10))
+	stream = tokenizer.ParseStream(buffer, 8)
+	require.Equal(t, 40, stream.PeekN(40).ID())
+	require.Len(t, stream.NextTokens(100), 49)
+}
+
+func TestStreamPrevN(t *testing.T) {
+	tokenizer := New()
+	buffer := bytes.NewBufferString(strings.Repeat("0 1 2 3 4 ", 10))
+	stream := tokenizer.ParseStream(buffer, 8).SetHistorySize(3)
+	stream.GoTo(12)
+
+	require.Equal(t, int64(2), stream.PrevN(0).ValueInt())
+	require.Equal(t, int64(1), stream.PrevN(1).ValueInt())
+	require.Equal(t, 9, stream.PrevN(3).ID())
+	require.False(t, stream.PrevN(4).IsValid())
+	require.False(t, stream.PrevN(-1).IsValid())
+
+	require.Equal(t, "9 10 11 12 13", strings.Join(tokenIDs(stream.GetSnippet(10, 1)), " "))
+	require.Equal(t, "4012", stream.GetSnippetAsString(3, 0, 0))
+	require.Equal(t, 10, stream.GoPrevN(2).CurrentToken().ID())
+	require.False(t, stream.GoPrevN(2).IsValid())
+	require.Equal(t, 9, stream.GoNext().CurrentToken().ID())
+}
+
+func tokenIDs(tokens []Token) []string {
+	ids := make([]string, len(tokens))
+	for i, token := range tokens {
+		ids[i] = strconv.Itoa(token.ID())
+	}
+	return ids
+}
+
+func tokenValues(tokens []Token) []string {
+	values := make([]string, len(tokens))
+	for i, token := range tokens {
+		values[i] = token.ValueString()
+	}
+	return values
+}
+
+func TestStreamUnread(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TCategory, []string{">>", ">"})
+	stream := tokenizer.ParseString("a<b>> c")
+
+	stream.GoTo(3)
+	shift := stream.CurrentToken()
+	require.Equal(t, ">>", shift.ValueString())
+	stream.GoNext()
+	stream.Unread(NewToken(TCategory, []byte(">"), shift.Line(), shift.Offset()+1))
+	stream.Unread(NewToken(TCategory, []byte(">"), shift.Line(), shift.Offset()))
+	stream.GoPrev()
+	stream.Unread(NewToken(TokenKeyword, []byte("d"), 1, 0))
+
+	require.Equal(t, 3, stream.CurrentToken().ID())
+	require.Equal(t, "a<bd>>>>c", stream.GetSnippetAsString(10, 10, 0))
+	require.Equal(t, "0 1 2 3 4 5 6 7", strings.Join(tokenIDs(stream.GetSnippet(10, 10)), " "))
+
+	for stream.IsValid() {
+		stream.GoNext()
+	}
+	stream.Unread(NewToken(TokenKeyword, []byte("e"), 1, 7))
+	require.Equal(t, 8, stream.CurrentToken().ID())
+	require.Equal(t, 7, stream.PrevToken().ID())
+	require.False(t, stream.NextToken().IsValid())
+
+	buffer := bytes.NewBufferString(strings.Repeat("0 1 2 3 4 ", 10))
+	stream = tokenizer.ParseStream(buffer, 8)
+	stream.GoTo(2).Unread(stream.PrevToken())
+	require.Equal(t, int64(1), stream.CurrentToken().ValueInt())
+	require.Len(t, stream.Tokens(), 49)
+}
+
+type sliceStream struct {
+	tokens []*Token
+	i      int
+}
+
+func (s *sliceStream) token(i int) *Token {
+	if i < 0 || i >= len(s.tokens) {
+		return undefToken
+	}
+	return s.tokens[i]
+}
+
+func (s *sliceStream) CurrentToken() *Token { return s.token(s.i) }
+func (s *sliceStream) PrevToken() *Token    { return s.token(s.i - 1) }
+func (s *sliceStream) NextToken() *Token    { return s.token(s.i + 1) }
+func (s *sliceStream) IsValid() bool        { return s.i >= 0 && s.i < len(s.tokens) }
+func (s *sliceStream) Next()                { s.i++ }
+func (s *sliceStream) Prev()                { s.i-- }
+func (s *sliceStream) GoNextIfNextIs(key TokenKey, otherKeys ...TokenKey) bool {
+	if s.NextToken().Is(key, otherKeys...) {
+		s.Next()
+		return true
+	}
+	return false
+}
+
+func TestTokenStream(t *testing.T) {
+	sum := func(stream TokenStream) (n int64) {
+		for stream.IsValid() {
+			n += stream.CurrentToken().ValueInt()
+			stream.Next()
+		}
+		return n
+	}
+
+	require.Equal(t, int64(6), sum(New().ParseString("1 2 3")))
+	require.Equal(t, int64(3), sum(&sliceStream{tokens: []*Token{
+		NewToken(TokenInteger, []byte("1"), 1, 0),
+		NewToken(TokenInteger, []byte("2"), 1, 2),
+	}}))
+}
+
+func TestNewStreamFromTokens(t *testing.T) {
+	stream := NewStreamFromTokens([]Token{
+		*NewToken(TokenKeyword, []byte("one"), 1, 0),
+		*NewToken(TCategory, []byte("=="), 2, 4),
+		*NewToken(TokenInteger, []byte("3"), 3, 7),
+	})
+
+	require.Equal(t, 8, stream.GetParsedLength())
+	require.True(t, stream.IsNextSequence(TCategory, TokenInteger))
+	require.Equal(t, "one", stream.CurrentToken().ValueString())
+	require.Equal(t, 2, stream.GoNext().CurrentToken().Line())
+	require.Equal(t, 2, stream.GoNext().CurrentToken().ID())
+	require.Equal(t, 7, stream.CurrentToken().Offset())
+	require.False(t, stream.GoNext().IsValid())
+	stream.Close()
+
+	require.False(t, NewStreamFromTokens(nil).IsValid())
+}
+
+func TestStreamExpect(t *testing.T) {
+	compareKey := TokenKey(10)
+	tokenizer := New()
+	tokenizer.DefineTokens(compareKey, []string{"==", ">"})
+	stream := tokenizer.ParseString("one == 2\nthree > four")
+
+	tok, err := stream.Expect(TokenKeyword)
+	require.NoError(t, err)
+	require.Equal(t, "one", tok.ValueString())
+
+	_, err = stream.Expect(TokenKeyword)
+	require.EqualError(t, err, `expected -1, got 10 "==" on line 1`)
+	var unexpected *UnexpectedTokenError
+	require.ErrorAs(t, err, &unexpected)
+	require.Equal(t, []TokenKey{TokenKeyword}, unexpected.Expected)
+	require.Equal(t, 1, unexpected.Token.ID())
+
+	require.Nil(t, stream.ConsumeIf(TokenKeyword, TokenInteger))
+	require.Equal(t, "==", stream.ConsumeIf(TokenKeyword, compareKey).ValueString())
+
+	_, err = stream.ExpectSequence(TokenInteger, TokenKeyword, TokenKeyword)
+	require.EqualError(t, err, `expected -1, got 10 ">" on line 2`)
+	require.Equal(t, 2, stream.CurrentToken().ID())
+
+	tokens, err := stream.ExpectSequence(TokenInteger, TokenKeyword, compareKey)
+	require.NoError(t, err)
+	require.Len(t, tokens, 3)
+	require.Equal(t, "three", tokens[1].ValueString())
+	require.Equal(t, "four", stream.CurrentToken().ValueString())
+
+	_, err = stream.ExpectSequence(TokenKeyword, TokenInteger)
+	require.EqualError(t, err, `expected -2, got end of stream on line 2`)
+	require.Equal(t, 21, err.(*UnexpectedTokenError).Token.Offset())
+}
+
+func TestStreamEmpty(t *testing.T) {
+	tokenizer := New()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, tokenizer.ParseString("  ").WriteBinary(buf))
+	restored, err := tokenizer.ReadBinary(buf)
+	require.NoError(t, err)
+	for name, stream := range map[string]*Stream{
+		"bytes":  tokenizer.ParseString(""),
+		"stream": tokenizer.ParseStream(strings.NewReader("  "), 8),
+		"tokens": NewStreamFromTokens(nil),
+		"binary": restored,
+		"trivia": tokenizer.ParseBytesPooled(nil),
+		"unread": tokenizer.ParseString(" "),
+	} {
+		require.NotNil(t, stream.CurrentToken(), name)
+		require.False(t, stream.IsValid(), name)
+		require.False(t, stream.GoNext().GoPrev().IsValid(), name)
+		_, err := stream.Expect(TokenKeyword)
+		require.EqualError(t, err, "expected -1, got end of stream on line 1", name)
+		if name == "unread" {
+			require.True(t, stream.Unread(NewToken(TokenKeyword, []byte("one"), 1, 0)).IsValid())
+			require.Equal(t, "one", stream.HeadToken().ValueString())
+		}
+		stream.Close()
+	}
+}
+
+func TestStreamFormatError(t *testing.T) {
+	compareKey := TokenKey(10)
+	tokenizer := New()
+	tokenizer.DefineTokens(compareKey, []string{"==", ">"})
+	tokenizer.DefineStringToken(TokenKey(11), `"`, `"`)
+	stream := tokenizer.ParseString("one == 2\n\tthree > \"четыре\" five\nsix")
+	defer stream.Close()
+
+	_, err := stream.GoTo(5).Expect(TokenKeyword)
+	var unexpected *UnexpectedTokenError
+	require.ErrorAs(t, err, &unexpected)
+	require.Equal(t, "2:10: expected keyword\n\tthree > \"четыре\" five\n\t        ^~~~~~~~",
+		stream.FormatError(&unexpected.Token, "expected keyword"))
+	require.Equal(t, "1:1: unexpected token\none == 2\n^~~", stream.FormatError(stream.HeadToken(), "unexpected token"))
+
+	_, err = stream.GoTo(7).GoNext().Expect(TokenKeyword)
+	require.ErrorAs(t, err, &unexpected)
+	require.Equal(t, "3:4: unexpected end\nsix\n   ^", stream.FormatError(&unexpected.Token, "unexpected end"))
+	require.Equal(t, "3:4: unexpected end\nsix\n   ^", stream.FormatError(stream.CurrentToken(), "unexpected end"))
+	empty := tokenizer.ParseString("")
+	defer empty.Close()
+	require.Equal(t, "1:1: unexpected end", empty.FormatError(empty.CurrentToken(), "unexpected end"))
+	require.Equal(t, "1:1: unexpected end", empty.FormatError(nil, "unexpected end"))
+
+	stream = tokenizer.ParseStream(strings.NewReader(strings.Repeat("one == 2\n", 10)), 8).SetHistorySize(2)
+	first := *stream.CurrentToken()
+	stream.GoTo(20)
+	require.Equal(t, "1:1: unexpected token", stream.FormatError(&first, "unexpected token"))
+	synthetic := NewToken(TokenKeyword, []byte("two"), 1, 0)
+	require.Equal(t, "1: unexpected token", stream.FormatError(synthetic, "unexpected token"))
+}
+
+func TestStreamPosition(t *testing.T) {
+	compareKey := TokenKey(10)
+	tokenizer := New()
+	tokenizer.DefineTokens(compareKey, []string{"=="})
+	stream := tokenizer.ParseBytesNamed("main.cfg", []byte("one == 2\nthree =="))
+	defer stream.Close()
+
+	require.Equal(t, "main.cfg", stream.FileName())
+	require.Equal(t, Position{File: "main.cfg", Line: 2, Column: 1, Offset: 9}, stream.Position(stream.GoTo(3).CurrentToken()))
+	require.Equal(t, "main.cfg:2:1", stream.Position(stream.CurrentToken()).String())
+	require.Equal(t, "2:1", stream.CurrentToken().Position().String())
+	require.Equal(t, "2", Position{Line: 2}.String())
+
+	_, err := stream.GoTo(1).Expect(TokenKeyword)
+	require.EqualError(t, err, `expected -1, got 10 "==" at main.cfg:1:5`)
+	_, err = stream.GoTo(4).GoNext().Expect(TokenKeyword)
+	require.EqualError(t, err, `expected -1, got end of stream at main.cfg:2:9`)
+	var unexpected *UnexpectedTokenError
+	require.ErrorAs(t, err, &unexpected)
+	require.Equal(t, "main.cfg:2:9: expected keyword\nthree ==\n        ^", stream.FormatError(&unexpected.Token, "expected keyword"))
+	require.Equal(t, "main.cfg", stream.Fork().FileName())
+}
+
+func TestStreamKeyNames(t *testing.T) {
+	compareKey := TokenKey(10)
+	tokenizer := New().SetKeyName(compareKey, "COMPARE_OP").SetKeyName(TokenKeyword, "IDENT").SetKeyName(TokenString, "STRING")
+	tokenizer.DefineTokens(compareKey, []string{"=="})
+	tokenizer.DefineStringToken(TokenKey(11), `"`, `"`)
+	require.Contains(t, tokenizer.ParseString(`"two"`).CurrentToken().String(), "\tKey: STRING\n")
+	stream := tokenizer.ParseString("one ==")
+	defer stream.Close()
+
+	require.Equal(t, "COMPARE_OP", tokenizer.KeyName(compareKey))
+	require.Equal(t, "-2", tokenizer.KeyName(TokenInteger))
+	require.Contains(t, stream.String(), "1: {\n\tId: 1\n\tKey: COMPARE_OP\n")
+	require.Contains(t, stream.CurrentToken().String(), "\tKey: -1\n")
+
+	err := stream.unexpected(stream.GoNext().CurrentToken(), TokenKeyword, TokenInteger)
+	require.EqualError(t, err, `expected IDENT or -2, got COMPARE_OP "==" on line 1`)
+}
+
+func TestStreamKeyDescriptions(t *testing.T) {
+	compareKey := TokenKey(10)
+	tokenizer := New().AllowKeywordUnderscore().
+		SetKeyDescription(compareKey, "a comparison operator").
+		SetKeyDescription(TokenString, "a quoted string")
+	tokenizer.DefineTokens(compareKey, []string{"==", ">"})
+	tokenizer.DefineStringToken(TokenKey(11), `"`, `"`)
+	stream := tokenizer.ParseString("user_agent == \"wget\"\nand agent curl")
+	defer stream.Close()
+
+	require.Equal(t, "a quoted string", tokenizer.KeyDescription(TokenString))
+	require.Equal(t, "-1", tokenizer.KeyDescription(TokenKeyword))
+	require.Equal(t, "user_agent", stream.CurrentToken().ValueString())
+
+	_, err := stream.GoTo(4).Expect(compareKey)
+	require.EqualError(t, err, `expected a comparison operator, found "agent" at 2:5`)
+	_, err = stream.GoTo(5).Expect(compareKey)
+	require.EqualError(t, err, `expected a comparison operator, found "curl" at 2:11`)
+	_, err = stream.GoNext().Expect(TokenString)
+	require.EqualError(t, err, `expected a quoted string, found end of input at 2:15`)
+	_, err = stream.GoTo(0).Expect(TokenInteger)
+	require.EqualError(t, err, `expected -2, got -1 "user_agent" on line 1`)
+}
+
+func TestStreamGetLines(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineStringToken(TokenKey(10), "#", "\n")
+	tokenizer.DefineTrivia(TokenKey(10))
+	tokenizer.DefineStringToken(TokenKey(11), `"`, `"`)
+	stream := tokenizer.ParseString("one two\n  three # comment\n  four \"five\nsix\" seven\neight")
+	defer stream.Close()
+
+	require.Equal(t, []string{"three", "four", "\"five\nsix\""}, tokenValues(stream.GetLines(2, 3)))
+	require.Equal(t, "  three # comment\n  four \"five\nsix\"", stream.GetLinesAsString(2, 3))
+	require.Equal(t, "one two\n  three # comment", stream.GetLinesAsString(1, 2))
+	require.Equal(t, "  four \"five\nsix\"", stream.GetLinesAsString(3, 3))
+	require.Equal(t, []string{"\"five\nsix\"", "seven"}, tokenValues(stream.GetLines(4, 4)))
+	require.Equal(t, []string{"eight"}, tokenValues(stream.GetLines(5, 10)))
+	require.Empty(t, stream.GetLines(6, 10))
+	require.Equal(t, 0, stream.CurrentToken().ID())
+}
+
+func TestStreamSkip(t *testing.T) {
+	semicolonKey := TokenKey(10)
+	tokenizer := New()
+	tokenizer.DefineTokens(semicolonKey, []string{";"})
+	stream := tokenizer.ParseString("one 2 three; 4 five")
+
+	require.Equal(t, 3, stream.SkipUntil(semicolonKey).CurrentToken().ID())
+	require.Equal(t, 3, stream.SkipUntil(semicolonKey, TokenInteger).CurrentToken().ID())
+	require.Equal(t, "five", stream.SkipWhile(func(t *Token) bool {
+		return !t.IsKeyword()
+	}).CurrentToken().ValueString())
+	require.False(t, stream.SkipUntil(semicolonKey).IsValid())
+	require.False(t, stream.SkipWhile(func(t *Token) bool { return true }).IsValid())
+}
+
+func TestStreamSkipBalanced(t *testing.T) {
+	openKey := TokenKey(10)
+	closeKey := TokenKey(11)
+	tokenizer := New()
+	tokenizer.DefineTokens(openKey, []string{"("})
+	tokenizer.DefineTokens(closeKey, []string{")"})
+
+	stream := tokenizer.ParseString("(a (b) (c (d)) e) f (g")
+	require.NoError(t, stream.SkipBalanced(openKey, closeKey))
+	require.Equal(t, "f", stream.CurrentToken().ValueString())
+
+	err := stream.SkipBalanced(openKey, closeKey)
+	require.EqualError(t, err, `expected 10, got -1 "f" on line 1`)
+	require.Equal(t, "f", stream.CurrentToken().ValueString())
+
+	err = stream.GoNext().SkipBalanced(openKey, closeKey)
+	require.EqualError(t, err, `expected 11, got end of stream on line 1`)
+	require.False(t, stream.IsValid())
+
+	empty := tokenizer.ParseString("")
+	defer empty.Close()
+	require.EqualError(t, empty.SkipBalanced(openKey, closeKey), `expected 10, got end of stream on line 1`)
+}
+
+func TestStreamWriteTo(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineTokens(TokenKey(12), []string{">="})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).SetEscapeSymbol(BackSlash).AddInjection(10, 11)
+	tokenizer.DefineStringToken(TokenKey(15), "//", "\n")
+
+	for _, str := range []string{
+		"",
+		" \n ",
+		"one",
+		" one >= 2.5e3 // comment\n\t\"two\n {{ three  }}\\\" four\"  дом! ",
+	} {
+		out := bytes.NewBuffer(nil)
+		n, err := tokenizer.ParseString(str).WriteTo(out)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(str)), n)
+		require.Equal(t, str, out.String())
+
+		out.Reset()
+		_, err = tokenizer.ParseStream(bytes.NewBufferString(strings.Repeat(str, 10)), 7).WriteTo(out)
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat(str, 10), out.String())
+	}
+}
+
 func TestHistory(t *testing.T) {
 	tokenizer := New()
 	tokens := tokenizer.ParseString("0 1 2 3 4 5 6 7 8 9")
@@ -334,3 +885,90 @@ func BenchmarkParseBytes(b *testing.B) {
 	size := len(reader.data)
 	b.Logf("Speed: %d bytes string with %s: %d byte/sec", size, dif, int(float64(size)/dif.Seconds()))
 }
+
+func BenchmarkParseLongStrings(b *testing.B) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(1), []string{"="})
+	tokenizer.DefineStringToken(TokenKey(2), `"`, `"`).SetEscapeSymbol('\\')
+	data := bytes.Repeat([]byte(`key = "`+strings.Repeat("long value of the string \\\" ", 40)+`" 12345678901234567890`+"\n"), 100)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.ParseBytes(data).Close()
+	}
+}
+
+func TestParseStreamWith(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"="})
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`)
+	data := strings.Repeat(`key = "value" `, 100) + `long = "` + strings.Repeat("x", 100) + `"`
+
+	stream := tokenizer.ParseStreamWith(strings.NewReader(data), StreamOptions{ChunkSize: 16, HistorySize: 3})
+	defer stream.Close()
+	stream.GoTo(100)
+	require.Equal(t, 100, stream.CurrentToken().ID())
+	require.Equal(t, 97, stream.HeadToken().ID())
+	n := 100
+	for ; stream.IsValid(); stream.GoNext() {
+		n++
+	}
+	require.NoError(t, stream.Err())
+	require.Equal(t, 303, n)
+
+	stream = tokenizer.ParseStreamWith(strings.NewReader(data), StreamOptions{ChunkSize: 16, MaxTokenSize: 50})
+	defer stream.Close()
+	for n = 0; stream.IsValid(); stream.GoNext() {
+		n++
+	}
+	var limit *LimitError
+	require.ErrorAs(t, stream.Err(), &limit)
+	require.Equal(t, LimitTokenLength, limit.Limit)
+	require.Equal(t, 302, n)
+	require.Zero(t, tokenizer.limits.tokenLength)
+}
+
+func TestStreamChunkBoundaries(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AllowKeywordUnderscore()
+	tokenizer.DefineTokens(TokenKey(10), []string{">>=", ">=", ">>", ">", "=", "==", "{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineTokens(TokenKey(12), []string{"(", ")", ";", "+", "-"})
+	tokenizer.DefineTokens(TokenKey(13), []string{"not", "not in"})
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`).SetEscapeSymbol('\\').AddInjection(10, 11)
+	tokenizer.DefineStringToken(TokenKey(21), "<![CDATA[", "]]>")
+	tokenizer.DefineStringToken(TokenKey(22), "//", "\n")
+	tokenizer.DefineStringToken(TokenKey(23), `"`, `"`).SetPrefix("r")
+	tokenizer.DefineCharToken(TokenKey(24), "'")
+	tokenizer.DefineTrivia(TokenKey(22))
+
+	sources := []string{
+		"a >>= b >= c >> d > e == f",
+		"привет, мир: ключ=значение; 世界 ∑",
+		"1.5e-10 + 2E+3 - 0.25 1234567890 x.y",
+		`s = "one \" {{ a >= "two {{ b }}" }} three" ;`,
+		"<![CDATA[ one ]] two ]]> // comment ✓\n next",
+		`r"raw \n" 'a' '\n' '☺' 'я'`,
+		"x not in y not z\r\n\t end",
+	}
+	for _, src := range sources {
+		expected := tokenizer.ParseString(src)
+		dump := expected.String()
+		trailing := expected.TrailingTrivia()
+		expected.Close()
+		for size := uint(1); size <= uint(len(src))+1; size++ {
+			stream := tokenizer.ParseStream(strings.NewReader(src), size)
+			stream.parseAll()
+			require.Equal(t, dump, stream.String(), "chunk size %d of %q", size, src)
+			require.Equal(t, trailing, stream.TrailingTrivia(), "chunk size %d of %q", size, src)
+			require.NoError(t, stream.Err())
+			stream.Close()
+		}
+		// readers may return less bytes than requested
+		stream := tokenizer.ParseStream(iotest.HalfReader(strings.NewReader(src)), 8)
+		stream.parseAll()
+		require.Equal(t, dump, stream.String(), "half reader of %q", src)
+		stream.Close()
+	}
+}
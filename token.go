@@ -1,8 +1,13 @@
 package tokenizer
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 var undefToken = &Token{
@@ -15,14 +20,31 @@ type Token struct {
 	key    TokenKey
 	value  []byte
 	line   int
+	column int
 	offset int
 	indent []byte
 	string *StringSettings
+	trivia []*Token
+	data   interface{}
+	folded []byte            // the value in lower case, if it differs, see Tokenizer.FoldKeywords
+	unit   int               // the length of the unit suffix merged into the number, see Tokenizer.DefineUnits
+	date   *DateTimeSettings // settings of the date and time literal, see Tokenizer.DefineDateTimeToken
 
 	prev *Token
 	next *Token
 }
 
+// NewToken creates a detached token with specific key, value and position.
+// Useful for synthetic tokens, see Stream.Unread.
+func NewToken(key TokenKey, value []byte, line, offset int) *Token {
+	return &Token{
+		key:    key,
+		value:  value,
+		line:   line,
+		offset: offset,
+	}
+}
+
 // addNext add new token as next node of dl-list.
 func (t *Token) addNext(next *Token) {
 	next.prev = t
@@ -40,15 +62,58 @@ func (t *Token) unlink() *Token {
 	return next
 }
 
+// copy returns the detached copy of the token — the copy is not linked to the stream.
+func (t *Token) copy() Token {
+	return Token{
+		id:     t.id,
+		key:    t.key,
+		value:  t.value,
+		line:   t.line,
+		column: t.column,
+		offset: t.offset,
+		indent: t.indent,
+		string: t.string,
+		trivia: t.trivia,
+		data:   t.data,
+		folded: t.folded,
+		unit:   t.unit,
+		date:   t.date,
+	}
+}
+
+// Copy returns the detached copy of the token with own copies of the value, the indent and trivia tokens,
+// so the copy stays valid after the input buffer is reused or released, see Tokenizer.CopyValues.
+func (t *Token) Copy() *Token {
+	c := t.copy()
+	c.value = bytes.Clone(t.value)
+	c.indent = bytes.Clone(t.indent)
+	c.folded = bytes.Clone(t.folded)
+	c.trivia = nil
+	for _, tr := range t.trivia {
+		c.trivia = append(c.trivia, tr.Copy())
+	}
+	return &c
+}
+
 // ID returns id of token. Id is the sequence number of tokens in the stream.
 func (t *Token) ID() int {
 	return t.id
 }
 
 // String returns a multiline string with the token's information.
+// Names of keys (see Tokenizer.SetKeyName) are known only by tokens of framed strings, other tokens don't refer
+// to the tokenizer and have numeric keys, use Stream.String to get names of all keys.
 func (t *Token) String() string {
-	return fmt.Sprintf("{\n\tId: %d\n\tKey: %d\n\tValue: %s\n\tPosition: %d\n\tIndent: %d bytes\n\tLine: %d\n}",
-		t.id, t.key, t.value, t.offset, len(t.indent), t.line)
+	if t.string != nil && t.string.t != nil {
+		return t.format(t.string.t.KeyName(t.key))
+	}
+	return t.format(strconv.Itoa(int(t.key)))
+}
+
+// format returns a multiline string with the token's information and the name of the key.
+func (t *Token) format(key string) string {
+	return fmt.Sprintf("{\n\tId: %d\n\tKey: %s\n\tValue: %s\n\tPosition: %d\n\tIndent: %d bytes\n\tLine: %d\n}",
+		t.id, key, t.value, t.offset, len(t.indent), t.line)
 }
 
 // IsValid checks if this token is valid — the key is not TokenUndef.
@@ -78,33 +143,199 @@ func (t *Token) IsInteger() bool {
 
 // ValueInt returns value as int64.
 // If the token is float the result wild be round by math's rules.
-// If the token is not TokenInteger or TokenFloat zero will be returned.
+// If the token is not TokenInteger or TokenFloat (or the number with the merged unit) zero will be returned.
 // Method doesn't use cache. Each call starts a number parser.
 func (t *Token) ValueInt() int64 {
-	if t.key == TokenInteger {
-		num, _ := strconv.ParseInt(b2s(t.value), 10, 64)
+	value, key := t.number()
+	if key == TokenInteger {
+		num, _ := strconv.ParseInt(b2s(value), 10, 64)
 		return num
-	} else if t.key == TokenFloat {
-		num, _ := strconv.ParseFloat(b2s(t.value), 64)
+	} else if key == TokenFloat {
+		num, _ := strconv.ParseFloat(b2s(value), 64)
+		if math.IsInf(num, 0) || math.IsNaN(num) {
+			return 0
+		}
 		return int64(num)
 	}
 	return 0
 }
 
 // ValueFloat returns value as float64.
-// If the token is not TokenInteger or TokenFloat zero will be returned.
+// If the token is not TokenInteger or TokenFloat (or the number with the merged unit) zero will be returned.
 // Method doesn't use cache. Each call starts a number parser.
 func (t *Token) ValueFloat() float64 {
-	if t.key == TokenFloat {
-		num, _ := strconv.ParseFloat(b2s(t.value), 64)
+	value, key := t.number()
+	if key == TokenFloat {
+		num, err := strconv.ParseFloat(b2s(value), 64)
+		if err != nil {
+			if special, ok := infNaN(value); ok {
+				return special
+			}
+		}
+		return num
+	} else if key == TokenInteger {
+		// integers out of the int64 range are approximated, not clipped
+		num, _ := strconv.ParseFloat(b2s(value), 64)
 		return num
-	} else if t.key == TokenInteger {
-		num, _ := strconv.ParseInt(b2s(t.value), 10, 64)
-		return float64(num)
 	}
 	return 0.0
 }
 
+// ValueBigInt returns value as big.Int without the overflow, for integers out of the int64 range.
+// If the token is float the fraction is truncated. If the token is not TokenInteger or TokenFloat
+// (or the number with the merged unit) nil will be returned.
+func (t *Token) ValueBigInt() *big.Int {
+	value, key := t.number()
+	if key == TokenInteger {
+		num, ok := new(big.Int).SetString(b2s(value), 10)
+		if !ok {
+			return nil
+		}
+		return num
+	} else if key == TokenFloat {
+		// the rational number is exact for decimal floats, unlike big.Float
+		if num, ok := new(big.Rat).SetString(b2s(value)); ok {
+			return num.Num().Quo(num.Num(), num.Denom())
+		}
+	}
+	return nil
+}
+
+// ValueBigFloat returns value as big.Float with the precision enough for all digits of the value,
+// so long mantissas and exponents out of the float64 range aren't lost.
+// If the token is not TokenInteger or TokenFloat (or the number with the merged unit) nil will be returned.
+func (t *Token) ValueBigFloat() *big.Float {
+	value, key := t.number()
+	if key != TokenInteger && key != TokenFloat {
+		return nil
+	}
+	// about 3.33 bits per the decimal digit
+	prec := uint(len(value)) * 4
+	if prec < 64 {
+		prec = 64
+	}
+	num, _, err := big.ParseFloat(b2s(value), 10, prec, big.ToNearestEven)
+	if err != nil {
+		if special, ok := infNaN(value); ok && math.IsInf(special, 0) {
+			return new(big.Float).SetInf(special < 0)
+		}
+		return nil
+	}
+	return num
+}
+
+// ValueUnit returns the unit suffix merged into the number, like `ms` of `200ms`, see UnitSettings.Merge.
+// Nil is returned if the token has no merged unit.
+func (t *Token) ValueUnit() []byte {
+	if t.unit == 0 {
+		return nil
+	}
+	return t.value[len(t.value)-t.unit:]
+}
+
+// number returns the value and the key of the number: the value of TokenInteger and TokenFloat,
+// or the value without the unit of the number with the merged unit. Separators of thousands and underscores
+// are skipped, see Tokenizer.SetThousandsSeparator.
+func (t *Token) number() ([]byte, TokenKey) {
+	value, key := t.value, t.key
+	if t.unit > 0 {
+		value = value[:len(value)-t.unit]
+		key = TokenInteger
+		if bytes.ContainsAny(value, ".eE") {
+			key = TokenFloat
+		}
+	}
+	if key != TokenInteger && key != TokenFloat {
+		return value, key
+	}
+	for i, b := range value {
+		if !numberSymbol(b) {
+			digits := append(make([]byte, 0, len(value)), value[:i]...)
+			for _, b := range value[i:] {
+				if numberSymbol(b) {
+					digits = append(digits, b)
+				}
+			}
+			return digits, key
+		}
+	}
+	return value, key
+}
+
+// numberSymbol checks if `b` may be the part of the decoded number: the digit, the letter (like `e` or `Inf`),
+// the dot or the sign.
+func numberSymbol(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '.' || b == '-' || b == '+'
+}
+
+// infNaN decodes the spelling of infinity or not-a-number, like `-Inf`, `NaN` or `.inf`, see Tokenizer.AllowInfNaN.
+func infNaN(value []byte) (float64, bool) {
+	s := b2s(value)
+	sign := ""
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	s = strings.TrimPrefix(s, ".")
+	if len(s) == 0 || s[0] != 'i' && s[0] != 'I' && s[0] != 'n' && s[0] != 'N' {
+		return 0, false // not a number in the hex or the exponent form, like `0x1p-2`
+	}
+	num, err := strconv.ParseFloat(sign+s, 64)
+	return num, err == nil && (math.IsInf(num, 0) || math.IsNaN(num))
+}
+
+// Trivia describes one piece of leading trivia of the token: comment or whitespaces.
+type Trivia struct {
+	// Key is the key (or the string key) of the comment token. Key is TokenUndef for whitespaces.
+	Key TokenKey
+	// Value is the source of the comment or whitespaces.
+	Value []byte
+	// Line is the line number where the trivia starts.
+	Line int
+	// Offset is the byte position of the trivia in input string.
+	Offset int
+}
+
+// IsComment checks if the trivia is comment.
+func (t Trivia) IsComment() bool {
+	return t.Key != TokenUndef
+}
+
+// NewLines returns count of new lines in the trivia. Two or more new lines in whitespaces mean blank lines.
+func (t Trivia) NewLines() int {
+	return countNewLines(t.Value)
+}
+
+// Trivia returns leading trivia of the token in source order: comments (see Tokenizer.DefineTrivia)
+// and whitespaces between them, including the indent of the token.
+// Method doesn't use cache. Each call builds new slice.
+func (t *Token) Trivia() []Trivia {
+	var trivia []Trivia
+	for _, c := range t.trivia {
+		trivia = appendComment(trivia, c)
+	}
+	return appendIndent(trivia, t)
+}
+
+// appendComment appends the trivia token with its indent.
+func appendComment(trivia []Trivia, c *Token) []Trivia {
+	trivia = appendIndent(trivia, c)
+	key := c.key
+	if c.string != nil {
+		key = c.string.Key
+	}
+	return append(trivia, Trivia{Key: key, Value: c.value, Line: c.line, Offset: c.offset})
+}
+
+// appendIndent appends the indent of the token as whitespaces trivia.
+func appendIndent(trivia []Trivia, t *Token) []Trivia {
+	if len(t.indent) == 0 {
+		return trivia
+	}
+	ws := Trivia{Value: t.indent, Line: t.line, Offset: t.offset - len(t.indent)}
+	ws.Line -= ws.NewLines()
+	return append(trivia, ws)
+}
+
 // Indent returns spaces before the token.
 func (t *Token) Indent() []byte {
 	return t.indent
@@ -139,11 +370,47 @@ func (t *Token) Line() int {
 	return t.line
 }
 
+// Column returns the byte position of the token in the line.
+// Column numbers starts from 1. Column is zero for hand-built tokens (see NewToken).
+func (t *Token) Column() int {
+	return t.column
+}
+
 // Offset returns the byte position in input string (from start).
 func (t *Token) Offset() int {
 	return t.offset
 }
 
+// Position returns the position of the token without the file name, see Stream.Position.
+func (t *Token) Position() Position {
+	return Position{Line: t.line, Column: t.column, Offset: t.offset}
+}
+
+// Position describes the position in the source.
+type Position struct {
+	// File is the file name of the source, see Tokenizer.ParseBytesNamed. Empty if the source isn't named.
+	File string
+	// Line number starts from 1.
+	Line int
+	// Column is the byte position in the line, starts from 1. Zero if unknown.
+	Column int
+	// Offset is the byte position in the source, starts from 0.
+	Offset int
+}
+
+// String returns the position in the form `file:line:column`.
+// The file is omitted if it's empty, the column is omitted if it's unknown.
+func (p Position) String() string {
+	s := strconv.Itoa(p.Line)
+	if p.Column > 0 {
+		s += ":" + strconv.Itoa(p.Column)
+	}
+	if p.File != "" {
+		s = p.File + ":" + s
+	}
+	return s
+}
+
 // StringSettings returns StringSettings structure if token is framed string.
 func (t *Token) StringSettings() *StringSettings {
 	return t.string
@@ -158,6 +425,60 @@ func (t *Token) StringKey() TokenKey {
 	return TokenString
 }
 
+// StringPrefix returns the literal prefix of the framed string, like `r` of r"…" (see StringSettings.SetPrefix).
+// Method returns nil if the token isn't a string or the string has no prefix.
+func (t *Token) StringPrefix() []byte {
+	if t.string != nil {
+		return t.string.Prefix
+	}
+	return nil
+}
+
+// IsChar checks if this token is char literal — the key is TokenChar.
+func (t *Token) IsChar() bool {
+	return t.key == TokenChar
+}
+
+// ValueRune returns the decoded rune of the char literal, see Tokenizer.DefineCharToken.
+// If the token is not TokenChar utf8.RuneError will be returned.
+func (t *Token) ValueRune() rune {
+	if t.key != TokenChar || t.string == nil {
+		return utf8.RuneError
+	}
+	v := t.value[len(t.string.StartToken) : len(t.value)-len(t.string.EndToken)]
+	if len(v) > 1 && v[0] == t.string.EscapeSymbol {
+		if len(v) > 2 {
+			r, err := strconv.ParseUint(b2s(v[2:]), 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return utf8.RuneError
+			}
+			return rune(r)
+		}
+		if s, ok := t.string.SpecSymbols[v[1]]; ok {
+			return rune(s)
+		}
+		return rune(v[1])
+	}
+	r, _ := utf8.DecodeRune(v)
+	return r
+}
+
+// RegexFlags returns flags of the regex literal, like `gi` of /a+/gi (see Tokenizer.DefineRegexToken).
+// Method returns nil if the token isn't a regex literal or it has no flags.
+func (t *Token) RegexFlags() []byte {
+	if t.string == nil || !t.string.Regex {
+		return nil
+	}
+	i := len(t.value)
+	for i > 0 && isLetterByte(t.value[i-1]) {
+		i--
+	}
+	if i == len(t.value) {
+		return nil
+	}
+	return t.value[i:]
+}
+
 // IsString checks if current token is a quoted string.
 // Token key may be TokenString or TokenStringFragment.
 func (t *Token) IsString() bool {
@@ -176,8 +497,17 @@ func (t *Token) IsString() bool {
 //
 //	one "two"		three
 //
+// The pattern of the regex literal is returned as is, without flags.
+// Quotes are kept if the string is defined with StringSettings.KeepQuotes.
+// The escape symbol followed by the symbol without the special meaning yields the symbol, like `\q` is `q`.
 // Method doesn't use cache. Each call starts a string parser.
 func (t *Token) ValueUnescaped() []byte {
+	if t.key == TokenChar {
+		return utf8.AppendRune(nil, t.ValueRune())
+	}
+	if t.string != nil && t.string.Regex {
+		return t.value[len(t.string.StartToken) : len(t.value)-len(t.RegexFlags())-len(t.string.EndToken)]
+	}
 	if t.string != nil {
 		from := 0
 		to := len(t.value)
@@ -188,29 +518,59 @@ func (t *Token) ValueUnescaped() []byte {
 			to = len(t.value) - len(t.string.EndToken)
 		}
 		str := t.value[from:to]
-		result := make([]byte, 0, len(str))
-		escaping := false
+		end := t.string.EndToken
+		var result []byte
 		start := 0
 		for i := 0; i < len(str); i++ {
-			if escaping {
+			if t.string.EscapeSymbol != 0 && str[i] == t.string.EscapeSymbol && i+1 < len(str) {
+				result = append(result, str[start:i]...)
+				i++
 				if v, ok := t.string.SpecSymbols[str[i]]; ok {
-					result = append(result, t.value[start:i]...)
 					result = append(result, v)
+				} else {
+					result = append(result, str[i])
 				}
-				start = i
-				escaping = false
-			} else if t.string.EscapeSymbol != 0 && str[i] == t.string.EscapeSymbol {
-				escaping = true
+				start = i + 1
+			} else if t.string.DoubledEscape && len(end) > 0 && bytesStarts(end, str[i:]) && bytesStarts(end, str[i+len(end):]) {
+				result = append(result, str[start:i+len(end)]...)
+				i += 2*len(end) - 1
+				start = i + 1
+			} else if n := t.string.doubledInjection(str[i:]); n > 0 {
+				result = append(result, str[start:i+n]...)
+				i += 2*n - 1
+				start = i + 1
 			}
 		}
-		if start == 0 { // no one escapes
+		if result == nil { // no one escapes
+			if t.string.Quoted {
+				return t.value
+			}
 			return str
 		}
+		result = append(result, str[start:]...)
+		if t.string.Quoted {
+			result = append(append(append([]byte(nil), t.value[:from]...), result...), t.value[to:]...)
+		}
 		return result
 	}
 	return t.value
 }
 
+// ValueFolded returns the value of the keyword in lower case recorded during parsing (see Tokenizer.FoldKeywords),
+// so identifiers of case-insensitive languages, like SQL or Pascal, are compared without allocations.
+// The value as is returned if it's in lower case already or folding of keywords isn't enabled.
+func (t *Token) ValueFolded() []byte {
+	if t.folded != nil {
+		return t.folded
+	}
+	return t.value
+}
+
+// ValueFoldedString like as ValueFolded but returns string.
+func (t *Token) ValueFoldedString() string {
+	return b2s(t.ValueFolded())
+}
+
 // ValueUnescapedString like as ValueUnescaped but returns string.
 func (t *Token) ValueUnescapedString() string {
 	if s := t.ValueUnescaped(); s != nil {
@@ -219,6 +579,16 @@ func (t *Token) ValueUnescapedString() string {
 	return ""
 }
 
+// hasAnyKey checks if the token or the string of the token has any of these keys.
+func (t *Token) hasAnyKey(keys []TokenKey) bool {
+	for _, key := range keys {
+		if t.key == key || (t.string != nil && t.string.Key == key) {
+			return true
+		}
+	}
+	return false
+}
+
 // Is checks if the token has any of these keys.
 func (t *Token) Is(key TokenKey, keys ...TokenKey) bool {
 	if t.key == key {
@@ -1,9 +1,15 @@
 package tokenizer
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 const newLine = '\n'
@@ -12,6 +18,9 @@ const newLine = '\n'
 type TokenKey int
 
 const (
+	// TokenChar means that this token is char literal, see DefineCharToken.
+	// For example, 'a', '\n'
+	TokenChar TokenKey = -7
 	// TokenUnknown means that this token not embedded token and not user defined.
 	TokenUnknown TokenKey = -6
 	// TokenStringFragment means that this is only fragment of quoted string with injections
@@ -39,6 +48,14 @@ const (
 	fAllowKeywordUnderscore uint16 = 0b10
 	fAllowNumberUnderscore  uint16 = 0b100
 	fAllowNumberInKeyword   uint16 = 0b1000
+	fCollectStats           uint16 = 0b10000
+	fCopyValues             uint16 = 0b100000
+	fProfileLabels          uint16 = 0b1000000
+	fNormalizeNFC           uint16 = 0b10000000
+	fFoldKeywords           uint16 = 0b100000000
+	fSignedNumbers          uint16 = 0b1000000000
+	fLeadingDotFloat        uint16 = 0b10000000000
+	fInfNaN                 uint16 = 0b100000000000
 )
 
 // BackSlash just backslash byte
@@ -62,14 +79,79 @@ type tokenRef struct {
 	Token []byte
 	// Require that token must be surrounded by whitespaces
 	IsFull bool
+	// Priority of the token, see SetPriority
+	Priority int
+	// Fold means that the token is matched case-insensitively, see TokenSettings.CaseInsensitive
+	Fold bool
+	// Word means that the token isn't matched as the part of a word, see TokenSettings.WholeWord
+	Word bool
+	// Mode is the name of the mode of the token, see TokenSettings.Mode
+	Mode string
+}
+
+// heads returns first bytes of the token in the index, both cases of the ASCII letter for case-insensitive tokens.
+func (ref *tokenRef) heads() []byte {
+	head := ref.Token[0]
+	if ref.Fold && head < utf8.RuneSelf && unicode.IsLetter(rune(head)) {
+		if other := byte(unicode.SimpleFold(rune(head))); other != head {
+			return []byte{head, other}
+		}
+	}
+	return ref.Token[:1]
+}
+
+// TokenSettings configures user defined tokens of the key, see Tokenizer.DefineTokens.
+// The settings embed the tokenizer, so the definition of the grammar may be chained:
+//
+//	t.DefineTokens(TokenAnd, []string{"and"}).CaseInsensitive().WholeWord().
+//		DefineTokens(TokenOr, []string{"or"}).CaseInsensitive()
+type TokenSettings struct {
+	*Tokenizer
+	// Key is the key of tokens.
+	Key TokenKey
+}
+
+// CaseInsensitive matches tokens ignoring the case of letters, like `AND` and `And` for the `and` token in SQL.
+// The value of the token is the input as is, not the defined literal.
+func (s *TokenSettings) CaseInsensitive() *TokenSettings {
+	s.configure(s.Key, func(ref *tokenRef) {
+		ref.Fold = true
+	})
+	return s
+}
+
+// WholeWord requires that the token isn't followed by letters, digits or the underscore,
+// so `in` isn't matched in `index`, the keyword is parsed instead. See also DefineFullTokens.
+func (s *TokenSettings) WholeWord() *TokenSettings {
+	s.configure(s.Key, func(ref *tokenRef) {
+		ref.Word = true
+	})
+	return s
+}
+
+// Priority sets the priority of tokens, see Tokenizer.SetPriority.
+func (s *TokenSettings) Priority(priority int) *TokenSettings {
+	s.SetPriority(s.Key, priority)
+	return s
+}
+
+// Mode adds tokens to the named mode: tokens are matched only if the mode is enabled, see Tokenizer.EnableModes.
+// So feature-flagged operators or dialect-specific tokens are defined in one grammar. The empty name means no mode.
+func (s *TokenSettings) Mode(name string) *TokenSettings {
+	s.configure(s.Key, func(ref *tokenRef) {
+		ref.Mode = name
+	})
+	return s
 }
 
 // QuoteInjectSettings describes open injection token and close injection token.
 type QuoteInjectSettings struct {
 	// Token type witch opens quoted string.
-	StartKey TokenKey
+	StartKey TokenKey `json:"start" yaml:"start"`
 	// Token type witch closes quoted string.
-	EndKey TokenKey
+	EndKey TokenKey `json:"end" yaml:"end"`
+	// Tokenizer parses the injected region if it's not nil, see AddInjectionWith.
+	Tokenizer *Tokenizer `json:"-" yaml:"-"`
 }
 
 // StringSettings describes framed(quoted) string tokens like quoted strings.
@@ -80,16 +162,51 @@ type StringSettings struct {
 	EscapeSymbol byte
 	SpecSymbols  map[byte]byte
 	Injects      []QuoteInjectSettings
+	// DoubledEscape allows the end token escaped by doubling, see EscapeByDoubling.
+	DoubledEscape bool
+	// Char means that the string is char literal (see Tokenizer.DefineCharToken).
+	Char bool
+	// Regex means that the string is regex literal (see Tokenizer.DefineRegexToken).
+	Regex bool
+	// Operands are keys of tokens after which the regex literal isn't expected, like `)`.
+	Operands []TokenKey
+	// Prefix is the literal prefix of the start token, see SetPrefix.
+	Prefix []byte
+	// DoubledInjection allows the start token of injections escaped by doubling, see EscapeInjectionByDoubling.
+	DoubledInjection bool
+	// SingleLine means that the string can't contain new lines, see AllowNewlines.
+	SingleLine bool
+	// Quoted means that the unescaped value keeps the start and end tokens, see KeepQuotes.
+	Quoted bool
+	// sequence number of the string definition
+	id int
+	// tokenizer of the string definition, it resolves literals of injections
+	t *Tokenizer
+}
+
+// ID returns the sequence number of the string definition in the tokenizer, starting from zero.
+func (q *StringSettings) ID() int {
+	return q.id
 }
 
 // AddInjection configure injection in to string.
 // Injection - parsable fragment of framed(quoted) string.
 // Often used for parsing of placeholders or template's expressions in the framed string.
+// If endTokenKey is TokenUndef the injection is the only one token after the start token, like `$var` in shell strings.
 func (q *StringSettings) AddInjection(startTokenKey, endTokenKey TokenKey) *StringSettings {
 	q.Injects = append(q.Injects, QuoteInjectSettings{StartKey: startTokenKey, EndKey: endTokenKey})
 	return q
 }
 
+// AddInjectionWith configure injection in to string like AddInjection, but the injected region is parsed
+// by the tokenizer `t`, so template expressions may have their own keywords and operators distinct from
+// the grammar of the document. The start token belongs to the grammar of the document and the end token
+// should be defined in both tokenizers with the same key.
+func (q *StringSettings) AddInjectionWith(startTokenKey, endTokenKey TokenKey, t *Tokenizer) *StringSettings {
+	q.Injects = append(q.Injects, QuoteInjectSettings{StartKey: startTokenKey, EndKey: endTokenKey, Tokenizer: t})
+	return q
+}
+
 // SetEscapeSymbol set escape symbol for framed(quoted) string.
 // Escape symbol allows ignoring close token of framed string.
 // Also escape symbol allows using special symbols in the frame strings, like \n, \t.
@@ -98,6 +215,67 @@ func (q *StringSettings) SetEscapeSymbol(symbol byte) *StringSettings {
 	return q
 }
 
+// EscapeByDoubling allows the end token inside the framed string escaped by doubling it, like in SQL and CSV:
+//
+//	'it''s'
+//	"say ""hi"""
+func (q *StringSettings) EscapeByDoubling() *StringSettings {
+	q.DoubledEscape = true
+	return q
+}
+
+// SetPrefix sets the literal prefix attached to the start token, like `r` of raw strings r"…" in Python
+// or `E` of E'…' in PostgreSQL. The string starts with the prefix followed by the start token,
+// so each prefix is defined as the separate string with own escape and injection settings:
+//
+//	t.DefineStringToken(TokenQuotedString, `"`, `"`).SetEscapeSymbol(tokenizer.BackSlash)
+//	t.DefineStringToken(TokenQuotedString, `"`, `"`).SetPrefix("r")
+//
+// The prefixed string takes precedence over the keyword, the prefix is available via Token.StringPrefix.
+func (q *StringSettings) SetPrefix(prefix string) *StringSettings {
+	q.StartToken = append([]byte(prefix), q.StartToken[len(q.Prefix):]...)
+	q.Prefix = q.StartToken[:len(prefix)]
+	return q
+}
+
+// EscapeInjectionByDoubling allows the start token of injections inside the framed string escaped by doubling it,
+// like "{{{{" for literal "{{" in templates. The escape symbol also prevents opening of the injection, like "\{{".
+// Token.ValueUnescaped returns the single start token in both cases.
+func (q *StringSettings) EscapeInjectionByDoubling() *StringSettings {
+	q.DoubledInjection = true
+	return q
+}
+
+// doubledInjection returns the length of the start token of injection repeated twice at the beginning of `str`.
+func (q *StringSettings) doubledInjection(str []byte) int {
+	if !q.DoubledInjection || q.t == nil {
+		return 0
+	}
+	for _, inject := range q.Injects {
+		for _, token := range q.t.tokens[inject.StartKey] {
+			if bytesStarts(token.Token, str) && bytesStarts(token.Token, str[len(token.Token):]) {
+				return len(token.Token)
+			}
+		}
+	}
+	return 0
+}
+
+// AllowNewlines allows or forbids new lines in the framed string, new lines are allowed by default.
+// The string with forbidden new lines ends before the unescaped new line as the unclosed string,
+// like string literals in Go or C. The escaped new line is the part of the string.
+func (q *StringSettings) AllowNewlines(allow bool) *StringSettings {
+	q.SingleLine = !allow
+	return q
+}
+
+// KeepQuotes keeps the start and end tokens (quotes) in the value returned by Token.ValueUnescaped,
+// by default the unescaped value is the content of the string without them.
+func (q *StringSettings) KeepQuotes(keep bool) *StringSettings {
+	q.Quoted = keep
+	return q
+}
+
 // SetSpecialSymbols set mapping of all escapable symbols for escape symbol, like \n, \t, \r.
 func (q *StringSettings) SetSpecialSymbols(special map[byte]byte) *StringSettings {
 	q.SpecSymbols = special
@@ -113,11 +291,47 @@ type Tokenizer struct {
 	index   map[byte][]*tokenRef
 	quotes  []*StringSettings
 	wSpaces []byte
-	pool    sync.Pool
+	trivia  []TokenKey
+	// definitions with empty literals and invalid options, they are ignored and reported by Compile
+	invalid []invalidDefinition
+	// names of token keys for debug dumps and errors
+	names map[TokenKey]string
+	// display strings of token keys for user-facing errors
+	descriptions map[TokenKey]string
+	// receiver of events, see SetLogger
+	logger   Logger
+	logLevel LogLevel
+	limits   limits
+	// enabled modes of tokens, see EnableModes
+	modes map[string]bool
+	// keys of tokens after which the sign isn't absorbed into the number, see AllowSignedNumbers
+	signOperands []TokenKey
+	// policy of numbers with the trailing dot, see SetTrailingDot
+	trailingDot TrailingDot
+	// spellings of infinity and not-a-number floats, the longest first, see AllowInfNaN
+	infNaN [][]byte
+	// unit suffixes of numbers in definition order, see DefineUnits
+	units []*UnitSettings
+	// separator of groups of digits in numbers, see SetThousandsSeparator
+	thousands byte
+	// date and time literals in definition order, see DefineDateTimeToken
+	dateTimes []*DateTimeSettings
+	// key of duration literals, see DefineDurationToken
+	duration TokenKey
+	// key of UUIDs, see DefineUUIDToken
+	uuid TokenKey
+	// keys of IP addresses and CIDR ranges, see DefineIPTokens
+	ip, cidr TokenKey
+	// callbacks of emitted tokens, see OnToken
+	hooks []tokenHook
+	pool  sync.Pool
+	// released parsers of bytes slices and streams, see ParseBytesPooled
+	parsers sync.Pool
+	streams sync.Pool
 }
 
-// New creates new tokenizer.
-func New() *Tokenizer {
+// New creates new tokenizer configured by the options in order, like New(WithStopOnUnknown(), WithMaxTokenLength(4096)).
+func New(opts ...Option) *Tokenizer {
 	t := Tokenizer{
 		flags:   0,
 		tokens:  map[TokenKey][]*tokenRef{},
@@ -125,8 +339,8 @@ func New() *Tokenizer {
 		quotes:  []*StringSettings{},
 		wSpaces: defaultWhiteSpaces,
 	}
-	t.pool.New = func() interface{} {
-		return new(Token)
+	for _, opt := range opts {
+		opt(&t)
 	}
 	return &t
 }
@@ -158,63 +372,323 @@ func (t *Tokenizer) AllowNumbersInKeyword() *Tokenizer {
 	return t
 }
 
-// DefineTokens add custom token.
-// There `key` unique is identifier of `tokens`, `tokens` — slice of string of tokens.
-// If key already exists tokens will be rewritten.
-func (t *Tokenizer) DefineFullTokens(key TokenKey, tokens []string) *Tokenizer {
-	var tks []*tokenRef
-	if key < 1 {
-		return t
+// AllowLeadingDotFloat allows floats without the integer part, like `.5` or `.5e3`, as in CSS and many DSLs.
+// The float takes precedence over the user defined `.` token if the digit follows the dot, so `x.0` is the keyword
+// followed by the float.
+func (t *Tokenizer) AllowLeadingDotFloat() *Tokenizer {
+	t.flags |= fLeadingDotFloat
+	return t
+}
+
+// AllowSignedNumbers absorbs the unary sign `-` or `+` into the number, like `-5` or `+3.2`, if the sign is followed
+// by the digit and the previous token isn't an operand: the number is at the start of data or after an operator,
+// like `x = -5` or `(-5`. After keywords, numbers, strings, chars and tokens of `operandKeys` (like `)` or `]`)
+// the sign is the binary operator, so `x-5` and `(x) -5` are not changed. Token.ValueInt and Token.ValueFloat
+// take the sign into account. Operand keys are added to keys of previous calls.
+func (t *Tokenizer) AllowSignedNumbers(operandKeys ...TokenKey) *Tokenizer {
+	t.flags |= fSignedNumbers
+	t.signOperands = append(t.signOperands, operandKeys...)
+	return t
+}
+
+// DefaultInfNaN are spellings of infinity and not-a-number floats of AllowInfNaN by default.
+var DefaultInfNaN = []string{"Inf", "+Inf", "-Inf", "NaN"}
+
+// AllowInfNaN parses infinity and not-a-number literals as TokenFloat instead of keywords, like `Inf` or `NaN`.
+// Spellings are case-sensitive words, DefaultInfNaN are used if spellings are empty. Any case of `inf`, `infinity`
+// and `nan` with the optional sign and the leading dot (like YAML `.inf`) is allowed, others are reported by Compile.
+// The signed spelling like `-Inf` follows the rules of AllowSignedNumbers: the sign after the operand is the binary
+// operator. Token.ValueFloat returns the infinity or NaN. Spellings are added to spellings of previous calls.
+func (t *Tokenizer) AllowInfNaN(spellings ...string) *Tokenizer {
+	if len(spellings) == 0 {
+		spellings = DefaultInfNaN
 	}
-	for _, token := range tokens {
-		ref := tokenRef{
-			Key:    key,
-			Token:  s2b(token),
-			IsFull: true,
+	t.flags |= fInfNaN
+	for _, spelling := range spellings {
+		if _, ok := infNaN([]byte(spelling)); !ok {
+			t.invalid = append(t.invalid, invalidDefinition{key: TokenUndef, what: fmt.Sprintf("spelling %q of AllowInfNaN is neither infinity nor NaN", spelling)})
+			continue
+		}
+		found := false
+		for _, s := range t.infNaN {
+			found = found || string(s) == spelling
 		}
-		head := ref.Token[0]
-		tks = append(tks, &ref)
-		if t.index[head] == nil {
-			t.index[head] = []*tokenRef{}
+		if !found {
+			t.infNaN = append(t.infNaN, []byte(spelling))
 		}
-		t.index[head] = append(t.index[head], &ref)
-		sort.Slice(t.index[head], func(i, j int) bool {
-			return len(t.index[head][i].Token) > len(t.index[head][j].Token)
-		})
 	}
-	t.tokens[key] = tks
+	sort.SliceStable(t.infNaN, func(i, j int) bool {
+		return len(t.infNaN[i]) > len(t.infNaN[j])
+	})
+	return t
+}
+
+// SetThousandsSeparator allows the separator of thousands in numbers, like `,` in `1,000,000` or the space
+// in `1 000 000`. The separator is the part of the number only if groups are valid: the first group has 1-3 digits
+// and each separator is followed by exactly 3 digits, so `1,00` and `1234,567` are numbers and unknown tokens.
+// Only the integer part of floats is grouped, like `1,234.5`. Token.ValueInt and Token.ValueFloat skip separators.
+// The separator must not be the letter, the digit, the dot, the sign or the new line. Zero disables separators.
+func (t *Tokenizer) SetThousandsSeparator(sep byte) *Tokenizer {
+	if !validThousands(sep) {
+		t.invalid = append(t.invalid, invalidDefinition{key: TokenUndef, what: fmt.Sprintf("thousands separator %q is the letter, the digit, the dot, the sign or the new line", sep)})
+		return t
+	}
+	t.thousands = sep
+	return t
+}
+
+// validThousands checks if `sep` may separate thousands, see SetThousandsSeparator.
+func validThousands(sep byte) bool {
+	return sep == 0 || !isNumberByte(sep) && sep != '.' && sep != '-' && sep != '+' && sep != newLine &&
+		(sep >= utf8.RuneSelf || !unicode.IsLetter(rune(sep)))
+}
+
+// TrailingDot is the policy of numbers with the trailing dot, like `2.`, see Tokenizer.SetTrailingDot.
+type TrailingDot uint8
+
+const (
+	// TrailingDotFloat parses `2.` as the float, it's the default policy.
+	TrailingDotFloat TrailingDot = iota
+	// TrailingDotInteger parses `2.` as the integer followed by the dot, for grammars where the dot
+	// is the member access or the range operator, like `2.abs()` or `1..5`.
+	TrailingDotInteger
+	// TrailingDotReject stops parsing with TrailingDotError on `2.`.
+	TrailingDotReject
+)
+
+func (d TrailingDot) String() string {
+	switch d {
+	case TrailingDotFloat:
+		return "float"
+	case TrailingDotInteger:
+		return "integer"
+	case TrailingDotReject:
+		return "reject"
+	}
+	return fmt.Sprintf("trailing_dot(%d)", uint8(d))
+}
+
+// SetTrailingDot sets the policy of numbers with the trailing dot not followed by the digit, like `2.` or `1..5`.
+// By default such number is the float (TrailingDotFloat).
+func (t *Tokenizer) SetTrailingDot(policy TrailingDot) *Tokenizer {
+	t.trailingDot = policy
+	return t
+}
 
+// SetKeyName sets the name of the token key, like `COMPARE_OP`.
+// Names are used instead of numbers in the dump of the stream (see Stream.String) and in error messages.
+func (t *Tokenizer) SetKeyName(key TokenKey, name string) *Tokenizer {
+	if t.names == nil {
+		t.names = map[TokenKey]string{}
+	}
+	t.names[key] = name
 	return t
 }
 
+// KeyName returns the name of the token key (see SetKeyName) or the number of the key if the name isn't set.
+func (t *Tokenizer) KeyName(key TokenKey) string {
+	if name, ok := t.names[key]; ok {
+		return name
+	}
+	return strconv.Itoa(int(key))
+}
+
+// SetKeyDescription sets the display string of the token key for user-facing error messages,
+// like "a comparison operator" or "a quoted string".
+// If any of the expected keys has a description, UnexpectedTokenError produces the message
+// like `expected a comparison operator, found "curl" at 2:15`.
+func (t *Tokenizer) SetKeyDescription(key TokenKey, description string) *Tokenizer {
+	if t.descriptions == nil {
+		t.descriptions = map[TokenKey]string{}
+	}
+	t.descriptions[key] = description
+	return t
+}
+
+// KeyDescription returns the display string of the token key (see SetKeyDescription)
+// or the name of the key (see KeyName) if the description isn't set.
+func (t *Tokenizer) KeyDescription(key TokenKey) string {
+	if description, ok := t.descriptions[key]; ok {
+		return description
+	}
+	return t.KeyName(key)
+}
+
+// DefineTrivia marks the tokens with these keys as trivia, like comments.
+// String tokens are matched by the string key, so comments may be defined via DefineStringToken.
+// Trivia tokens are not added to the stream, they are attached to the next token as leading trivia (see Token.Trivia).
+// Use it for comments without injections.
+func (t *Tokenizer) DefineTrivia(keys ...TokenKey) *Tokenizer {
+	t.trivia = append(t.trivia, keys...)
+	return t
+}
+
+// DefineFullTokens add custom tokens like DefineTokens, but tokens must be surrounded by whitespaces.
+// If key already exists tokens will be rewritten.
+func (t *Tokenizer) DefineFullTokens(key TokenKey, tokens []string) *TokenSettings {
+	t.defineTokens(key, tokens, true)
+	return &TokenSettings{Tokenizer: t, Key: key}
+}
+
 // DefineTokens add custom token.
 // There `key` unique is identifier of `tokens`, `tokens` — slice of string of tokens.
 // If key already exists tokens will be rewritten.
-func (t *Tokenizer) DefineTokens(key TokenKey, tokens []string) *Tokenizer {
-	var tks []*tokenRef
+//
+// Tokens are matched by the longest match regardless of the order of definitions: `>=` always wins over `>`.
+// Tokens of the same length are matched in the order of definitions, so if the same literal is defined
+// for several keys the first definition wins.
+// The returned settings configure the tokens, like TokenSettings.CaseInsensitive.
+func (t *Tokenizer) DefineTokens(key TokenKey, tokens []string) *TokenSettings {
+	t.defineTokens(key, tokens, false)
+	return &TokenSettings{Tokenizer: t, Key: key}
+}
+
+// invalidLiteral describes tokens with the empty literal, see Compile.
+const invalidLiteral = "tokens have the empty literal"
+
+// defineTokens replaces tokens of the key and keeps the index ordered by the length of tokens.
+func (t *Tokenizer) defineTokens(key TokenKey, tokens []string, full bool) {
 	if key < 1 {
-		return t
+		return
 	}
+	t.unindex(key)
+	var tks []*tokenRef
 	for _, token := range tokens {
-		ref := tokenRef{
-			Key:   key,
-			Token: s2b(token),
+		if token == "" {
+			t.invalid = append(t.invalid, invalidDefinition{key: key, what: invalidLiteral})
+			continue
 		}
-		head := ref.Token[0]
-		tks = append(tks, &ref)
-		if t.index[head] == nil {
-			t.index[head] = []*tokenRef{}
+		ref := &tokenRef{
+			Key:    key,
+			Token:  s2b(token),
+			IsFull: full,
 		}
-		t.index[head] = append(t.index[head], &ref)
-		sort.Slice(t.index[head], func(i, j int) bool {
-			return len(t.index[head][i].Token) > len(t.index[head][j].Token)
-		})
+		tks = append(tks, ref)
+		t.indexToken(ref)
 	}
 	t.tokens[key] = tks
+}
+
+// indexToken adds the token to the index by its first bytes.
+func (t *Tokenizer) indexToken(ref *tokenRef) {
+	for _, head := range ref.heads() {
+		t.index[head] = append(t.index[head], ref)
+		t.sortIndex(head)
+	}
+}
+
+// configure changes settings of tokens of the key. Tokens keep their places in the index,
+// so ties are still matched in the order of definitions, new heads of case-insensitive tokens are added.
+func (t *Tokenizer) configure(key TokenKey, fn func(ref *tokenRef)) {
+	for _, ref := range t.tokens[key] {
+		heads := ref.heads()
+		fn(ref)
+		for _, head := range ref.heads() {
+			if bytes.IndexByte(heads, head) < 0 {
+				t.index[head] = append(t.index[head], ref)
+			}
+			t.sortIndex(head)
+		}
+	}
+}
+
+// copySettings configures tokens of the key like `proto`, except the literal.
+func (t *Tokenizer) copySettings(key TokenKey, proto *tokenRef) {
+	t.configure(key, func(ref *tokenRef) {
+		ref.IsFull, ref.Priority, ref.Fold, ref.Word, ref.Mode = proto.IsFull, proto.Priority, proto.Fold, proto.Word, proto.Mode
+	})
+}
+
+// EnableModes enables tokens of the named modes, see TokenSettings.Mode. Tokens without the mode are always enabled.
+func (t *Tokenizer) EnableModes(names ...string) *Tokenizer {
+	if t.modes == nil {
+		t.modes = map[string]bool{}
+	}
+	for _, name := range names {
+		t.modes[name] = true
+	}
+	return t
+}
+
+// sortIndex orders tokens with the same first byte by priority, then by length. Ties keep the order of definitions.
+func (t *Tokenizer) sortIndex(head byte) {
+	refs := t.index[head]
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Priority != refs[j].Priority {
+			return refs[i].Priority > refs[j].Priority
+		}
+		return len(refs[i].Token) > len(refs[j].Token)
+	})
+}
+
+// SetPriority sets the priority of tokens of the key, where the longest match is not enough.
+// Tokens with higher priority are matched first regardless of the length, like `-` over `->` with lower priority.
+// Keywords have zero priority: user defined tokens with zero priority win over keywords (`in` splits `int` into `in` and `t`),
+// while tokens with negative priority yield to the longer keyword, so `in` is the token only if it's not a part of a word.
+// The priority is reset by the redefinition of tokens, all tokens have zero priority by default.
+func (t *Tokenizer) SetPriority(key TokenKey, priority int) *Tokenizer {
+	for _, ref := range t.tokens[key] {
+		ref.Priority = priority
+		for _, head := range ref.heads() {
+			t.sortIndex(head)
+		}
+	}
+	return t
+}
 
+// UndefineTokens removes user defined tokens of the keys, like feature-flagged operators.
+// Framed strings, names and descriptions of keys are kept.
+// The tokenizer isn't safe for changes during parsing, so long-lived applications should swap
+// compiled tokenizers (see Compile) instead of changing the shared one.
+func (t *Tokenizer) UndefineTokens(keys ...TokenKey) *Tokenizer {
+	for _, key := range keys {
+		t.unindex(key)
+		delete(t.tokens, key)
+		invalid := t.invalid[:0]
+		for _, def := range t.invalid {
+			if def.key != key || def.what != invalidLiteral {
+				invalid = append(invalid, def)
+			}
+		}
+		t.invalid = invalid
+	}
 	return t
 }
 
+// RedefineTokens replaces tokens of the key like DefineTokens, but keeps settings of the existing definition:
+// tokens stay full (see DefineFullTokens), keep the priority (see SetPriority) and settings of TokenSettings.
+func (t *Tokenizer) RedefineTokens(key TokenKey, tokens []string) *Tokenizer {
+	var proto *tokenRef
+	if refs := t.tokens[key]; len(refs) > 0 {
+		proto = refs[0]
+	}
+	t.UndefineTokens(key)
+	t.defineTokens(key, tokens, false)
+	if proto != nil {
+		t.copySettings(key, proto)
+	}
+	return t
+}
+
+// unindex removes tokens of the key from the index.
+func (t *Tokenizer) unindex(key TokenKey) {
+	for _, ref := range t.tokens[key] {
+		for _, head := range ref.heads() {
+			refs := t.index[head][:0]
+			for _, r := range t.index[head] {
+				if r != ref {
+					refs = append(refs, r)
+				}
+			}
+			if len(refs) == 0 {
+				delete(t.index, head)
+			} else {
+				t.index[head] = refs
+			}
+		}
+	}
+}
+
 // DefineStringToken defines a token string.
 // For example, a piece of data surrounded by quotes: "string in quotes" or 'string on sigle quotes'.
 // Arguments startToken and endToken defines open and close "quotes".
@@ -227,17 +701,58 @@ func (t *Tokenizer) DefineStringToken(key TokenKey, startToken, endToken string)
 		Key:        key,
 		StartToken: s2b(startToken),
 		EndToken:   s2b(endToken),
+		t:          t,
+	}
+	if len(q.StartToken) == 0 {
+		t.invalid = append(t.invalid, invalidDefinition{key: key, what: "string has the empty start"})
+		return q
 	}
-	if q.StartToken == nil {
+	if len(q.EndToken) == 0 {
+		t.invalid = append(t.invalid, invalidDefinition{key: key, what: "string has the empty end"})
 		return q
 	}
+	q.id = len(t.quotes)
 	t.quotes = append(t.quotes, q)
 
 	return q
 }
 
+// DefineCharToken defines char literals framed by `quote`, like 'a', '\n' or '\u00e9' in Go, C or Rust.
+// The literal contains exactly one UTF-8 rune or one escape sequence: the special symbol (DefaultStringEscapes by default),
+// \xHH, \uHHHH or \UHHHHHHHH. Char literals produce TokenChar tokens with the decoded rune (see Token.ValueRune).
+// Char literals are tried before framed strings, so a string with the same quote is parsed only if the char literal
+// doesn't match, like 'abc' in Python.
+func (t *Tokenizer) DefineCharToken(key TokenKey, quote string) *StringSettings {
+	// the copy, so changes of symbols of the literal don't affect other definitions
+	special := make(map[byte]byte, len(DefaultStringEscapes))
+	for k, v := range DefaultStringEscapes {
+		special[k] = v
+	}
+	q := t.DefineStringToken(key, quote, quote).
+		SetEscapeSymbol(BackSlash).
+		SetSpecialSymbols(special)
+	q.Char = true
+	return q
+}
+
+// DefineRegexToken defines regex literals /pattern/flags, like in JavaScript or awk.
+// The slash is ambiguous with division, so the regex literal is recognized only in the expression position:
+// at the start of data or after a token which isn't an operand. Keywords, numbers, strings, chars and tokens
+// with `operandKeys` (like `)` and `]`) are operands, so `a / b / c` is division while `x = /a+/g` is regex.
+// The pattern can't contain new lines, `/` inside character classes `[…]` and escaped `\/` don't end the pattern.
+// Regex literals produce TokenString tokens, see Token.ValueUnescaped and Token.RegexFlags.
+func (t *Tokenizer) DefineRegexToken(key TokenKey, operandKeys ...TokenKey) *StringSettings {
+	q := t.DefineStringToken(key, "/", "/").SetEscapeSymbol(BackSlash)
+	q.Regex = true
+	q.Operands = operandKeys
+	return q
+}
+
 func (t *Tokenizer) allocToken() *Token {
-	return t.pool.Get().(*Token)
+	if token, ok := t.pool.Get().(*Token); ok {
+		return token
+	}
+	return new(Token)
 }
 
 func (t *Tokenizer) freeToken(token *Token) {
@@ -247,12 +762,37 @@ func (t *Tokenizer) freeToken(token *Token) {
 	token.indent = nil
 	token.offset = 0
 	token.line = 0
+	token.column = 0
 	token.id = 0
 	token.key = 0
 	token.string = nil
+	for _, tr := range token.trivia {
+		t.freeToken(tr)
+	}
+	token.trivia = nil
+	token.data = nil
+	token.folded = nil
+	token.unit = 0
+	token.date = nil
 	t.pool.Put(token)
 }
 
+// CopyValues enables copying of values and indents of tokens during parsing: tokens own their bytes
+// and stay valid after the caller reuses or releases the input buffer or after the streaming window advances.
+// By default values are slices of the input (or of the data-chunk in the streaming mode), see Token.Copy.
+func (t *Tokenizer) CopyValues() *Tokenizer {
+	t.flags |= fCopyValues
+	return t
+}
+
+// FoldKeywords enables recording of values of keywords in lower case during parsing (see Token.ValueFolded),
+// so case-insensitive languages, like SQL or Pascal, compare identifiers without allocating lowercase copies.
+// Folded values are stored in blocks shared by tokens of the stream, values in lower case aren't copied.
+func (t *Tokenizer) FoldKeywords() *Tokenizer {
+	t.flags |= fFoldKeywords
+	return t
+}
+
 // ParseString parse the string into tokens
 func (t *Tokenizer) ParseString(str string) *Stream {
 	return t.ParseBytes(s2b(str))
@@ -261,14 +801,144 @@ func (t *Tokenizer) ParseString(str string) *Stream {
 // ParseBytes parse the bytes slice into tokens
 func (t *Tokenizer) ParseBytes(str []byte) *Stream {
 	p := newParser(t, str)
-	p.parse()
-	return NewStream(p)
+	p.parseTokens()
+	s := NewStream(p)
+	p.release()
+	return s
+}
+
+// ParseBytesCopy parse the copy of the bytes slice into tokens like ParseBytes: the input is copied at once up front,
+// so values and indents of tokens slice the copy and the stream is immune to mutation of `data` by the caller.
+// Unlike Tokenizer.CopyValues it costs one allocation per call instead of one per token and is selected per call.
+func (t *Tokenizer) ParseBytesCopy(data []byte) *Stream {
+	return t.ParseBytes(bytes.Clone(data))
+}
+
+// ParseBytesHint parse the bytes slice into tokens like ParseBytes, tokens for `expectedTokens` are allocated at once
+// instead of growing blocks, if the caller knows typical input shapes.
+func (t *Tokenizer) ParseBytesHint(data []byte, expectedTokens int) *Stream {
+	p := newParser(t, data)
+	p.reserve(expectedTokens)
+	p.parseTokens()
+	s := NewStream(p)
+	p.release()
+	return s
+}
+
+// ParseBytesPooled parse the bytes slice into tokens like ParseBytes, the stream is taken from the pool of the tokenizer.
+// Stream.Close returns the stream with its tokens to the pool, so services parsing many small inputs
+// don't churn the GC with the new stream per request. The stream and its tokens must not be used after Close.
+func (t *Tokenizer) ParseBytesPooled(data []byte) *Stream {
+	p := newParser(t, data)
+	p.parseTokens()
+	s, _ := t.streams.Get().(*Stream)
+	if s == nil {
+		s = &Stream{}
+	}
+	s.init(p)
+	s.pooled = true
+	p.release()
+	return s
 }
 
 // ParseStream parse the string into tokens.
 func (t *Tokenizer) ParseStream(r io.Reader, bufferSize uint) *Stream {
 	p := newInfParser(t, r, bufferSize)
 	p.preload()
-	p.parse()
+	p.parseTokens()
+	return NewInfStream(p)
+}
+
+// Count returns the count of tokens of the data without trivia tokens, like len of the stream of ParseBytes,
+// and the error which stopped parsing (see Stream.Err). Tokens are not collected: each token is released
+// to the pool as soon as the next one is emitted, so counting doesn't allocate the memory proportional to the data.
+func (t *Tokenizer) Count(data []byte) (int, error) {
+	p := newParser(t, data)
+	p.discard = true
+	p.parseTokens()
+	if p.ptr != nil {
+		t.freeToken(p.ptr)
+	}
+	for _, tr := range p.trivia {
+		t.freeToken(tr)
+	}
+	n, err := p.n, p.error()
+	p.release()
+	return n, err
+}
+
+// StreamOptions configures the streaming mode, see Tokenizer.ParseStreamWith.
+// Options trade the memory for the depth of lookbehind explicitly. Zero values mean defaults.
+type StreamOptions struct {
+	// ChunkSize is the size of data-chunks read from the reader, DefaultChunkSize by default.
+	ChunkSize uint
+	// HistorySize is the count of tokens kept before the current token (see Stream.SetHistorySize), unlimited by default.
+	HistorySize int
+	// MaxTokenSize limits the length of any single token like Tokenizer.SetMaxTokenLength for this stream,
+	// so the buffer isn't grown for unbounded tokens. The limit of the tokenizer is used by default.
+	MaxTokenSize int
+}
+
+// ParseStreamWith parse the data from the reader into tokens like ParseStream with options of the streaming mode.
+func (t *Tokenizer) ParseStreamWith(r io.Reader, opts StreamOptions) *Stream {
+	p := newInfParser(t, r, opts.ChunkSize)
+	if opts.MaxTokenSize > 0 {
+		p.tokenLength = opts.MaxTokenSize
+	}
+	p.preload()
+	p.parseTokens()
+	return NewInfStream(p).SetHistorySize(opts.HistorySize)
+}
+
+// ParseBytesCtx parse the bytes slice into tokens like ParseBytes, the context is checked periodically.
+// If the context is done parsing is aborted, tokens are released and the error of the context is returned,
+// so a server can bound how long a hostile or enormous input may occupy a goroutine.
+func (t *Tokenizer) ParseBytesCtx(ctx context.Context, data []byte) (*Stream, error) {
+	p := newParser(t, data)
+	p.ctx = ctx
+	p.parseTokens()
+	s := NewStream(p)
+	aborted := p.aborted()
+	err := p.stop
+	p.release()
+	if aborted {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseReaderCtx parse the data from the reader into tokens like ParseStream, the context is checked periodically
+// while data-chunks are parsed. If the context is done the stream ends and Stream.Err returns the error of the context.
+func (t *Tokenizer) ParseReaderCtx(ctx context.Context, r io.Reader, bufferSize uint) *Stream {
+	p := newInfParser(t, r, bufferSize)
+	p.ctx = ctx
+	if !p.aborted() {
+		p.preload()
+		p.parseTokens()
+	}
 	return NewInfStream(p)
 }
+
+// ParseBytesNamed parse the bytes slice of the file `name` into tokens.
+// The file name is attached to the stream and used in positions of tokens, errors (see Stream.Position) and log events.
+func (t *Tokenizer) ParseBytesNamed(name string, data []byte) *Stream {
+	p := newParser(t, data)
+	p.file = name
+	p.parseTokens()
+	s := NewStream(p)
+	p.release()
+	s.file = name
+	return s
+}
+
+// ParseStreamNamed parse the data of the file `name` into tokens, like ParseStream and ParseBytesNamed.
+func (t *Tokenizer) ParseStreamNamed(name string, r io.Reader, bufferSize uint) *Stream {
+	p := newInfParser(t, r, bufferSize)
+	p.file = name
+	p.preload()
+	p.parseTokens()
+	s := NewInfStream(p)
+	s.file = name
+	return s
+}
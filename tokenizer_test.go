@@ -1,7 +1,15 @@
 package tokenizer
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/require"
 )
@@ -86,29 +94,29 @@ func TestTokenizeEdgeCases(t *testing.T) {
 
 	data1 := []item{
 		{"one1", []Token{
-			{key: TokenKeyword, value: s2b("one"), offset: 0, line: 1, id: 0},
-			{key: TokenInteger, value: s2b("1"), offset: 3, line: 1, id: 1},
+			{key: TokenKeyword, value: s2b("one"), offset: 0, line: 1, column: 1, id: 0},
+			{key: TokenInteger, value: s2b("1"), offset: 3, line: 1, column: 4, id: 1},
 		}},
 		{"one_two", []Token{
-			{key: TokenKeyword, value: s2b("one"), offset: 0, line: 1, id: 0},
-			{key: TokenUnknown, value: s2b("_"), offset: 3, line: 1, id: 1},
-			{key: TokenKeyword, value: s2b("two"), offset: 4, line: 1, id: 2},
+			{key: TokenKeyword, value: s2b("one"), offset: 0, line: 1, column: 1, id: 0},
+			{key: TokenUnknown, value: s2b("_"), offset: 3, line: 1, column: 4, id: 1},
+			{key: TokenKeyword, value: s2b("two"), offset: 4, line: 1, column: 5, id: 2},
 		}},
 		{"one_1", []Token{
-			{key: TokenKeyword, value: s2b("one"), offset: 0, line: 1, id: 0},
-			{key: TokenUnknown, value: s2b("_"), offset: 3, line: 1, id: 1},
-			{key: TokenInteger, value: s2b("1"), offset: 4, line: 1, id: 2},
+			{key: TokenKeyword, value: s2b("one"), offset: 0, line: 1, column: 1, id: 0},
+			{key: TokenUnknown, value: s2b("_"), offset: 3, line: 1, column: 4, id: 1},
+			{key: TokenInteger, value: s2b("1"), offset: 4, line: 1, column: 5, id: 2},
 		}},
 	}
 	data2 := []item{
 		{"one1", []Token{
-			{key: TokenKeyword, value: s2b("one1"), offset: 0, line: 1, id: 0},
+			{key: TokenKeyword, value: s2b("one1"), offset: 0, line: 1, column: 1, id: 0},
 		}},
 		{"one_two", []Token{
-			{key: TokenKeyword, value: s2b("one_two"), offset: 0, line: 1, id: 0},
+			{key: TokenKeyword, value: s2b("one_two"), offset: 0, line: 1, column: 1, id: 0},
 		}},
 		{"one_1", []Token{
-			{key: TokenKeyword, value: s2b("one_1"), offset: 0, line: 1, id: 0},
+			{key: TokenKeyword, value: s2b("one_1"), offset: 0, line: 1, column: 1, id: 0},
 		}},
 	}
 
@@ -145,6 +153,7 @@ func TestTokenizeComplex(t *testing.T) {
 			key:    TokenKeyword,
 			value:  []byte("modified"),
 			offset: 0,
+			column: 1,
 			line:   1,
 		},
 		{
@@ -153,6 +162,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte(">"),
 			indent: []byte(" "),
 			offset: 9,
+			column: 10,
 			line:   1,
 		},
 		{
@@ -161,6 +171,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("\"2021-10-06 12:30:44\""),
 			indent: []byte("\t"),
 			offset: 11,
+			column: 12,
 			line:   1,
 			string: quote,
 		},
@@ -171,6 +182,7 @@ func TestTokenizeComplex(t *testing.T) {
 			indent: []byte(" "),
 			line:   1,
 			offset: 33,
+			column: 34,
 		},
 		{
 			id:     4,
@@ -178,6 +190,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("bytes_in"),
 			indent: []byte(" \n"),
 			offset: 38,
+			column: 1,
 			line:   2,
 		},
 		{
@@ -186,6 +199,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("<="),
 			indent: []byte(" "),
 			offset: 47,
+			column: 10,
 			line:   2,
 		},
 		{
@@ -194,6 +208,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("100"),
 			indent: []byte(" "),
 			offset: 50,
+			column: 13,
 			line:   2,
 		},
 		{
@@ -202,6 +217,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("or"),
 			indent: []byte(" "),
 			offset: 54,
+			column: 17,
 			line:   2,
 		},
 		{
@@ -210,6 +226,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("user_agent"),
 			indent: []byte(" "),
 			offset: 57,
+			column: 20,
 			line:   2,
 		},
 		{
@@ -218,6 +235,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("="),
 			indent: nil,
 			offset: 67,
+			column: 30,
 			line:   2,
 		},
 		{
@@ -226,6 +244,7 @@ func TestTokenizeComplex(t *testing.T) {
 			value:  []byte("'curl'"),
 			indent: nil,
 			offset: 68,
+			column: 31,
 			string: quote2,
 			line:   2,
 		},
@@ -253,6 +272,7 @@ func TestTokenizeInject(t *testing.T) {
 			key:    TokenStringFragment,
 			value:  []byte("\"one "),
 			offset: 0,
+			column: 1,
 			string: quote,
 			line:   1,
 		},
@@ -261,6 +281,7 @@ func TestTokenizeInject(t *testing.T) {
 			key:    startQuoteVarToken,
 			value:  []byte("{{"),
 			offset: 5,
+			column: 6,
 			indent: nil,
 			line:   1,
 		},
@@ -269,6 +290,7 @@ func TestTokenizeInject(t *testing.T) {
 			key:    TokenKeyword,
 			value:  []byte("two"),
 			offset: 8,
+			column: 9,
 			indent: []byte(" "),
 			line:   1,
 		},
@@ -277,6 +299,7 @@ func TestTokenizeInject(t *testing.T) {
 			key:    endQuoteVarToken,
 			value:  []byte("}}"),
 			offset: 12,
+			column: 13,
 			indent: []byte(" "),
 			line:   1,
 		},
@@ -285,9 +308,1021 @@ func TestTokenizeInject(t *testing.T) {
 			key:    TokenStringFragment,
 			value:  []byte(" three\""),
 			offset: 14,
+			column: 15,
 			indent: nil,
 			string: quote,
 			line:   1,
 		},
 	}, stream.GetSnippet(10, 10), "parsed %s as %s", str, stream)
+
+	stream = tokenizer.ParseString(`"{{two}}" three`)
+	require.Equal(t, "\"", stream.GoTo(4).CurrentToken().ValueString())
+	require.Equal(t, "three", stream.GoNext().CurrentToken().ValueString())
+}
+
+func TestTokenizeInjectWith(t *testing.T) {
+	expr := New()
+	expr.DefineTokens(TokenKey(11), []string{"}}"})
+	expr.DefineTokens(TokenKey(20), []string{"|"})
+	expr.DefineTokens(TokenKey(21), []string{"upper"})
+	expr.SetWhiteSpaces([]byte(" "))
+
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).AddInjectionWith(TokenKey(10), TokenKey(11), expr)
+
+	stream := tokenizer.ParseString(`upper | "a {{ name | upper }} b"`)
+	defer stream.Close()
+	var keys []TokenKey
+	for ; stream.IsValid(); stream.GoNext() {
+		keys = append(keys, stream.CurrentToken().Key())
+	}
+	require.Equal(t, []TokenKey{
+		TokenKeyword, TokenUnknown,
+		TokenStringFragment, TokenKey(10), TokenKeyword, TokenKey(20), TokenKey(21), TokenKey(11), TokenStringFragment,
+	}, keys)
+}
+
+func TestTokenizeInjectEscape(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).
+		SetEscapeSymbol('\\').
+		EscapeInjectionByDoubling().
+		AddInjection(TokenKey(10), TokenKey(11))
+
+	stream := tokenizer.ParseString(`"a \{{ b {{{{ c {{ d }} e"`)
+	defer stream.Close()
+	require.Equal(t, TokenStringFragment, stream.CurrentToken().Key())
+	require.Equal(t, `"a \{{ b {{{{ c `, stream.CurrentToken().ValueString())
+	require.Equal(t, "a {{ b {{ c ", stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, TokenKey(10), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, "d", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, TokenKey(11), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, " e", stream.GoNext().CurrentToken().ValueUnescapedString())
+	require.False(t, stream.GoNext().IsValid())
+}
+
+func TestTokenizeInjectUnterminated(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"{{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}}"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).AddInjection(TokenKey(10), TokenKey(11))
+
+	stream := tokenizer.ParseBytesNamed("tpl", []byte("x\n\"one {{ two \" three"))
+	defer stream.Close()
+	var err *UnterminatedInjectionError
+	require.ErrorAs(t, stream.Err(), &err)
+	require.Equal(t, Position{File: "tpl", Line: 2, Column: 6, Offset: 7}, err.Position)
+	require.Equal(t, TokenKey(10), err.Token.Key())
+	require.Equal(t, `tpl:2:6: injection opened by "{{" isn't closed`, err.Error())
+
+	stream = tokenizer.ParseString("\"one {{ two\nthree\nfour five six")
+	defer stream.Close()
+	require.ErrorAs(t, stream.Err(), &err)
+	require.Equal(t, Position{Line: 1, Column: 6, Offset: 5}, err.Position)
+
+	stream = tokenizer.ParseString(`"one {{ two }}"`)
+	defer stream.Close()
+	require.NoError(t, stream.Err())
+}
+
+func TestTokenizeStringPrefix(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"{"})
+	tokenizer.DefineTokens(TokenKey(11), []string{"}"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).SetEscapeSymbol(BackSlash)
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).SetPrefix("r")
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).SetPrefix("f").AddInjection(TokenKey(10), TokenKey(11))
+
+	stream := tokenizer.ParseString(`"a\"" r"a\" f"a{b}" rb`)
+	defer stream.Close()
+	require.Nil(t, stream.CurrentToken().StringPrefix())
+	require.Equal(t, `a"`, stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, []byte("r"), stream.GoNext().CurrentToken().StringPrefix())
+	require.Equal(t, `a\`, stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, TokenStringFragment, stream.GoNext().CurrentToken().Key())
+	require.Equal(t, []byte("f"), stream.CurrentToken().StringPrefix())
+	require.Equal(t, "a", stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, TokenKey(10), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, "b", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, TokenKey(11), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, TokenStringFragment, stream.GoNext().CurrentToken().Key())
+	require.Equal(t, TokenKeyword, stream.GoNext().CurrentToken().Key())
+	require.Equal(t, "rb", stream.CurrentToken().ValueString())
+	require.Nil(t, stream.CurrentToken().StringPrefix())
+}
+
+func TestTokenizeChar(t *testing.T) {
+	New().DefineCharToken(TokenKey(15), "'").SpecSymbols['q'] = 'Q'
+	require.NotContains(t, DefaultStringEscapes, byte('q'))
+
+	tokenizer := New()
+	tokenizer.DefineCharToken(TokenKey(15), "'")
+	tokenizer.DefineStringToken(TokenKey(14), "'", "'")
+
+	stream := tokenizer.ParseString(`'a' '\n' '\u00e9' 'д' '\'' 'abc' ''`)
+	defer stream.Close()
+	for _, r := range []rune{'a', '\n', 'é', 'д', '\''} {
+		require.True(t, stream.CurrentToken().IsChar(), stream.CurrentToken().String())
+		require.Equal(t, TokenKey(15), stream.CurrentToken().StringKey())
+		require.Equal(t, r, stream.CurrentToken().ValueRune())
+		require.Equal(t, string(r), stream.CurrentToken().ValueUnescapedString())
+		stream.GoNext()
+	}
+	require.Equal(t, TokenString, stream.CurrentToken().Key())
+	require.Equal(t, "abc", stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, TokenString, stream.GoNext().CurrentToken().Key())
+	require.Equal(t, `''`, stream.CurrentToken().ValueString())
+	require.False(t, stream.GoNext().IsValid())
+
+	require.Equal(t, utf8.RuneError, stream.CurrentToken().ValueRune())
+}
+
+func TestTokenizeRegex(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"/", "="})
+	tokenizer.DefineTokens(TokenKey(11), []string{"("})
+	tokenizer.DefineTokens(TokenKey(12), []string{")"})
+	tokenizer.DefineRegexToken(TokenKey(15), TokenKey(12))
+
+	stream := tokenizer.ParseString(`x = /a[/]\/+/gi; (a) / 2 / b / /c/`)
+	defer stream.Close()
+	var values []string
+	for ; stream.IsValid(); stream.GoNext() {
+		tok := stream.CurrentToken()
+		if tok.StringKey() == TokenKey(15) {
+			values = append(values, "regex:"+tok.ValueUnescapedString()+":"+string(tok.RegexFlags()))
+		} else {
+			values = append(values, tok.ValueString())
+		}
+	}
+	require.Equal(t, []string{
+		"x", "=", `regex:a[/]\/+:gi`, ";", "(", "a", ")", "/", "2", "/", "b", "/", "regex:c:",
+	}, values)
+
+	stream = tokenizer.ParseString("/a\n/")
+	defer stream.Close()
+	require.Equal(t, TokenKey(10), stream.CurrentToken().Key())
+}
+
+func TestTokenizeLongestMatch(t *testing.T) {
+	for _, order := range [][]TokenKey{{10, 11, 12}, {12, 11, 10}} {
+		tokenizer := New()
+		literals := map[TokenKey][]string{10: {">"}, 11: {">=", "=>"}, 12: {">>=", "="}}
+		for _, key := range order {
+			tokenizer.DefineTokens(key, literals[key])
+		}
+		tokenizer.DefineTokens(13, []string{"="}) // the first definition of the same literal wins
+
+		stream := tokenizer.ParseString(">>= >= > => = >>")
+		defer stream.Close()
+		var keys []TokenKey
+		for ; stream.IsValid(); stream.GoNext() {
+			keys = append(keys, stream.CurrentToken().Key())
+		}
+		require.Equal(t, []TokenKey{12, 11, 10, 11, 12, 10, 10}, keys, "order %v", order)
+	}
+
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{">="})
+	tokenizer.DefineTokens(10, []string{">"}) // redefinition removes ">="
+	stream := tokenizer.ParseString(">=")
+	defer stream.Close()
+	require.Equal(t, ">", stream.CurrentToken().ValueString())
+}
+
+func TestTokenizePriority(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"in"})
+	tokenizer.DefineTokens(11, []string{"->"})
+	tokenizer.DefineTokens(12, []string{"-"})
+
+	values := func(str string) []string {
+		stream := tokenizer.ParseString(str)
+		defer stream.Close()
+		var values []string
+		for ; stream.IsValid(); stream.GoNext() {
+			values = append(values, stream.CurrentToken().ValueString())
+		}
+		return values
+	}
+	require.Equal(t, []string{"in", "t", "->", "in"}, values("int -> in"))
+
+	tokenizer.SetPriority(10, -1).SetPriority(12, 1)
+	require.Equal(t, []string{"int", "-", ">", "in"}, values("int -> in"))
+	require.Equal(t, -1, tokenizer.Definitions().Tokens[0].Priority)
+}
+
+func TestTokenizerClone(t *testing.T) {
+	base := New().AllowKeywordUnderscore()
+	base.DefineTokens(10, []string{"="})
+	base.DefineStringToken(20, `"`, `"`).SetEscapeSymbol(BackSlash)
+	base.SetKeyName(10, "ASSIGN")
+	fingerprint := base.Fingerprint()
+
+	dialect := base.Clone()
+	require.Equal(t, fingerprint, dialect.Fingerprint())
+	dialect.DefineTokens(11, []string{"=>"})
+	dialect.DefineTokens(10, []string{":="})
+	dialect.quotes[0].SetEscapeSymbol(0)
+	require.Equal(t, fingerprint, base.Fingerprint())
+
+	keys := func(tokenizer *Tokenizer) []TokenKey {
+		stream := tokenizer.ParseString(`a_b => := "c\""`)
+		defer stream.Close()
+		var keys []TokenKey
+		for ; stream.IsValid(); stream.GoNext() {
+			keys = append(keys, stream.CurrentToken().Key())
+		}
+		return keys
+	}
+	require.Equal(t, []TokenKey{TokenKeyword, 10, TokenUnknown, TokenUnknown, 10, TokenString}, keys(base))
+	require.Equal(t, []TokenKey{TokenKeyword, 11, 10, TokenString, TokenString}, keys(dialect))
+}
+
+func TestTokenizerMerge(t *testing.T) {
+	literals := New()
+	literals.DefineTokens(1, []string{"{"})
+	literals.DefineTokens(2, []string{"}"})
+	literals.DefineStringToken(3, `"`, `"`).SetEscapeSymbol(BackSlash).AddInjection(1, 2)
+	literals.SetKeyName(1, "OPEN").SetKeyName(3, "STRING")
+
+	operators := New().AllowKeywordUnderscore()
+	operators.DefineTokens(1, []string{"+", "-"})
+	operators.DefineTokens(2, []string{"=="}).SetPriority(2, 1)
+	operators.SetKeyName(1, "OP")
+
+	tokenizer := New().Merge(literals, 0).Merge(operators, 10)
+	require.Equal(t, Definitions{
+		Flags: []string{FlagKeywordUnderscore},
+		Tokens: []TokenDefinition{
+			{Key: 1, Literals: []string{"{"}},
+			{Key: 2, Literals: []string{"}"}},
+			{Key: 11, Literals: []string{"+", "-"}},
+			{Key: 12, Literals: []string{"=="}, Priority: 1},
+		},
+		Strings: []StringDefinition{
+			{Key: 3, Start: `"`, End: `"`, Escape: `\`, Injections: []QuoteInjectSettings{{StartKey: 1, EndKey: 2}}},
+		},
+		Names: map[TokenKey]string{1: "OPEN", 3: "STRING", 11: "OP"},
+	}, tokenizer.Definitions())
+
+	stream := tokenizer.ParseString(`a_b == "{c + 1}"`)
+	defer stream.Close()
+	require.Equal(t, "a_b", stream.CurrentToken().ValueString())
+	require.Equal(t, TokenKey(12), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, TokenKey(3), stream.GoNext().CurrentToken().StringKey())
+	require.Equal(t, TokenKey(11), stream.GoTo(5).CurrentToken().Key())
+}
+
+func TestTokenizerUndefineTokens(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineFullTokens(10, []string{"and"}).SetPriority(10, 1)
+	tokenizer.DefineTokens(11, []string{"=>", ""})
+	tokenizer.DefineTokens(12, []string{"="})
+
+	tokenizer.UndefineTokens(11)
+	_, err := tokenizer.Compile()
+	require.NoError(t, err)
+	require.Equal(t, []TokenDefinition{
+		{Key: 10, Literals: []string{"and"}, Full: true, Priority: 1},
+		{Key: 12, Literals: []string{"="}},
+	}, tokenizer.Definitions().Tokens)
+
+	tokenizer.RedefineTokens(10, []string{"and", "or"})
+	require.Equal(t, TokenDefinition{Key: 10, Literals: []string{"and", "or"}, Full: true, Priority: 1}, tokenizer.Definitions().Tokens[0])
+
+	stream := tokenizer.ParseString("a or =>")
+	defer stream.Close()
+	var keys []TokenKey
+	for ; stream.IsValid(); stream.GoNext() {
+		keys = append(keys, stream.CurrentToken().Key())
+	}
+	require.Equal(t, []TokenKey{TokenKeyword, 10, 12, TokenUnknown}, keys)
+}
+
+func TestTokenizerCount(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"{{"})
+	tokenizer.DefineTokens(11, []string{"}}"})
+	tokenizer.DefineTokens(12, []string{"+"})
+	tokenizer.DefineStringToken(20, "#", "\n")
+	tokenizer.DefineTrivia(20)
+	tokenizer.DefineStringToken(21, `"`, `"`).AddInjection(10, 11)
+
+	for _, str := range []string{
+		"",
+		"a + 1 # note\n",
+		`x + "one {{ y + 2 }} two" # tail`,
+	} {
+		stream := tokenizer.ParseString(str)
+		n, err := tokenizer.Count([]byte(str))
+		require.NoError(t, err)
+		require.Equal(t, stream.len, n, str)
+		stream.Close()
+	}
+
+	n, err := tokenizer.Count([]byte(`a "one {{ b`))
+	require.Equal(t, 4, n)
+	require.ErrorAs(t, err, new(*UnterminatedInjectionError))
+
+	tokenizer.SetMaxTokens(2)
+	n, err = tokenizer.Count([]byte("a b c d"))
+	require.Equal(t, 2, n)
+	require.ErrorAs(t, err, new(*LimitError))
+}
+
+func TestTokenizeAllocations(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, `"`, `"`)
+	data := bytes.Repeat([]byte(`key = "value" 10 `), 1000)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		stream := tokenizer.ParseBytes(data)
+		require.Equal(t, 4000, stream.len)
+	})
+	require.Less(t, allocs, float64(40))
+}
+
+func TestTokenizeBytesPooled(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, "#", "\n")
+	tokenizer.DefineTrivia(20)
+
+	for _, str := range []string{"a = 1 # one\n", "b=2", ""} {
+		expected := tokenizer.ParseString(str)
+		stream := tokenizer.ParseBytesPooled([]byte(str))
+		require.Equal(t, expected.String(), stream.String())
+		require.Equal(t, expected.TrailingTrivia(), stream.TrailingTrivia())
+		require.Equal(t, expected.Stats(), stream.Stats())
+		stream.Close()
+		expected.Close()
+	}
+
+	data := []byte("key = value # comment\n")
+	allocs := testing.AllocsPerRun(100, func() {
+		tokenizer.ParseBytesPooled(data).Close()
+	})
+	require.Less(t, allocs, float64(2))
+}
+
+func TestTokenizeLongStrings(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineStringToken(20, `"`, `"`).SetEscapeSymbol('\\')
+	tokenizer.DefineStringToken(21, "<<", ">>")
+	body := bytes.Repeat([]byte("line of text \\\" with escape\n"), 50)
+	data := append(append(append([]byte(`x "`), body...), `" 1234567890 <<one > two`...), "\n>> 3.25e10 y"...)
+
+	expected := []struct {
+		value        string
+		line, column int
+	}{
+		{"x", 1, 1}, {`"` + string(body) + `"`, 1, 3}, {"1234567890", 51, 3}, {"<<one > two\n>>", 51, 14}, {"3.25e10", 52, 4}, {"y", 52, 12},
+	}
+	for _, stream := range []*Stream{tokenizer.ParseBytes(data), tokenizer.ParseStream(bytes.NewReader(data), 64)} {
+		for _, e := range expected {
+			require.Equal(t, e.value, stream.CurrentToken().ValueString())
+			require.Equal(t, e.line, stream.CurrentToken().Line())
+			require.Equal(t, e.column, stream.CurrentToken().Column())
+			stream.GoNext()
+		}
+		require.False(t, stream.IsValid())
+		stream.Close()
+	}
+	require.Equal(t, 4, tokenizer.ParseString("y 12 34.5 <<unclosed").len)
+}
+
+func TestTokenizeBytesHint(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	data := bytes.Repeat([]byte("key = 10 "), 1000)
+
+	stream := tokenizer.ParseBytesHint([]byte("a = 1"), 100)
+	require.Equal(t, tokenizer.ParseString("a = 1").String(), stream.String())
+	stream.Close()
+
+	allocs := testing.AllocsPerRun(10, func() {
+		stream := tokenizer.ParseBytesHint(data, 3001)
+		require.Equal(t, 3000, stream.len)
+	})
+	require.Less(t, allocs, float64(5))
+}
+
+func TestTokenizeCopyValues(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	tokenizer.DefineStringToken(20, "#", "\n")
+	tokenizer.DefineTrivia(20)
+	data := []byte("key = value # note\n ")
+
+	stream := tokenizer.ParseBytes(data)
+	copied := stream.CurrentToken().Copy()
+	last := stream.GoTo(2).CurrentToken().Copy()
+	stream.Close()
+	tokenizer.CopyValues()
+	stream = tokenizer.ParseBytes(data)
+	defer stream.Close()
+
+	for i := range data {
+		data[i] = 'x'
+	}
+	require.Equal(t, "key", copied.ValueString())
+	require.Equal(t, " ", string(last.Indent()))
+	require.Equal(t, "value", stream.GoTo(2).CurrentToken().ValueString())
+	require.Equal(t, " ", string(stream.CurrentToken().Indent()))
+	trivia := stream.TrailingTrivia()
+	require.Equal(t, "# note\n", string(trivia[1].Value))
+	require.Equal(t, " ", string(trivia[2].Value))
+}
+
+func TestTokenizeBytesCopy(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"="})
+	data := []byte("key = value\n  next")
+
+	stream := tokenizer.ParseBytesCopy(data)
+	defer stream.Close()
+	for i := range data {
+		data[i] = 'x'
+	}
+	require.Equal(t, "key", stream.CurrentToken().ValueString())
+	require.Equal(t, "value", stream.GoTo(2).CurrentToken().ValueString())
+	require.Equal(t, "next", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, "\n  ", string(stream.CurrentToken().Indent()))
+}
+
+func TestTokenizeSingleLineString(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineStringToken(20, `"`, `"`).SetEscapeSymbol(BackSlash).AllowNewlines(false)
+	logger := &testLogger{}
+	tokenizer.SetLogger(logger, LogWarning)
+
+	stream := tokenizer.ParseString("\"one\ntwo \"three\\\nfour\" \"" + string(bytes.Repeat([]byte("x"), 100)) + "\nfive")
+	defer stream.Close()
+	require.Equal(t, "\"one", stream.CurrentToken().ValueString())
+	require.Equal(t, TokenString, stream.CurrentToken().Key())
+	require.Equal(t, "two", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, "\"three\\\nfour\"", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, 101, len(stream.GoNext().CurrentToken().Value()))
+	require.Equal(t, "five", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, 4, stream.CurrentToken().Line())
+	require.Equal(t, testLogger{
+		`warning 1:5 string 20 isn't closed by "\"" at the end of line`,
+		`warning 3:108 string 20 isn't closed by "\"" at the end of line`,
+	}, *logger)
+
+	tokenizer.DefineStringToken(21, "'", "'").KeepQuotes(true)
+	stream = tokenizer.ParseString("'one\ntwo'")
+	defer stream.Close()
+	require.Equal(t, "'one\ntwo'", stream.CurrentToken().ValueString())
+}
+
+func TestTokenizeKeepQuotes(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineStringToken(20, `"`, `"`).SetEscapeSymbol(BackSlash).KeepQuotes(true)
+	tokenizer.DefineStringToken(21, "'", "'").SetEscapeSymbol(BackSlash).KeepQuotes(true).KeepQuotes(false)
+
+	stream := tokenizer.ParseString(`"one" "two \"2\"" "three 'three'`)
+	defer stream.Close()
+	require.Equal(t, `"one"`, stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, `"two "2""`, stream.GoNext().CurrentToken().ValueUnescapedString())
+	require.Equal(t, `"three 'three'`, stream.GoNext().CurrentToken().ValueUnescapedString())
+
+	stream = tokenizer.ParseString(`'four \'4\''`)
+	defer stream.Close()
+	require.Equal(t, `four '4'`, stream.CurrentToken().ValueUnescapedString())
+}
+
+func TestTokenizeTokenSettings(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"and"}).CaseInsensitive().WholeWord().
+		DefineTokens(11, []string{"in"}).WholeWord().
+		DefineTokens(12, []string{"->"}).Mode("arrows").
+		DefineTokens(13, []string{"-", ">"}).Priority(-1)
+
+	parse := func(tokenizer *Tokenizer, source string) ([]string, []TokenKey) {
+		stream := tokenizer.ParseString(source)
+		defer stream.Close()
+		var values []string
+		var keys []TokenKey
+		for ; stream.IsValid(); stream.GoNext() {
+			values = append(values, stream.CurrentToken().ValueString())
+			keys = append(keys, stream.CurrentToken().Key())
+		}
+		return values, keys
+	}
+	values, keys := parse(tokenizer, "a AND b And android in index a->b")
+	require.Equal(t, []string{"a", "AND", "b", "And", "android", "in", "index", "a", "-", ">", "b"}, values)
+	require.Equal(t, []TokenKey{TokenKeyword, 10, TokenKeyword, 10, TokenKeyword, 11, TokenKeyword, TokenKeyword, 13, 13, TokenKeyword}, keys)
+
+	tokenizer.EnableModes("arrows")
+	_, keys = parse(tokenizer, "a->b")
+	require.Equal(t, []TokenKey{TokenKeyword, 12, TokenKeyword}, keys)
+
+	redefined := tokenizer.Clone().RedefineTokens(10, []string{"or"})
+	_, keys = parse(redefined, "OR order AND a->b")
+	require.Equal(t, []TokenKey{10, TokenKeyword, TokenKeyword, TokenKeyword, 12, TokenKeyword}, keys)
+}
+
+func TestTokenizeNormalizeNFC(t *testing.T) {
+	tokenizer := New().NormalizeNFC()
+	tokenizer.DefineTokens(10, []string{"caf\u00e9"})
+	source := "cafe\u0301 = nai\u0308ve"
+
+	stream := tokenizer.ParseString(source)
+	defer stream.Close()
+	require.Equal(t, TokenKey(10), stream.CurrentToken().Key())
+	require.Equal(t, "caf\u00e9", stream.CurrentToken().ValueString())
+	require.Equal(t, "na\u00efve", stream.GoTo(2).CurrentToken().ValueString())
+	require.Equal(t, 8, stream.CurrentToken().Offset())
+
+	stream = tokenizer.ParseStream(strings.NewReader(source), 4)
+	defer stream.Close()
+	require.Equal(t, TokenKey(10), stream.CurrentToken().Key())
+	require.Equal(t, "na\u00efve", stream.GoTo(2).CurrentToken().ValueString())
+
+	data := []byte("na\u00efve")
+	stream = tokenizer.ParseBytes(data)
+	defer stream.Close()
+	require.Same(t, &data[0], &stream.CurrentToken().Value()[0])
+
+	stream = New().DefineTokens(10, []string{"caf\u00e9"}).ParseString(source)
+	defer stream.Close()
+	require.Equal(t, TokenKeyword, stream.CurrentToken().Key())
+
+	tokenizer.DefineTokens(11, []string{"cafe\u0301"})
+	_, err := tokenizer.Compile()
+	require.EqualError(t, err, "literal \"cafe\u0301\" of key 11 isn't in NFC and is never matched")
+}
+
+func TestTokenizeFoldKeywords(t *testing.T) {
+	tokenizer := New().FoldKeywords()
+	tokenizer.DefineTokens(10, []string{"="})
+	source := "SELECT select Ünïcode = тест"
+
+	stream := tokenizer.ParseString(source)
+	defer stream.Close()
+	require.Equal(t, "select", stream.CurrentToken().ValueFoldedString())
+	require.Equal(t, "SELECT", stream.CurrentToken().ValueString())
+	require.Same(t, &stream.GoNext().CurrentToken().Value()[0], &stream.CurrentToken().ValueFolded()[0])
+	require.Equal(t, "ünïcode", stream.GoNext().CurrentToken().ValueFoldedString())
+	require.Equal(t, "=", stream.GoNext().CurrentToken().ValueFoldedString())
+	require.Equal(t, "тест", stream.GoNext().CurrentToken().ValueFoldedString())
+	require.Equal(t, "select", stream.GoTo(0).CurrentToken().Copy().ValueFoldedString())
+
+	stream = tokenizer.ParseStream(strings.NewReader(source), 4)
+	defer stream.Close()
+	require.Equal(t, "select", stream.CurrentToken().ValueFoldedString())
+
+	stream = New().ParseString(source)
+	defer stream.Close()
+	require.Equal(t, "SELECT", stream.CurrentToken().ValueFoldedString())
+}
+
+// keyValues parses the source and returns tokens as "key:value".
+func keyValues(t *testing.T, tokenizer *Tokenizer, source string) []string {
+	t.Helper()
+	stream := tokenizer.ParseString(source)
+	defer stream.Close()
+	var values []string
+	for ; stream.IsValid(); stream.GoNext() {
+		values = append(values, fmt.Sprintf("%d:%s", stream.CurrentToken().Key(), stream.CurrentToken().ValueString()))
+	}
+	return values
+}
+
+func TestTokenizeSignedNumbers(t *testing.T) {
+	tokenizer := New().AllowSignedNumbers(TokenKey(12))
+	tokenizer.DefineTokens(10, []string{"-", "+", "="})
+	tokenizer.DefineTokens(11, []string{"("})
+	tokenizer.DefineTokens(12, []string{")"})
+
+	require.Equal(t, []string{"-2:-5", "10:+", "-3:+3.2"}, keyValues(t, tokenizer, "-5 + +3.2"))
+	require.Equal(t, []string{"-1:x", "10:=", "11:(", "-2:-5", "12:)", "10:-", "-2:1"}, keyValues(t, tokenizer, "x = (-5) -1"))
+	require.Equal(t, []string{"-1:x", "10:-", "-2:5", "10:-", "-2:-2"}, keyValues(t, tokenizer, "x-5 - -2"))
+	require.Equal(t, []string{"10:-", "-1:x", "10:-", "10:-", "-2:1"}, keyValues(t, tokenizer, "-x - - 1"))
+
+	stream := tokenizer.ParseString("= -12 = +2.5e1")
+	defer stream.Close()
+	require.Equal(t, int64(-12), stream.GoNext().CurrentToken().ValueInt())
+	require.Equal(t, 2.5e1, stream.GoTo(3).CurrentToken().ValueFloat())
+}
+
+func TestTokenizeLeadingDotFloat(t *testing.T) {
+	tokenizer := New().AllowLeadingDotFloat().AllowSignedNumbers()
+	tokenizer.DefineTokens(10, []string{".", "-"})
+
+	require.Equal(t, []string{"-3:-.25", "-3:.5", "-3:.5e3", "-3:1.5"}, keyValues(t, tokenizer, "-.25 .5 .5e3 1.5"))
+	require.Equal(t, []string{"-1:a", "10:.", "-1:b", "-1:x", "-3:.0"}, keyValues(t, tokenizer, "a.b x.0"))
+	require.Equal(t, []string{"10:.", "10:.", "-1:e"}, keyValues(t, tokenizer, ". .e"))
+
+	stream := tokenizer.ParseStream(strings.NewReader("scale      .75 .5e1"), 10)
+	defer stream.Close()
+	require.Equal(t, 0.75, stream.GoNext().CurrentToken().ValueFloat())
+	require.Equal(t, 5.0, stream.GoNext().CurrentToken().ValueFloat())
+
+	dot := New()
+	dot.DefineTokens(10, []string{"."})
+	require.Equal(t, []string{"10:.", "-2:5"}, keyValues(t, dot, ".5"))
+}
+
+func TestTokenizeTrailingDot(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(10, []string{"..", "."})
+	require.Equal(t, []string{"-3:2.", "-1:abs"}, keyValues(t, tokenizer, "2.abs"))
+
+	tokenizer.SetTrailingDot(TrailingDotInteger)
+	require.Equal(t, []string{"-2:2", "10:.", "-1:abs"}, keyValues(t, tokenizer, "2.abs"))
+	require.Equal(t, []string{"-2:1", "10:..", "-2:5", "-3:1.5", "-2:3", "10:."}, keyValues(t, tokenizer, "1..5 1.5 3."))
+
+	tokenizer.SetTrailingDot(TrailingDotReject)
+	stream := tokenizer.ParseString("x = 1.5\ny = 12. + 1")
+	defer stream.Close()
+	var values []string
+	for _, tok := range stream.Tokens() {
+		values = append(values, tok.ValueString())
+	}
+	require.Equal(t, []string{"x", "=", "1.5", "y", "="}, values)
+	var trailing *TrailingDotError
+	require.ErrorAs(t, stream.Err(), &trailing)
+	require.Equal(t, "12.", trailing.Token.ValueString())
+	require.Equal(t, 2, trailing.Position.Line)
+	require.Equal(t, 5, trailing.Position.Column)
+	require.Equal(t, "2:5: number \"12.\" has the trailing dot", trailing.Error())
+}
+
+func TestTokenizeBigNumbers(t *testing.T) {
+	stream := New().ParseString("123456789012345678901234567890 98765432109876543210.123456789012345678901234567890 1e400 2. abc")
+	defer stream.Close()
+
+	require.Equal(t, TokenInteger, stream.CurrentToken().Key())
+	require.Equal(t, "123456789012345678901234567890", stream.CurrentToken().ValueBigInt().String())
+	require.Equal(t, 1.2345678901234568e29, stream.CurrentToken().ValueFloat())
+	require.Equal(t, "123456789012345678901234567890", stream.CurrentToken().ValueBigFloat().Text('f', 0))
+
+	tok := stream.GoNext().CurrentToken()
+	require.Equal(t, TokenFloat, tok.Key())
+	require.Equal(t, "98765432109876543210.123456789012345678901234567890", tok.ValueBigFloat().Text('f', 30))
+	require.Equal(t, "98765432109876543210", tok.ValueBigInt().String())
+
+	tok = stream.GoNext().CurrentToken()
+	require.Equal(t, TokenFloat, tok.Key())
+	require.Equal(t, "1e+400", tok.ValueBigFloat().Text('g', 10))
+	require.Equal(t, "1"+strings.Repeat("0", 400), tok.ValueBigInt().String())
+
+	require.Equal(t, "2", stream.GoNext().CurrentToken().ValueBigInt().String())
+	require.Nil(t, stream.GoNext().CurrentToken().ValueBigInt())
+	require.Nil(t, stream.CurrentToken().ValueBigFloat())
+}
+
+func TestTokenizeInfNaN(t *testing.T) {
+	tokenizer := New().AllowInfNaN()
+	tokenizer.DefineTokens(10, []string{"-", "+", "="})
+
+	require.Equal(t, []string{"-3:Inf", "-3:NaN"}, keyValues(t, tokenizer, "Inf NaN"))
+	require.Equal(t, []string{"-3:+Inf", "10:-", "-3:Inf"}, keyValues(t, tokenizer, "+Inf -Inf"))
+	require.Equal(t, []string{"-1:x", "10:-", "-3:Inf", "-1:Infinity", "-1:nan", "-1:NaNa"}, keyValues(t, tokenizer, "x -Inf Infinity nan NaNa"))
+	require.Equal(t, []string{"-1:x", "10:=", "-3:-Inf"}, keyValues(t, tokenizer, "x = -Inf"))
+
+	stream := tokenizer.ParseString("Inf NaN")
+	defer stream.Close()
+	require.True(t, math.IsInf(stream.CurrentToken().ValueFloat(), 1))
+	require.Equal(t, int64(0), stream.CurrentToken().ValueInt())
+	require.Equal(t, "+Inf", stream.CurrentToken().ValueBigFloat().String())
+	require.True(t, math.IsNaN(stream.GoNext().CurrentToken().ValueFloat()))
+	require.Nil(t, stream.CurrentToken().ValueBigFloat())
+
+	yaml := New().AllowInfNaN(".inf", "-.inf", ".NaN")
+	stream = yaml.ParseString("-.inf .NaN")
+	defer stream.Close()
+	require.Equal(t, TokenFloat, stream.CurrentToken().Key())
+	require.True(t, math.IsInf(stream.CurrentToken().ValueFloat(), -1))
+	require.Equal(t, "-Inf", stream.CurrentToken().ValueBigFloat().String())
+	require.True(t, math.IsNaN(stream.GoNext().CurrentToken().ValueFloat()))
+
+	_, err := New().AllowInfNaN("Inf", "none", "0x1p-2").Compile()
+	require.EqualError(t, err, "spelling \"none\" of AllowInfNaN is neither infinity nor NaN\n"+
+		"spelling \"0x1p-2\" of AllowInfNaN is neither infinity nor NaN")
+}
+
+func TestTokenizeUnits(t *testing.T) {
+	tokenizer := New().AllowKeywordUnderscore()
+	tokenizer.DefineTokens(10, []string{">", "<"})
+	tokenizer.DefineUnits(20, []string{"KB", "MB", "MiB", "%"})
+	tokenizer.DefineUnits(21, []string{"ms", "s"}).Merge(true)
+
+	require.Equal(t, []string{"-1:bytes_in", "10:>", "-2:5", "20:MB"}, keyValues(t, tokenizer, "bytes_in > 5MB"))
+	require.Equal(t, []string{"-1:timeout", "10:<", "21:200ms", "21:1.5s"}, keyValues(t, tokenizer, "timeout < 200ms 1.5s"))
+	require.Equal(t, []string{"-2:10", "20:MiB", "-3:2.5", "20:%", "-2:5", "-1:msec", "-2:3", "-1:KBs"}, keyValues(t, tokenizer, "10MiB 2.5% 5msec 3KBs"))
+	require.Equal(t, []string{"-2:5", "-1:MB"}, keyValues(t, tokenizer, "5 MB"))
+
+	stream := tokenizer.ParseStream(strings.NewReader("limit    200ms 1.5e3s"), 8)
+	defer stream.Close()
+	tok := stream.GoNext().CurrentToken()
+	require.Equal(t, TokenKey(21), tok.Key())
+	require.Equal(t, "ms", string(tok.ValueUnit()))
+	require.Equal(t, int64(200), tok.ValueInt())
+	require.Equal(t, 200.0, tok.ValueFloat())
+	tok = stream.GoNext().CurrentToken()
+	require.Equal(t, "s", string(tok.ValueUnit()))
+	require.Equal(t, 1.5e3, tok.ValueFloat())
+	require.Equal(t, "1500", tok.ValueBigInt().String())
+
+	units := New().ParseString("5MB")
+	defer units.Close()
+	require.Nil(t, units.CurrentToken().ValueUnit())
+
+	_, err := New().DefineUnits(20, []string{"", "2x"}).Compile()
+	require.EqualError(t, err, "key 20 unit \"\" is empty or starts with the digit\n"+
+		"key 20 unit \"2x\" is empty or starts with the digit")
+}
+
+func TestTokenizeThousandsSeparator(t *testing.T) {
+	commas := New().SetThousandsSeparator(',').AllowSignedNumbers()
+	commas.DefineTokens(10, []string{","})
+	require.Equal(t, []string{"-2:1,000,000", "10:,", "-3:1,234.5", "10:,", "-2:12"}, keyValues(t, commas, "1,000,000, 1,234.5, 12"))
+	require.Equal(t, []string{"-2:1", "10:,", "-2:00", "-2:1234", "10:,", "-2:567", "-2:1,000", "10:,", "-2:0000"}, keyValues(t, commas, "1,00 1234,567 1,000,0000"))
+	require.Equal(t, []string{"-2:-12,345", "-3:1.5", "10:,", "-2:000"}, keyValues(t, commas, "-12,345 1.5,000"))
+
+	spaces := New().SetThousandsSeparator(' ')
+	require.Equal(t, []string{"-2:1 000 000", "-2:12", "-2:34"}, keyValues(t, spaces, "1 000 000 12 34"))
+
+	stream := spaces.ParseStream(strings.NewReader("total 12 345 678.25 1 000"), 8)
+	defer stream.Close()
+	tok := stream.GoNext().CurrentToken()
+	require.Equal(t, "12 345 678.25", tok.ValueString())
+	require.Equal(t, 12345678.25, tok.ValueFloat())
+	require.Equal(t, int64(12345678), tok.ValueInt())
+	require.Equal(t, int64(1000), stream.GoNext().CurrentToken().ValueInt())
+	require.Equal(t, "1000", stream.CurrentToken().ValueBigInt().String())
+
+	_, err := New().SetThousandsSeparator('.').Compile()
+	require.EqualError(t, err, "thousands separator '.' is the letter, the digit, the dot, the sign or the new line")
+}
+
+func TestTokenizeDateTime(t *testing.T) {
+	tokenizer := New().AllowKeywordUnderscore()
+	tokenizer.DefineTokens(10, []string{">", "<=", "-"})
+	tokenizer.DefineDateTimeToken(20)
+	tokenizer.DefineDateTimeToken(21, "Jan _2 15:04:05").In(time.FixedZone("UTC+3", 3*60*60))
+
+	require.Equal(t, []string{"-1:modified", "10:>", "20:2021-10-06 12:30:44", "-1:and", "-1:bytes_in", "10:<=", "-2:100"},
+		keyValues(t, tokenizer, "modified > 2021-10-06 12:30:44 and bytes_in <= 100"))
+	require.Equal(t, []string{"20:2021-10-06T12:30:44.5Z", "20:2021-10-06", "-2:2021", "10:-", "-2:10", "-2:12"},
+		keyValues(t, tokenizer, "2021-10-06T12:30:44.5Z 2021-10-06 2021-10 12"))
+	require.Equal(t, []string{"-2:2021", "10:-", "-2:10", "10:-", "-2:06", "-1:x", "21:Oct  6 12:30:44", "-1:Jan"},
+		keyValues(t, tokenizer, "2021-10-06x Oct  6 12:30:44 Jan"))
+
+	stream := tokenizer.ParseStream(strings.NewReader("at    2021-10-06T12:30:44+03:00 Oct  6 12:30:44"), 8)
+	defer stream.Close()
+	tm, ok := stream.GoNext().CurrentToken().ValueTime()
+	require.True(t, ok)
+	require.True(t, time.Date(2021, 10, 6, 9, 30, 44, 0, time.UTC).Equal(tm))
+	tm, ok = stream.GoNext().CurrentToken().ValueTime()
+	require.True(t, ok)
+	require.Equal(t, "0000-10-06T12:30:44+03:00", tm.Format(time.RFC3339))
+	_, ok = stream.GoPrev().GoPrev().CurrentToken().ValueTime()
+	require.False(t, ok)
+
+	textual := New()
+	textual.DefineTokens(10, []string{",", ":"})
+	textual.DefineDateTimeToken(20, time.RFC1123, time.Kitchen, "_2.01.2006", "at 15:04 -0700")
+	require.Equal(t, []string{"20:Wed, 06 Oct 2021 12:30:44 MSK", "20:3:04PM", "-1:Mon", "10:,", "20:16.10.2021", "20:at 12:30 +0300"},
+		keyValues(t, textual, "Wed, 06 Oct 2021 12:30:44 MSK 3:04PM Mon, 16.10.2021 at 12:30 +0300"))
+	require.Equal(t, []string{"20:sun, 06 oct 2021 12:30:44 +03", "-2:3", "10::", "-2:04", "-1:XM"},
+		keyValues(t, textual, "sun, 06 oct 2021 12:30:44 +03 3:04XM"))
+	require.Equal(t, []byte("0000\x02"), dateTimeShape("2006Mon"))
+	require.Equal(t, []byte("T00:0"), dateTimeShape("T15:4"))
+	require.Equal(t, []byte("_0000-00\x01"), dateTimeShape("_2006-01_2"))
+
+	_, err := New().DefineDateTimeToken(20, "").Compile()
+	require.EqualError(t, err, "key 20 date and time literal has the empty layout")
+}
+
+func TestTokenizeDuration(t *testing.T) {
+	tokenizer := New().DefineDurationToken(20)
+	tokenizer.DefineTokens(10, []string{"<", "-"})
+	tokenizer.DefineUnits(21, []string{"ms", "min"}).Merge(true)
+
+	require.Equal(t, []string{"-1:timeout", "10:<", "20:250ms", "20:1h30m", "20:1.5h", "20:2µs"}, keyValues(t, tokenizer, "timeout < 250ms 1h30m 1.5h 2µs"))
+	require.Equal(t, []string{"21:5min", "-2:5", "-1:hours", "-2:1", "-1:h", "-2:30", "10:-", "20:1s", "-2:15"}, keyValues(t, tokenizer, "5min 5 hours 1h30 -1s 15"))
+	require.Equal(t, []string{"-2:9999999999999", "-1:h"}, keyValues(t, tokenizer, "9999999999999h"))
+
+	stream := tokenizer.ParseStream(strings.NewReader("elapsed 1h2m3.5s 200ms 20"), 8)
+	defer stream.Close()
+	d, ok := stream.GoNext().CurrentToken().ValueDuration()
+	require.True(t, ok)
+	require.Equal(t, time.Hour+2*time.Minute+3500*time.Millisecond, d)
+	d, ok = stream.GoNext().CurrentToken().ValueDuration()
+	require.True(t, ok)
+	require.Equal(t, 200*time.Millisecond, d)
+	_, ok = stream.GoNext().CurrentToken().ValueDuration()
+	require.False(t, ok)
+
+	off := tokenizer.Clone().DefineDurationToken(TokenUndef).ParseString("250ms")
+	defer off.Close()
+	require.Equal(t, "21:250ms", fmt.Sprintf("%d:%s", off.CurrentToken().Key(), off.CurrentToken().ValueString()))
+	d, ok = off.CurrentToken().ValueDuration()
+	require.True(t, ok)
+	require.Equal(t, 250*time.Millisecond, d)
+}
+
+func TestTokenizeUUID(t *testing.T) {
+	tokenizer := New().DefineUUIDToken(20)
+	tokenizer.DefineTokens(10, []string{"==", "-"})
+
+	require.Equal(t, []string{"-1:id", "10:==", "20:550e8400-e29b-41d4-a716-446655440000"}, keyValues(t, tokenizer, "id == 550e8400-e29b-41d4-a716-446655440000"))
+	require.Equal(t, []string{"20:F47AC10B-58CC-4372-A567-0E02B2C3D479"}, keyValues(t, tokenizer, "F47AC10B-58CC-4372-A567-0E02B2C3D479"))
+	require.Equal(t, []string{"-3:550e8400", "10:-"}, keyValues(t, tokenizer, "550e8400-e29b-41d4-a716-446655440000x")[:2])
+	require.Equal(t, []string{"-1:deadbeef"}, keyValues(t, tokenizer, "deadbeef"))
+
+	stream := tokenizer.ParseStream(strings.NewReader("id 550e8400-e29b-41d4-a716-446655440000"), 8)
+	defer stream.Close()
+	uuid, ok := stream.GoNext().CurrentToken().ValueUUID()
+	require.True(t, ok)
+	require.Equal(t, [16]byte{0x55, 0x0e, 0x84, 0x00, 0xe2, 0x9b, 0x41, 0xd4, 0xa7, 0x16, 0x44, 0x66, 0x55, 0x44, 0x00, 0x00}, uuid)
+	_, ok = stream.GoPrev().CurrentToken().ValueUUID()
+	require.False(t, ok)
+}
+
+func TestTokenizeIP(t *testing.T) {
+	tokenizer := New().DefineIPTokens(20, 21)
+	tokenizer.DefineTokens(10, []string{"==", ".", ":", "/"})
+
+	require.Equal(t, []string{"-1:src", "10:==", "20:10.0.0.1"}, keyValues(t, tokenizer, "src == 10.0.0.1"))
+	require.Equal(t, []string{"20:2001:db8::1", "20:::1"}, keyValues(t, tokenizer, "2001:db8::1 ::1"))
+	require.Equal(t, []string{"21:10.0.0.0/8", "21:2001:db8::/32"}, keyValues(t, tokenizer, "10.0.0.0/8 2001:db8::/32"))
+	require.Equal(t, []string{"20:10.0.0.1", "10:."}, keyValues(t, tokenizer, "10.0.0.1."))
+	require.Equal(t, []string{"20:10.0.0.1", "10:/", "-2:33"}, keyValues(t, tokenizer, "10.0.0.1/33"))
+	require.Equal(t, []string{"-3:1.2", "10:.", "-2:3"}, keyValues(t, tokenizer, "1.2.3"))
+	require.Equal(t, []string{"-2:12", "10::", "-2:30"}, keyValues(t, tokenizer, "12:30"))
+	require.Equal(t, []string{"-1:cafe"}, keyValues(t, tokenizer, "cafe"))
+	require.Equal(t, []string{"-3:10.0", "10:."}, keyValues(t, tokenizer, "10.0.0.1x")[:2])
+
+	stream := tokenizer.ParseStream(strings.NewReader("src 192.168.0.1 dst 2001:db8::/32"), 8)
+	defer stream.Close()
+	addr, ok := stream.GoNext().CurrentToken().ValueAddr()
+	require.True(t, ok)
+	require.Equal(t, netip.MustParseAddr("192.168.0.1"), addr)
+	prefix, ok := stream.GoNext().GoNext().CurrentToken().ValuePrefix()
+	require.True(t, ok)
+	require.Equal(t, netip.MustParsePrefix("2001:db8::/32"), prefix)
+	_, ok = stream.GoPrev().CurrentToken().ValueAddr()
+	require.False(t, ok)
+
+	tokenizer = New().DefineIPTokens(20, TokenUndef)
+	tokenizer.DefineTokens(10, []string{"/"})
+	require.Equal(t, []string{"20:10.0.0.0", "10:/", "-2:8"}, keyValues(t, tokenizer, "10.0.0.0/8"))
+}
+func TestTokenizeInjectOneToken(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"$"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).AddInjection(TokenKey(10), TokenUndef)
+
+	stream := tokenizer.ParseString(`"a $b c$d" e`)
+	var values []string
+	for _, tok := range stream.Tokens() {
+		values = append(values, tok.ValueString())
+	}
+	require.Equal(t, []string{`"a `, "$", "b", " c", "$", "d", `"`, "e"}, values)
+}
+
+func TestTokenizeEscapes(t *testing.T) {
+	tokenizer := New()
+	tokenizer.DefineTokens(TokenKey(10), []string{"-"})
+	tokenizer.DefineStringToken(TokenKey(14), `"`, `"`).SetEscapeSymbol(BackSlash).SetSpecialSymbols(DefaultStringEscapes)
+	tokenizer.DefineStringToken(TokenKey(15), "'", "'").EscapeByDoubling()
+	tokenizer.DefineStringToken(TokenKey(16), "--", "\n")
+
+	stream := tokenizer.ParseString(`"one \"two\"\t three" "\q" 'it''s' - 'x' -- note` + "\n-")
+	require.Equal(t, "one \"two\"\t three", stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, "q", stream.GoNext().CurrentToken().ValueUnescapedString())
+	require.Equal(t, "'it''s'", stream.GoNext().CurrentToken().ValueString())
+	require.Equal(t, "it's", stream.CurrentToken().ValueUnescapedString())
+	require.Equal(t, TokenKey(10), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, "x", stream.GoNext().CurrentToken().ValueUnescapedString())
+	require.Equal(t, TokenKey(16), stream.GoNext().CurrentToken().StringKey())
+	require.Equal(t, TokenKey(10), stream.GoNext().CurrentToken().Key())
+	require.Equal(t, 2, stream.CurrentToken().Line())
+}
+
+func TestTokenizeNewLineTokens(t *testing.T) {
+	tokenizer := New().SetWhiteSpaces([]byte{' '})
+	tokenizer.DefineTokens(TokenKey(10), []string{"\r\n", "\n"})
+
+	stream := tokenizer.ParseString("a\r\n b\n\tc")
+	require.Equal(t, []int{1, 1, 2, 2, 3, 3}, []int{
+		stream.CurrentToken().Line(), stream.GoNext().CurrentToken().Line(),
+		stream.GoNext().CurrentToken().Line(), stream.GoNext().CurrentToken().Line(),
+		stream.GoNext().CurrentToken().Line(), stream.GoNext().CurrentToken().Line(),
+	})
+	require.Equal(t, TokenUnknown, stream.PrevToken().Key())
+	require.Equal(t, 2, stream.CurrentToken().Column())
+}
+
+func TestTokenizeTrivia(t *testing.T) {
+	commentKey := TokenKey(15)
+	tokenizer := New()
+	tokenizer.DefineStringToken(commentKey, "//", "\n")
+	tokenizer.DefineStringToken(commentKey, "/*", "*/")
+	tokenizer.DefineTrivia(commentKey)
+
+	str := "// doc\n// comment\n\n  func /* inline */ name // tail"
+	stream := tokenizer.ParseString(str)
+
+	require.Equal(t, []Trivia{
+		{Key: commentKey, Value: []byte("// doc\n"), Line: 1, Offset: 0},
+		{Key: commentKey, Value: []byte("// comment\n"), Line: 2, Offset: 7},
+		{Key: TokenUndef, Value: []byte("\n  "), Line: 3, Offset: 18},
+	}, stream.CurrentToken().Trivia())
+	require.Equal(t, 0, stream.CurrentToken().ID())
+	require.Equal(t, "func", stream.CurrentToken().ValueString())
+	require.Equal(t, 1, stream.CurrentToken().Trivia()[2].NewLines())
+	require.True(t, stream.CurrentToken().Trivia()[0].IsComment())
+	require.False(t, stream.CurrentToken().Trivia()[2].IsComment())
+
+	stream.GoNext()
+	require.Equal(t, 1, stream.CurrentToken().ID())
+	require.Equal(t, "name", stream.CurrentToken().ValueString())
+	require.Len(t, stream.CurrentToken().Trivia(), 3)
+	require.Equal(t, []byte("/* inline */"), stream.CurrentToken().Trivia()[1].Value)
+	require.False(t, stream.GoNext().IsValid())
+
+	out := bytes.NewBuffer(nil)
+	_, err := stream.WriteTo(out)
+	require.NoError(t, err)
+	require.Equal(t, str, out.String())
+	require.Equal(t, str, NewRewriter(stream).String())
+	require.Equal(t, []Trivia{
+		{Key: TokenUndef, Value: []byte(" "), Line: 4, Offset: 43},
+		{Key: commentKey, Value: []byte("// tail"), Line: 4, Offset: 44},
+	}, stream.TrailingTrivia())
+	require.Equal(t, []Trivia{
+		{Key: TokenUndef, Value: []byte("\n "), Line: 1, Offset: 4},
+	}, tokenizer.ParseString("name\n ").TrailingTrivia())
+	stream.Close()
+
+	out.Reset()
+	_, err = tokenizer.ParseStream(bytes.NewBufferString(str), 5).WriteTo(out)
+	require.NoError(t, err)
+	require.Equal(t, str, out.String())
+}
+
+func TestTokenizeTrace(t *testing.T) {
+	trace := bytes.NewBuffer(nil)
+	tokenizer := New().EnableTrace(trace).SetKeyName(TokenKey(10), "OP")
+	tokenizer.DefineTokens(TokenKey(10), []string{"==", "="})
+	tokenizer.DefineFullTokens(TokenKey(11), []string{"and"})
+	tokenizer.DefineStringToken(TokenKey(20), `"`, `"`)
+	tokenizer.ParseString("a = 1.5 andy ! \"b").Close()
+	require.Equal(t, `1:1: token "and" (11) isn't matched
+1:1: keyword "a"
+1:3: token "==" (OP) isn't matched
+1:3: token "=" (OP) matched
+1:5: number "1.5" (-3)
+1:9: token "and" (11) isn't matched: it isn't followed by whitespace
+1:9: keyword "andy"
+1:14: unknown byte '!': no token, keyword, number or string starts with it
+1:16: string 20 opened by "\""
+1:18: warning: string 20 isn't closed by "\"" at the end of data
+`, trace.String())
+
+	trace.Reset()
+	tokenizer.EnableTrace(nil).ParseString("a == b").Close()
+	require.Empty(t, trace.String())
+}
+
+func TestParseCtx(t *testing.T) {
+	tokenizer := New()
+	source := bytes.Repeat([]byte("one two "), 5000)
+
+	stream, err := tokenizer.ParseBytesCtx(context.Background(), source)
+	require.NoError(t, err)
+	require.NoError(t, stream.Err())
+	require.Len(t, stream.Tokens(), 10000)
+	stream.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream, err = tokenizer.ParseBytesCtx(ctx, source)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Nil(t, stream)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stream = tokenizer.ParseReaderCtx(ctx, bytes.NewReader(source), 64)
+	defer stream.Close()
+	n := 0
+	for ; stream.IsValid(); stream.GoNext() {
+		if n++; n == 100 {
+			cancel()
+		}
+	}
+	require.Less(t, n, 10000)
+	require.ErrorIs(t, stream.Err(), context.Canceled)
 }
@@ -0,0 +1,106 @@
+package tokenizer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// UnitSettings describes unit suffixes of numbers, see Tokenizer.DefineUnits.
+type UnitSettings struct {
+	*Tokenizer
+	// Key is the key of unit tokens, or of numbers with units if Merged.
+	Key TokenKey
+	// Units are suffixes, the longest first.
+	Units [][]byte
+	// Merged units are the part of the number token, see Merge.
+	Merged bool
+}
+
+// Merge makes the unit the part of the number token with the key of units, like `200ms`, instead of the separate
+// unit token after the number. Token.ValueUnit returns the unit, Token.ValueInt and Token.ValueFloat decode the number.
+func (u *UnitSettings) Merge(merge bool) *UnitSettings {
+	u.Merged = merge
+	return u
+}
+
+// literals returns units as strings.
+func (u *UnitSettings) literals() []string {
+	literals := make([]string, len(u.Units))
+	for i, unit := range u.Units {
+		literals[i] = string(unit)
+	}
+	return literals
+}
+
+// DefineUnits defines unit suffixes which may follow the number without whitespace, like `ms` in `200ms`
+// or `MB` in `5MB`. The unit is matched if it isn't followed by the letter, digit or underscore, so `5ms` is
+// the number with the unit and `5msec` is the number and the keyword. Units are case-sensitive, the longest wins.
+// By default the unit is the token with the key `key` after the number token, see UnitSettings.Merge.
+// If key already exists units will be rewritten.
+func (t *Tokenizer) DefineUnits(key TokenKey, units []string) *UnitSettings {
+	u := &UnitSettings{Tokenizer: t, Key: key}
+	if key < 1 {
+		return u
+	}
+	for i, other := range t.units {
+		if other.Key == key {
+			t.units = append(t.units[:i:i], t.units[i+1:]...)
+			break
+		}
+	}
+	for _, unit := range units {
+		if unit == "" || isNumberByte(unit[0]) {
+			t.invalid = append(t.invalid, invalidDefinition{key: key, what: fmt.Sprintf("unit %q is empty or starts with the digit", unit)})
+			continue
+		}
+		u.Units = append(u.Units, []byte(unit))
+	}
+	sort.SliceStable(u.Units, func(i, j int) bool {
+		return len(u.Units[i]) > len(u.Units[j])
+	})
+	t.units = append(t.units, u)
+	return u
+}
+
+// parseUnit parses the unit suffix of the number which starts at `start` and ends at the current position,
+// see Tokenizer.DefineUnits. The number is in p.token and isn't emitted if there is no unit.
+func (p *parsing) parseUnit(start int) bool {
+	var unit *UnitSettings
+	var suffix []byte
+	for _, u := range p.g.units {
+		for _, s := range u.Units {
+			if len(s) > len(suffix) && s[0] == p.curr && p.match(s, false, false) && !p.isWordRune(len(s)) {
+				unit, suffix = u, s
+			}
+		}
+	}
+	if unit == nil {
+		return false
+	}
+	if unit.Merged {
+		p.pos += len(suffix) - 1
+		p.next()
+		p.token.key = unit.Key
+		p.token.value = p.str[start:p.pos]
+		p.token.unit = len(suffix)
+		if p.t.logs(LogTrace) {
+			p.logf(LogTrace, p.token.offset, "number %q with unit (%s)", p.token.value, p.g.KeyName(unit.Key))
+		}
+		p.emmitToken()
+		return true
+	}
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "number %q (%s)", p.token.value, p.g.KeyName(p.token.key))
+	}
+	p.emmitToken()
+	p.token.key = unit.Key
+	p.token.value = suffix
+	p.token.offset = p.offset + p.pos
+	p.pos += len(suffix) - 1
+	p.next()
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "unit %q (%s)", p.token.value, p.g.KeyName(unit.Key))
+	}
+	p.emmitToken()
+	return true
+}
@@ -0,0 +1,16 @@
+//go:build !purego && !safe
+
+package tokenizer
+
+import "unsafe"
+
+// b2s returns the string sharing the memory of the bytes without copying, bytes must not be changed.
+// The `purego` or `safe` build tag disables unsafe conversions, see safe.go.
+func b2s(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// s2b returns bytes sharing the memory of the string without copying, bytes must not be changed.
+func s2b(s string) (b []byte) {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
@@ -0,0 +1,74 @@
+package tokenizer
+
+import (
+	"encoding/hex"
+)
+
+// uuidLength is the length of the canonical UUID, like `550e8400-e29b-41d4-a716-446655440000`.
+const uuidLength = 36
+
+// DefineUUIDToken defines canonical UUIDs with the key `key`, like `550e8400-e29b-41d4-a716-446655440000`:
+// 32 hex digits in any case in groups of 8-4-4-4-12 separated by dashes. The UUID isn't matched if the letter,
+// digit or underscore follows it. UUIDs take precedence over numbers, keywords and tokens.
+// Token.ValueUUID returns bytes of the UUID. TokenUndef disables UUIDs.
+func (t *Tokenizer) DefineUUIDToken(key TokenKey) *Tokenizer {
+	if key < 1 {
+		key = TokenUndef
+	}
+	t.uuid = key
+	return t
+}
+
+// parseUUID parses the UUID at the current position, see Tokenizer.DefineUUIDToken.
+func (p *parsing) parseUUID() bool {
+	if !isHexByte(p.curr) || !p.ensureBytes(uuidLength-1) || !isUUID(p.str[p.pos:p.pos+uuidLength]) || p.isWordRune(uuidLength) {
+		return false
+	}
+	start := p.pos
+	p.pos += uuidLength - 1
+	p.next()
+	p.token.key = p.g.uuid
+	p.token.value = p.str[start:p.pos]
+	p.token.offset = p.offset + start
+	if p.t.logs(LogTrace) {
+		p.logf(LogTrace, p.token.offset, "uuid %q (%s)", p.token.value, p.g.KeyName(p.token.key))
+	}
+	p.emmitToken()
+	return true
+}
+
+// isUUID checks if `v` is the canonical UUID.
+func isUUID(v []byte) bool {
+	if len(v) != uuidLength {
+		return false
+	}
+	for i, b := range v {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if b != '-' {
+				return false
+			}
+		} else if !isHexByte(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValueUUID returns bytes of the canonical UUID, see Tokenizer.DefineUUIDToken.
+// False is returned if the value isn't the UUID.
+func (t *Token) ValueUUID() ([16]byte, bool) {
+	var uuid [16]byte
+	if !isUUID(t.value) {
+		return uuid, false
+	}
+	var digits [32]byte
+	n := 0
+	for _, b := range t.value {
+		if b != '-' {
+			digits[n] = b
+			n++
+		}
+	}
+	_, err := hex.Decode(uuid[:], digits[:])
+	return uuid, err == nil
+}